@@ -0,0 +1,244 @@
+package at
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Auditable 需要审计的 model 实现此接口，AuditEnabled 返回 true 时
+// UpdateByID、DeleteByID 会在同一事务内写入审计记录
+type Auditable interface {
+	AuditEnabled() bool
+}
+
+const (
+	AuditActionUpdate = "update"
+	AuditActionDelete = "delete"
+)
+
+// 审计表默认表名，表结构参考：
+//
+//	CREATE TABLE audit_log (
+//		id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+//		table_name VARCHAR(64) NOT NULL,
+//		pk_value VARCHAR(64) NOT NULL,
+//		action VARCHAR(16) NOT NULL,
+//		actor VARCHAR(128) NOT NULL,
+//		before_data TEXT NULL,
+//		after_data TEXT NULL,
+//		created_at BIGINT NOT NULL
+//	);
+var auditTable = "audit_log"
+
+// 读取修改前的数据时是否加 FOR UPDATE 行锁（SQLite 不支持，需关闭）
+var auditLockRow = true
+
+// InitAudit 设置审计
+// table string	审计表名，为 "" 时使用 audit_log
+// lockRow bool	读取修改前数据时是否使用 SELECT ... FOR UPDATE
+func InitAudit(table string, lockRow bool) {
+	if "" != table {
+		auditTable = table
+	}
+	auditLockRow = lockRow
+}
+
+type auditActorKey struct{}
+
+// WithAuditActor 将操作人放入 context，审计记录的 actor 从此处取得
+func WithAuditActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, auditActorKey{}, actor)
+}
+
+// AuditActorFromContext 取得 context 中的操作人，没有时为 ""
+func AuditActorFromContext(ctx context.Context) string {
+	if nil == ctx {
+		return ""
+	}
+	actor, _ := ctx.Value(auditActorKey{}).(string)
+	return actor
+}
+
+// AuditRecord 一条审计记录
+type AuditRecord struct {
+	TableName string
+	PKValue   string // 主键值，联合主键以 , 分隔
+	Action    string
+	Actor     string
+	// Before 修改前的值，k=表字段
+	Before map[string]interface{}
+	// After 修改后的值，k=表字段，删除时为 nil
+	After map[string]interface{}
+}
+
+func isAuditable(modPointer interface{}) bool {
+	a, isOk := modPointer.(Auditable)
+	return isOk && a.AuditEnabled()
+}
+
+// loadAuditBefore 在事务内读取 model 主键对应的当前行，k=表字段，有租户时只读取当前租户的行
+func (that *BaseDao) loadAuditBefore(ctx context.Context, tx *sql.Tx, tableName string, meta *modelMeta, pkValues []interface{}, fields []string) (map[string]interface{}, error) {
+	s := fmt.Sprintf("SELECT %s FROM %s WHERE %s", strings.Join(fields, ","), tableName, meta.pkWhere(""))
+	params := append([]interface{}{}, pkValues...)
+	tf, tenantID, scoped, err := tenantScope(ctx, meta)
+	if nil != err {
+		return nil, err
+	}
+	if scoped {
+		s, params = that.AddCondTenant(s, params, "", tf.FieldNameByTable, tenantID)
+	}
+	if auditLockRow {
+		s = fmt.Sprintf("%s FOR UPDATE", s)
+	}
+
+	values := make([]interface{}, len(fields))
+	for i := range values {
+		values[i] = new(interface{})
+	}
+	if err := that.queryRowScanContext(ctx, tx, tableName, OpAuditBefore, s, params, values...); nil != err {
+		return nil, err
+	}
+	before := make(map[string]interface{}, len(fields))
+	for i, f := range fields {
+		before[f] = auditNormalize(*(values[i].(*interface{})))
+	}
+	return before, nil
+}
+
+// auditChanged 比较修改前与 model 当前值，返回有变化的字段，创建时间与最后更新不参与比较，
+// 加密字段解密后比较，值记为 AuditMask；编码或解密失败时返回错误，不写入不完整的审计记录
+func auditChanged(before map[string]interface{}, modPointer interface{}) (map[string]interface{}, map[string]interface{}, error) {
+	_, mapModelTableField := (&BaseModel{}).ModelToTableFields(modPointer)
+	mValue := reflect.ValueOf(modPointer)
+	if reflect.Ptr == mValue.Kind() {
+		mValue = mValue.Elem()
+	}
+	oldValues := make(map[string]interface{})
+	newValues := make(map[string]interface{})
+	for k, v := range mapModelTableField {
+		if PropertyCreateTime == v.FieldProperty || PropertyUpdateTime == v.FieldProperty {
+			continue
+		}
+		old, isOk := before[v.FieldNameByTable]
+		if !isOk {
+			continue
+		}
+		if _, isOk := blindIndexSource(v.FieldNameByTable, mapModelTableField); isOk {
+			// 盲索引随加密字段变化，不单独记录
+			continue
+		}
+		now, err := EncodeValue(v.FieldCodec, mValue.FieldByName(k).Interface())
+		if nil != err {
+			return nil, nil, err
+		}
+		now = auditNormalize(now)
+		if v.FieldEncrypt {
+			// 加密字段解密后比较，审计记录中不保存明文
+			if nil != old && "" != old {
+				plain, err := Decrypt(fmt.Sprint(old))
+				if nil != err {
+					return nil, nil, err
+				}
+				old = string(plain)
+			}
+			if auditEqual(old, now) {
+				continue
+			}
+			oldValues[v.FieldNameByTable] = AuditMask
+			newValues[v.FieldNameByTable] = AuditMask
+			continue
+		}
+		if auditEqual(old, now) {
+			continue
+		}
+		oldValues[v.FieldNameByTable] = old
+		newValues[v.FieldNameByTable] = now
+	}
+	return oldValues, newValues, nil
+}
+
+// auditEqual 比较修改前与修改后的值。一方为数值（或 bool）时按数值比较，
+// 兼容驱动返回的 int64、[]byte 与 model 中的 int、float、bool，如 "3.50" 与 3.5
+func auditEqual(old, now interface{}) bool {
+	_, oldIsStr := old.(string)
+	_, nowIsStr := now.(string)
+	if oldIsStr && nowIsStr || nil == old || nil == now {
+		return fmt.Sprint(old) == fmt.Sprint(now)
+	}
+	a, isOk := auditNumber(old)
+	b, isOk2 := auditNumber(now)
+	if isOk && isOk2 {
+		return a == b
+	}
+	return fmt.Sprint(old) == fmt.Sprint(now)
+}
+
+// auditNumber 数值、bool 与数值字符串转换为 float64
+func auditNumber(v interface{}) (float64, bool) {
+	rv := reflect.ValueOf(v)
+	switch {
+	case reflect.Bool == rv.Kind():
+		if rv.Bool() {
+			return 1, true
+		}
+		return 0, true
+	case isIntKind(rv.Kind()):
+		return float64(reflectInt(rv)), true
+	case reflect.Float32 == rv.Kind():
+		// float32 按最短表示转换，避免 0.1 变为 0.10000000149011612
+		f, err := strconv.ParseFloat(strconv.FormatFloat(rv.Float(), 'g', -1, 32), 64)
+		return f, nil == err
+	case reflect.Float64 == rv.Kind():
+		return rv.Float(), true
+	case reflect.String == rv.Kind():
+		f, err := strconv.ParseFloat(rv.String(), 64)
+		return f, nil == err
+	}
+	return 0, false
+}
+
+// auditNormalize 统一驱动返回值与 model 值的表现形式，便于比较与序列化
+func auditNormalize(v interface{}) interface{} {
+	switch val := v.(type) {
+	case []byte:
+		return string(val)
+	case time.Time:
+		return val.Format("2006-01-02 15:04:05")
+	}
+	rv := reflect.ValueOf(v)
+	if reflect.Ptr == rv.Kind() {
+		if rv.IsNil() {
+			return nil
+		}
+		return auditNormalize(rv.Elem().Interface())
+	}
+	return v
+}
+
+// writeAudit 在同一事务内写入审计记录
+func (that *BaseDao) writeAudit(ctx context.Context, tx *sql.Tx, record AuditRecord) error {
+	var before, after interface{}
+	if nil != record.Before {
+		b, err := json.Marshal(record.Before)
+		if nil != err {
+			return err
+		}
+		before = string(b)
+	}
+	if nil != record.After {
+		b, err := json.Marshal(record.After)
+		if nil != err {
+			return err
+		}
+		after = string(b)
+	}
+	s := fmt.Sprintf("INSERT INTO %s(table_name,pk_value,action,actor,before_data,after_data,created_at) VALUES(?,?,?,?,?,?,?)", auditTable)
+	_, err := that.execContext(ctx, tx, auditTable, OpAuditWrite, s, record.TableName, record.PKValue, record.Action, record.Actor, before, after, nowTime().Unix())
+	return err
+}
@@ -0,0 +1,198 @@
+package at
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func setTestKey(t *testing.T) {
+	t.Helper()
+	key := []byte("0123456789abcdef0123456789abcdef")
+	SetKeyProvider(NewStaticKeyProvider("k1", map[string][]byte{"k1": key}, key))
+	t.Cleanup(func() { SetKeyProvider(nil) })
+}
+
+// accountRow 修改前的行，数值按 MySQL 文本协议返回 []byte 或 int64
+func accountRow(t *testing.T, secret string) (func(string) ([]string, [][]driver.Value), string) {
+	t.Helper()
+	enc, err := Encrypt([]byte(secret))
+	if nil != err {
+		t.Fatal(err)
+	}
+	return func(string) ([]string, [][]driver.Value) {
+		return []string{"id", "name", "balance", "level", "active", "secret"},
+			[][]driver.Value{{int64(1), []byte("tom"), []byte("3.50"), int64(2), int64(1), enc}}
+	}, enc
+}
+
+// auditWrites 写入审计表的参数
+func auditWrites(f *fakeDB) [][]driver.Value {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var writes [][]driver.Value
+	for i, s := range f.execs {
+		if strings.HasPrefix(s, "INSERT INTO audit_log") {
+			writes = append(writes, f.args[i])
+		}
+	}
+	return writes
+}
+
+func auditData(t *testing.T, v driver.Value) map[string]interface{} {
+	t.Helper()
+	if nil == v {
+		return nil
+	}
+	m := make(map[string]interface{})
+	if err := json.Unmarshal([]byte(v.(string)), &m); nil != err {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func updateAccount(ctx context.Context, db *sql.DB, mod *testAccount) error {
+	return GetInstanceByBaseDao().TransactionContext(ctx, db, nil, func(tx *sql.Tx) error {
+		_, err := GetInstanceByBaseDao().UpdateByIDContext(ctx, tx, mod)
+		return err
+	})
+}
+
+func TestAuditUpdate(t *testing.T) {
+	setTestKey(t)
+	f, db := newFakeDB()
+	defer db.Close()
+	f.rows, _ = accountRow(t, "s1")
+	ctx := WithAuditActor(context.Background(), "admin")
+
+	// 数值与 bool 和驱动返回值相等，只记录 name
+	err := updateAccount(ctx, db, &testAccount{Id: 1, Name: "jerry", Balance: 3.5, Level: 2, Active: true, Secret: "s1"})
+	if nil != err {
+		t.Fatal(err)
+	}
+	writes := auditWrites(f)
+	if 1 != len(writes) {
+		t.Fatalf("audit writes = %v", writes)
+	}
+	w := writes[0]
+	if "account" != w[0] || "1" != w[1] || AuditActionUpdate != w[2] || "admin" != w[3] {
+		t.Errorf("audit record = %v", w)
+	}
+	if before := auditData(t, w[4]); !reflect.DeepEqual(map[string]interface{}{"name": "tom"}, before) {
+		t.Errorf("before = %v", before)
+	}
+	if after := auditData(t, w[5]); !reflect.DeepEqual(map[string]interface{}{"name": "jerry"}, after) {
+		t.Errorf("after = %v", after)
+	}
+}
+
+func TestAuditUpdateNumbers(t *testing.T) {
+	setTestKey(t)
+	f, db := newFakeDB()
+	defer db.Close()
+	f.rows, _ = accountRow(t, "s1")
+
+	err := updateAccount(context.Background(), db, &testAccount{Id: 1, Name: "tom", Balance: 4, Level: 3, Active: false, Secret: "s1"})
+	if nil != err {
+		t.Fatal(err)
+	}
+	writes := auditWrites(f)
+	if 1 != len(writes) {
+		t.Fatalf("audit writes = %v", writes)
+	}
+	after := auditData(t, writes[0][5])
+	for _, k := range []string{"balance", "level", "active"} {
+		if _, isOk := after[k]; !isOk {
+			t.Errorf("%s changed but not audited: %v", k, after)
+		}
+	}
+	if _, isOk := after["name"]; isOk {
+		t.Errorf("unchanged name audited: %v", after)
+	}
+}
+
+func TestAuditEncryptedFieldMasked(t *testing.T) {
+	setTestKey(t)
+	f, db := newFakeDB()
+	var enc string
+	f.rows, enc = accountRow(t, "s1")
+	defer db.Close()
+
+	err := updateAccount(context.Background(), db, &testAccount{Id: 1, Name: "tom", Balance: 3.5, Level: 2, Active: true, Secret: "s2"})
+	if nil != err {
+		t.Fatal(err)
+	}
+	writes := auditWrites(f)
+	if 1 != len(writes) {
+		t.Fatalf("audit writes = %v", writes)
+	}
+	before, after := auditData(t, writes[0][4]), auditData(t, writes[0][5])
+	if AuditMask != before["secret"] || AuditMask != after["secret"] || 1 != len(after) {
+		t.Errorf("before = %v after = %v, want only the masked secret", before, after)
+	}
+	for _, v := range writes[0] {
+		if s, isOk := v.(string); isOk && (strings.Contains(s, "s1") || strings.Contains(s, "s2") || strings.Contains(s, enc)) {
+			t.Errorf("audit record contains the secret: %v", writes[0])
+		}
+	}
+}
+
+func TestAuditDecryptError(t *testing.T) {
+	setTestKey(t)
+	f, db := newFakeDB()
+	defer db.Close()
+	f.rows = func(string) ([]string, [][]driver.Value) {
+		return []string{"id", "name", "balance", "level", "active", "secret"},
+			[][]driver.Value{{int64(1), "tom", "3.5", int64(2), int64(1), "not encrypted"}}
+	}
+	err := updateAccount(context.Background(), db, &testAccount{Id: 1, Name: "tom", Balance: 3.5, Level: 2, Active: true, Secret: "s1"})
+	if nil == err {
+		t.Fatal("update with an undecryptable old value succeeded")
+	}
+	if 0 != len(auditWrites(f)) || 0 != f.commits {
+		t.Errorf("audit writes = %v commits = %d, want rollback", auditWrites(f), f.commits)
+	}
+}
+
+func TestAuditDeleteScopedToTenant(t *testing.T) {
+	f, db := newFakeDB()
+	defer db.Close()
+	f.rows = func(string) ([]string, [][]driver.Value) {
+		return []string{"id", "tenant_id", "body"}, [][]driver.Value{{int64(1), int64(7), []byte("old")}}
+	}
+	ctx := WithAuditActor(WithTenant(context.Background(), int64(7)), "admin")
+	err := GetInstanceByBaseDao().TransactionContext(ctx, db, nil, func(tx *sql.Tx) error {
+		_, err := GetInstanceByBaseDao().DeleteByIDContext(ctx, tx, &testNote{Id: 1})
+		return err
+	})
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	f.mu.Lock()
+	for i, s := range f.execs {
+		if strings.HasPrefix(s, "SELECT") || strings.HasPrefix(s, "DELETE") {
+			if !strings.Contains(s, "tenant_id = ?") || int64(7) != f.args[i][len(f.args[i])-1] {
+				t.Errorf("%s %v is not scoped to the tenant", s, f.args[i])
+			}
+		}
+	}
+	f.mu.Unlock()
+
+	writes := auditWrites(f)
+	if 1 != len(writes) {
+		t.Fatalf("audit writes = %v", writes)
+	}
+	w := writes[0]
+	if "note" != w[0] || "1" != w[1] || AuditActionDelete != w[2] || "admin" != w[3] || nil != w[5] {
+		t.Errorf("audit record = %v", w)
+	}
+	want := map[string]interface{}{"id": float64(1), "tenant_id": float64(7), "body": "old"}
+	if before := auditData(t, w[4]); !reflect.DeepEqual(want, before) {
+		t.Errorf("before = %v, want %v", before, want)
+	}
+}
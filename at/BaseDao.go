@@ -1,12 +1,15 @@
 package at
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
+	"log/slog"
 	"reflect"
 	"strconv"
 	"strings"
+	"sync"
 )
 
 type BaseDao struct {
@@ -31,44 +34,109 @@ func InitDao(log Logs) {
 	daoLogs = log
 }
 
-func (*BaseDao) LogDebug(msg string) {
-	if nil != daoLogs {
-		daoLogs.Debug(msg)
-	}
+func (that *BaseDao) LogDebug(msg string) {
+	that.logAttrs(context.Background(), slog.LevelDebug, msg, nil)
 }
 
-func (*BaseDao) LogError(msg string, err error) {
-	if nil != daoLogs {
-		daoLogs.Error(msg, err)
-	}
+func (that *BaseDao) LogError(msg string, err error) {
+	that.logAttrs(context.Background(), slog.LevelError, msg, err)
 }
 
-// Transaction	开启事务
+// Transaction	开启事务，见 TransactionContext
 // db *sql.DB	数据源
 // callBack func() error	开启事务执行 callBack 函数，error 返回为 nil 时提交事务，不为 nil 回滚事务。
 func (that *BaseDao) Transaction(db *sql.DB, callBack func(tx *sql.Tx) error) error {
-	tx, err := db.Begin()
+	return that.TransactionContext(context.Background(), db, nil, callBack)
+}
+
+// TransactionContext	开启事务执行 callBack，callBack 返回 nil 时提交，否则回滚。
+// 开启事务与提交失败时返回对应错误；回滚失败时返回 errors.Join(callBack 的错误, 回滚的错误)；
+// callBack panic 时回滚后以原来的值再次 panic；提交成功后执行 AfterCommit 注册的函数（如查询缓存失效）
+// ctx context.Context	上下文，取消时事务回滚
+// db *sql.DB	数据源
+// opts *sql.TxOptions	隔离级别与只读，nil 为数据库默认
+// callBack func(tx *sql.Tx) error	事务中执行的函数
+// error	不为 nil 时事务没有提交
+func (that *BaseDao) TransactionContext(ctx context.Context, db *sql.DB, opts *sql.TxOptions, callBack func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, opts)
 	if nil != err {
-		that.LogError("Transaction", err)
+		that.logAttrs(ctx, slog.LevelError, "Transaction Begin", err)
 		return err
 	}
-
-	defer func(tx *sql.Tx) {
-		err2 := recover()
-		if nil != err2 {
-			that.LogError(fmt.Sprintf("Transaction err=%v,auto RollBack", err), nil)
-			tx.Rollback()
-			panic(err2)
+	hooks := &txHookList{db: db}
+	txHooks.Store(tx, hooks)
+	defer txHooks.Delete(tx)
+
+	defer func() {
+		if p := recover(); nil != p {
+			that.logAttrs(ctx, slog.LevelError, fmt.Sprintf("Transaction panic=%v,auto RollBack", p), nil)
+			if err2 := tx.Rollback(); nil != err2 {
+				that.logAttrs(ctx, slog.LevelError, "Transaction Rollback", err2)
+			}
+			panic(p)
 		}
-	}(tx)
+	}()
+
+	if err = callBack(tx); nil != err {
+		that.logAttrs(ctx, slog.LevelError, "Transaction", err)
+		// ctx 取消时事务已被回滚，Rollback 返回 sql.ErrTxDone
+		if err2 := tx.Rollback(); nil != err2 && !errors.Is(err2, sql.ErrTxDone) {
+			that.logAttrs(ctx, slog.LevelError, "Transaction Rollback", err2)
+			return errors.Join(err, err2)
+		}
+		return err
+	}
+	if err = tx.Commit(); nil != err {
+		that.logAttrs(ctx, slog.LevelError, "Transaction Commit", err)
+		return err
+	}
+	hooks.run()
+	return nil
+}
 
-	err2 := callBack(tx)
-	if nil != err2 {
-		that.LogError("Transaction2", err2)
-		tx.Rollback()
-		return err2
+// txHooks TransactionContext 开启的事务，值为开启事务的数据源与提交后执行的函数
+var txHooks sync.Map
+
+type txHookList struct {
+	db    *sql.DB
+	lock  sync.Mutex
+	funcs []func()
+}
+
+// txDB TransactionContext 开启 tx 的数据源，其它方式开启的事务返回 false
+func txDB(tx *sql.Tx) (*sql.DB, bool) {
+	if v, isOk := txHooks.Load(tx); isOk {
+		return v.(*txHookList).db, true
 	}
-	return tx.Commit()
+	return nil, false
+}
+
+func (that *txHookList) run() {
+	that.lock.Lock()
+	funcs := that.funcs
+	that.funcs = nil
+	that.lock.Unlock()
+	for _, fun := range funcs {
+		fun()
+	}
+}
+
+// AfterCommit 注册事务提交成功后执行的函数，回滚时不执行。
+// tx 为 nil 或不是 TransactionContext（包括 Transaction、BaseService 的事务）开启的事务时立即执行，
+// 自己开启的事务需在提交后自行处理，如调用 InvalidateCache
+// tx *sql.Tx	事务
+// fun func()	提交后执行的函数
+func AfterCommit(tx *sql.Tx, fun func()) {
+	if nil != tx {
+		if v, isOk := txHooks.Load(tx); isOk {
+			hooks := v.(*txHookList)
+			hooks.lock.Lock()
+			hooks.funcs = append(hooks.funcs, fun)
+			hooks.lock.Unlock()
+			return
+		}
+	}
+	fun()
 }
 
 // AddModel	标准：入库一个Model
@@ -77,6 +145,30 @@ func (that *BaseDao) Transaction(db *sql.DB, callBack func(tx *sql.Tx) error) er
 // int64	lastInsertId 入库成功数据的主键id
 // error	err 失败不为 nil，应回滚
 func (that *BaseDao) AddModel(tx *sql.Tx, modPointer interface{}) (int64, error) {
+	return that.AddModelContext(context.Background(), tx, modPointer)
+}
+
+// AddModelContext	标准：入库一个Model
+// ctx context.Context	上下文
+// tx *sql.Tx 事务控制器
+// modPointer interface{}	数据，model 的指针。
+// int64	lastInsertId 入库成功数据的主键id
+// error	err 失败不为 nil，应回滚
+func (that *BaseDao) AddModelContext(ctx context.Context, tx *sql.Tx, modPointer interface{}) (int64, error) {
+	meta := getModelMeta(modPointer)
+	// 多租户：上下文中的租户写入 model
+	if err := setTenant(ctx, meta, reflect.ValueOf(modPointer).Elem()); nil != err {
+		return -1, err
+	}
+	// 主键有 idgen tag 时生成 ID，需在分表之前
+	if err := fillID(meta, reflect.ValueOf(modPointer).Elem()); nil != err {
+		return -1, err
+	}
+	// 按 validate tag 校验，未通过返回 ValidationErrors
+	if err := Validate(modPointer); nil != err {
+		that.logOp(ctx, slog.LevelWarn, "", OpAddModel, "Validate", err)
+		return -1, err
+	}
 	modVal := reflect.ValueOf(modPointer)
 
 	// 调用 GetFieldsSQLByInsert 得到插入 SQL
@@ -91,10 +183,12 @@ func (that *BaseDao) AddModel(tx *sql.Tx, modPointer interface{}) (int64, error)
 	getTableName := modVal.MethodByName("GetTableName")
 	getTableNameParams := make([]reflect.Value, getTableName.Type().NumIn())
 	getTableNameResult := getTableName.Call(getTableNameParams)
-	tableName := getTableNameResult[0].String()
+	tableName, err := shardTable(getTableNameResult[0].String(), modPointer)
+	if nil != err {
+		return -1, err
+	}
 
 	s := fmt.Sprintf("INSERT INTO %s(%s) VALUE(%s)", tableName, insertSQL, sqlValues)
-	that.LogDebug(s)
 
 	// 调用 GetValueListByTableField 获得参数
 	getValueListByTableField := modVal.MethodByName("GetValueListByTableField")
@@ -105,19 +199,24 @@ func (that *BaseDao) AddModel(tx *sql.Tx, modPointer interface{}) (int64, error)
 	valueList := getValueListByTableFieldResult[0].Interface().([]interface{})
 
 	//	执行 SQL
-	r, err := tx.Exec(s, valueList...)
+	r, err := that.execContext(ctx, tx, tableName, OpAddModel, s, valueList...)
 	if nil != err {
-		that.LogError(fmt.Sprintf("%s AddModel", tableName), err)
 		return -1, err
 	}
+	if !meta.pkAutoIncrement() {
+		// 主键由 model 赋值或 idgen 生成，不使用 LastInsertId
+		invalidateModelCache(tx, modPointer)
+		return modelInsertID(meta, reflect.ValueOf(modPointer).Elem()), nil
+	}
 	insertID, err2 := r.LastInsertId()
 	if nil != err2 {
-		that.LogError(fmt.Sprintf("%s AddModel LastInsertId", tableName), err)
+		that.logOp(ctx, slog.LevelError, tableName, OpAddModel, "LastInsertId", err2)
 		return -1, err2
 	}
 	if 0 == insertID {
-		return insertID, errors.New("error:insert fail")
+		return insertID, newDaoError(tableName, OpAddModel, ErrInsertFailed)
 	}
+	invalidateModelCache(tx, modPointer)
 	return insertID, nil
 }
 
@@ -127,6 +226,30 @@ func (that *BaseDao) AddModel(tx *sql.Tx, modPointer interface{}) (int64, error)
 // int64	rowsAffected 受影响行数
 // error	err 不为 nil 时失败，应该回滚事务
 func (that *BaseDao) UpdateByID(tx *sql.Tx, modPointer interface{}) (int64, error) {
+	return that.UpdateByIDContext(context.Background(), tx, modPointer)
+}
+
+// UpdateByIDContext	标准：根据主键修改一条数据Model，model 实现 Auditable 时同一事务内写入审计记录
+// ctx context.Context	上下文，审计操作人由 WithAuditActor 放入
+// tx *sql.Tx 事务控制器
+// modPointer interface{}	数据，model 的指针。
+// int64	rowsAffected 受影响行数
+// error	err 不为 nil 时失败，应该回滚事务
+func (that *BaseDao) UpdateByIDContext(ctx context.Context, tx *sql.Tx, modPointer interface{}) (int64, error) {
+	// 多租户：租户不能被修改，上下文中的租户写入 model，WHERE 增加租户条件
+	meta := getModelMeta(modPointer)
+	tf, tenantID, tenantScoped, err := tenantScope(ctx, meta)
+	if nil != err {
+		return -1, err
+	}
+	if err = setTenant(ctx, meta, reflect.ValueOf(modPointer).Elem()); nil != err {
+		return -1, err
+	}
+	// 按 validate tag 校验，未通过返回 ValidationErrors
+	if err := Validate(modPointer); nil != err {
+		that.logOp(ctx, slog.LevelWarn, "", OpUpdateByID, "Validate", err)
+		return -1, err
+	}
 	modVal := reflect.ValueOf(modPointer)
 
 	// 调用 GetDefaultAlias 获得默认的 alias
@@ -146,16 +269,13 @@ func (that *BaseDao) UpdateByID(tx *sql.Tx, modPointer interface{}) (int64, erro
 	getTableName := modVal.MethodByName("GetTableName")
 	getTableNameParams := make([]reflect.Value, getTableName.Type().NumIn())
 	getTableNameResult := getTableName.Call(getTableNameParams)
-	tableName := getTableNameResult[0].String()
-
-	// 调用 GetPKTableField 得到表主键名
-	getPKTableField := modVal.MethodByName("GetPKTableField")
-	getPKTableFieldParams := make([]reflect.Value, getPKTableField.Type().NumIn())
-	getPKTableFieldResult := getPKTableField.Call(getPKTableFieldParams)
-	pkFieldName := getPKTableFieldResult[0].String()
+	tableName, err := shardTable(getTableNameResult[0].String(), modPointer)
+	if nil != err {
+		return -1, err
+	}
 
-	s := fmt.Sprintf("UPDATE %s AS %s SET %s WHERE %s.%s = ? ", tableName, alias, updateField, alias, pkFieldName)
-	that.LogDebug(s)
+	// 主键条件，联合主键为多个字段，见 PK.go
+	s := fmt.Sprintf("UPDATE %s AS %s SET %s WHERE %s ", tableName, alias, updateField, meta.pkWhere(alias))
 
 	//	调用 GetValueListByTableField 将值装进切片
 	getValueListByTableField := modVal.MethodByName("GetValueListByTableField")
@@ -165,25 +285,132 @@ func (that *BaseDao) UpdateByID(tx *sql.Tx, modPointer interface{}) (int64, erro
 	getValueListByTableFieldResult := getValueListByTableField.Call(getValueListByTableFieldParams)
 	valueList := getValueListByTableFieldResult[0].Interface().([]interface{})
 
-	//	调用 GetPKValue 获得主键值，将主键值作为条件最后装入
-	getPKValue := modVal.MethodByName("GetPKValue")
-	getPKValueParams := make([]reflect.Value, getPKValue.Type().NumIn())
-	getPKValueResult := getPKValue.Call(getPKValueParams)
-	pkValue := getPKValueResult[0].Interface()
+	//	主键值作为条件最后装入
+	pkValues := meta.pkValues(modVal.Elem())
+
+	// 审计：修改前读取当前行
+	auditable := isAuditable(modPointer)
+	var before map[string]interface{}
+	if auditable {
+		listTableFields, _ := (&BaseModel{}).ModelToTableFields(modPointer)
+		var err error
+		before, err = that.loadAuditBefore(ctx, tx, tableName, meta, pkValues, listTableFields)
+		if nil != err {
+			return -1, err
+		}
+	}
+
+	valueList = append(valueList, pkValues...)
+	if tenantScoped {
+		s, valueList = that.AddCondTenant(s, valueList, alias, tf.FieldNameByTable, tenantID)
+	}
+	result, err := that.execContext(ctx, tx, tableName, OpUpdateByID, s, valueList...)
+	if nil != err {
+		return -1, err
+	}
+	rowsAffected, err2 := result.RowsAffected()
+	if nil != err2 {
+		that.logOp(ctx, slog.LevelError, tableName, OpUpdateByID, "RowsAffected", err2)
+		return -1, err2
+	}
+	if 0 == rowsAffected {
+		return 0, newDaoError(tableName, OpUpdateByID, ErrNoRowsAffected)
+	}
+	invalidateModelCache(tx, modPointer)
+
+	if auditable {
+		oldValues, newValues, err3 := auditChanged(before, modPointer)
+		if nil != err3 {
+			that.logOp(ctx, slog.LevelError, tableName, OpUpdateByID, "Audit", err3)
+			return -1, err3
+		}
+		if 0 != len(newValues) {
+			err3 = that.writeAudit(ctx, tx, AuditRecord{
+				TableName: tableName,
+				PKValue:   meta.pkKey(modVal.Elem()),
+				Action:    AuditActionUpdate,
+				Actor:     AuditActorFromContext(ctx),
+				Before:    oldValues,
+				After:     newValues,
+			})
+			if nil != err3 {
+				return -1, err3
+			}
+		}
+	}
+	return rowsAffected, nil
+}
+
+// DeleteByID	标准：根据主键删除一条数据Model
+// tx *sql.Tx 事务控制器
+// modPointer interface{}	数据，model 的指针，只需要主键有值。
+// int64	rowsAffected 受影响行数
+// error	err 不为 nil 时失败，应该回滚事务
+func (that *BaseDao) DeleteByID(tx *sql.Tx, modPointer interface{}) (int64, error) {
+	return that.DeleteByIDContext(context.Background(), tx, modPointer)
+}
+
+// DeleteByIDContext	标准：根据主键删除一条数据Model，model 实现 Auditable 时同一事务内写入审计记录
+// ctx context.Context	上下文，审计操作人由 WithAuditActor 放入
+// tx *sql.Tx 事务控制器
+// modPointer interface{}	数据，model 的指针，只需要主键有值。
+// int64	rowsAffected 受影响行数
+// error	err 不为 nil 时失败，应该回滚事务
+func (that *BaseDao) DeleteByIDContext(ctx context.Context, tx *sql.Tx, modPointer interface{}) (int64, error) {
+	modVal := reflect.ValueOf(modPointer)
+	meta := getModelMeta(modPointer)
+	pkValues := meta.pkValues(modVal.Elem())
+	tf, tenantID, tenantScoped, err := tenantScope(ctx, meta)
+	if nil != err {
+		return -1, err
+	}
+	tableName, err := shardTable(callModelMethod(modVal, "GetTableName")[0].String(), modPointer)
+	if nil != err {
+		return -1, err
+	}
+
+	// 审计：删除前读取整行
+	auditable := isAuditable(modPointer)
+	var before map[string]interface{}
+	if auditable {
+		listTableFields, _ := (&BaseModel{}).ModelToTableFields(modPointer)
+		var err error
+		before, err = that.loadAuditBefore(ctx, tx, tableName, meta, pkValues, listTableFields)
+		if nil != err {
+			return -1, err
+		}
+	}
 
-	valueList = append(valueList, pkValue)
-	result, err := tx.Exec(s, valueList...)
+	s := fmt.Sprintf("DELETE FROM %s WHERE %s ", tableName, meta.pkWhere(""))
+	params := pkValues
+	if tenantScoped {
+		s, params = that.AddCondTenant(s, params, "", tf.FieldNameByTable, tenantID)
+	}
+	result, err := that.execContext(ctx, tx, tableName, OpDeleteByID, s, params...)
 	if nil != err {
-		that.LogError(fmt.Sprintf("%s UpdateByID", tableName), err)
 		return -1, err
 	}
 	rowsAffected, err2 := result.RowsAffected()
 	if nil != err2 {
-		that.LogError(fmt.Sprintf("%s UpdateByID RowsAffected", tableName), err2)
+		that.logOp(ctx, slog.LevelError, tableName, OpDeleteByID, "RowsAffected", err2)
 		return -1, err2
 	}
 	if 0 == rowsAffected {
-		return 0, errors.New("error:update row 0")
+		return 0, newDaoError(tableName, OpDeleteByID, ErrNoRowsAffected)
+	}
+	invalidateModelCache(tx, modPointer)
+
+	if auditable {
+		err3 := that.writeAudit(ctx, tx, AuditRecord{
+			TableName: tableName,
+			PKValue:   meta.pkKey(modVal.Elem()),
+			Action:    AuditActionDelete,
+			Actor:     AuditActorFromContext(ctx),
+			Before:    before,
+		})
+		if nil != err3 {
+			return -1, err3
+		}
 	}
 	return rowsAffected, nil
 }
@@ -195,12 +422,41 @@ func (that *BaseDao) UpdateByID(tx *sql.Tx, modPointer interface{}) (int64, erro
 // int64	rowsAffected 受影响行数
 // error	err	不为 nil 时失败，应回滚事务
 func (that *BaseDao) AddModelBatch(tx *sql.Tx, modPointerList interface{}) (int64, int64, error) {
+	return that.AddModelBatchContext(context.Background(), tx, modPointerList)
+}
+
+// AddModelBatchContext	批量插入，见 AddModelBatch，分表时全部数据需在同一个物理表，否则返回 ErrShardSpan
+// ctx context.Context	上下文，多租户时上下文中的租户写入每一个 model
+// tx *sql.Tx 事务控制器
+// modPointerList interface{}	数据，装载 model 数据的切片，数据 model 应该是指针。
+// int64	lastInsertId 最后一条插入的 ID
+// int64	rowsAffected 受影响行数
+// error	err	不为 nil 时失败，应回滚事务
+func (that *BaseDao) AddModelBatchContext(ctx context.Context, tx *sql.Tx, modPointerList interface{}) (int64, int64, error) {
 	modLst := reflect.ValueOf(modPointerList)
+	var meta *modelMeta
+	if 0 != modLst.Len() {
+		meta = getModelMeta(modLst.Index(0).Interface())
+		for i := 0; i < modLst.Len(); i++ {
+			if err := setTenant(ctx, meta, modLst.Index(i).Elem()); nil != err {
+				return -1, 0, err
+			}
+			if err := fillID(meta, modLst.Index(i).Elem()); nil != err {
+				return -1, 0, err
+			}
+		}
+	}
+	// 逐条按 validate tag 校验，ValidationError.Index 为数据下标
+	if err := ValidateList(modPointerList); nil != err {
+		that.logOp(ctx, slog.LevelWarn, "", OpAddModelBatch, "Validate", err)
+		return -1, 0, err
+	}
 	sql := strings.Builder{}
 	valueList := make([]interface{}, 0)
 	sqlValues := ""
 	insertSQL := ""
 	tableName := ""
+	var err error
 	for i := 0; i < modLst.Len(); i++ {
 		modVal := modLst.Index(i)
 		if 0 == i {
@@ -217,10 +473,23 @@ func (that *BaseDao) AddModelBatch(tx *sql.Tx, modPointerList interface{}) (int6
 			getTableNameParams := make([]reflect.Value, getTableName.Type().NumIn())
 			getTableNameResult := getTableName.Call(getTableNameParams)
 			tableName = getTableNameResult[0].String()
+			if tableName, err = shardTable(tableName, modVal.Interface()); nil != err {
+				return -1, 0, err
+			}
 
 			sql.WriteString(fmt.Sprintf("INSERT INTO %s(%s) VALUES", tableName, insertSQL))
 		}
 
+		if 0 != i {
+			// 分表时全部数据需在同一个物理表
+			shard, err := shardTable(callModelMethod(modVal, "GetTableName")[0].String(), modVal.Interface())
+			if nil != err {
+				return -1, 0, err
+			}
+			if shard != tableName {
+				return -1, 0, ErrShardSpan
+			}
+		}
 		sql.WriteString(fmt.Sprintf("(%s)", sqlValues))
 		if i != modLst.Len()-1 {
 			sql.WriteString(",")
@@ -237,24 +506,28 @@ func (that *BaseDao) AddModelBatch(tx *sql.Tx, modPointerList interface{}) (int6
 	}
 
 	//	执行 SQL
-	r, err := tx.Exec(sql.String(), valueList...)
+	r, err := that.execContext(ctx, tx, tableName, OpAddModelBatch, sql.String(), valueList...)
 	if nil != err {
-		that.LogError(fmt.Sprintf("%s AddModelBatch", tableName), err)
 		return -1, 0, err
 	}
 	rows, err21 := r.RowsAffected()
 	if nil != err21 {
-		that.LogError(fmt.Sprintf("%s AddModelBatch RowsAffected", tableName), err)
+		that.logOp(ctx, slog.LevelError, tableName, OpAddModelBatch, "RowsAffected", err21)
 		return -1, rows, err21
 	}
+	if nil != meta && !meta.pkAutoIncrement() {
+		invalidateModelCache(tx, modLst.Index(0).Interface())
+		return modelInsertID(meta, modLst.Index(modLst.Len()-1).Elem()), rows, nil
+	}
 	insertID, err22 := r.LastInsertId()
 	if nil != err22 {
-		that.LogError(fmt.Sprintf("%s AddModelBatch LastInsertId", tableName), err)
+		that.logOp(ctx, slog.LevelError, tableName, OpAddModelBatch, "LastInsertId", err22)
 		return -1, 0, err22
 	}
 	if 0 == insertID {
-		return insertID, 0, errors.New("error:insert fail")
+		return insertID, 0, newDaoError(tableName, OpAddModelBatch, ErrInsertFailed)
 	}
+	invalidateModelCache(tx, modLst.Index(0).Interface())
 	return insertID, rows, nil
 }
 
@@ -263,18 +536,17 @@ func (that *BaseDao) AddModelBatch(tx *sql.Tx, modPointerList interface{}) (int6
 // s string 执行的 SQL
 // args ...any	参数，可变数组
 func (that *BaseDao) UpdateMustAffected(tx *sql.Tx, s string, args ...any) (int64, error) {
-	result, err1 := tx.Exec(s, args...)
+	result, err1 := that.execContext(context.Background(), tx, "", OpUpdateMustAffected, s, args...)
 	if nil != err1 {
-		that.LogError(fmt.Sprintf("updateMustAffected - 1 sql=%s", s), err1)
 		return 0, err1
 	}
 	rowsAffected, err2 := result.RowsAffected()
 	if nil != err2 {
-		that.LogError(fmt.Sprintf("updateMustAffected UpdateByID RowsAffected sql=%s", s), err2)
+		that.logOp(context.Background(), slog.LevelError, "", OpUpdateMustAffected, "RowsAffected", err2)
 		return -1, err2
 	}
 	if 0 == rowsAffected {
-		return 0, errors.New("error:update row 0")
+		return 0, newDaoError("", OpUpdateMustAffected, ErrNoRowsAffected)
 	}
 	return rowsAffected, nil
 }
@@ -284,14 +556,13 @@ func (that *BaseDao) UpdateMustAffected(tx *sql.Tx, s string, args ...any) (int6
 // s string 执行的 SQL
 // args ...any	参数，可变数组
 func (that *BaseDao) Update(tx *sql.Tx, s string, args ...any) (int64, error) {
-	result, err1 := tx.Exec(s, args...)
+	result, err1 := that.execContext(context.Background(), tx, "", OpUpdate, s, args...)
 	if nil != err1 {
-		that.LogError(fmt.Sprintf("updateMustAffected - 1 sql=%s", s), err1)
 		return 0, err1
 	}
 	rowsAffected, err2 := result.RowsAffected()
 	if nil != err2 {
-		that.LogError(fmt.Sprintf("updateMustAffected UpdateByID RowsAffected sql=%s", s), err2)
+		that.logOp(context.Background(), slog.LevelError, "", OpUpdate, "RowsAffected", err2)
 		return -1, err2
 	}
 	return rowsAffected, nil
@@ -299,42 +570,40 @@ func (that *BaseDao) Update(tx *sql.Tx, s string, args ...any) (int64, error) {
 
 // AddLimit 分页
 func (that *BaseDao) AddLimit(condition map[string]interface{}, s string) string {
+	offset, size := LimitOffset(condition)
+	return fmt.Sprintf("%s LIMIT %d,%d", s, offset, size)
+}
+
+// LimitOffset 按 CondLimitBegin、CondPageIndex、CondPageSize 计算分页的起始条目与条目数，默认 0,20，
+// 负数（如 condPageIndex 为 0）按 0 处理
+func LimitOffset(condition map[string]interface{}) (int, int) {
+	offset, size := limitOffset(condition)
+	return max(offset, 0), max(size, 0)
+}
+
+func limitOffset(condition map[string]interface{}) (int, int) {
+	condPageSize := 20
+	if _, isPS := condition[CondPageSize]; isPS {
+		var isOk bool
+		condPageSize, isOk = condition[CondPageSize].(int)
+		if !isOk {
+			condPageSize, _ = strconv.Atoi(condition[CondPageSize].(string))
+		}
+	}
 	if _, isLB := condition[CondLimitBegin]; isLB {
 		condLimitBegin, isOk := condition[CondLimitBegin].(int)
 		if !isOk {
 			condLimitBegin, _ = strconv.Atoi(condition[CondLimitBegin].(string))
 		}
-		condPageSize := 20
-		if _, isPS := condition[CondPageSize]; isPS {
-			condPageSize, isOk = condition[CondPageSize].(int)
-			if !isOk {
-				condPageSize, _ = strconv.Atoi(condition[CondPageSize].(string))
-			}
-		}
-		s = fmt.Sprintf("%s LIMIT %d,%d", s, condLimitBegin, condPageSize)
+		return condLimitBegin, condPageSize
 	} else if _, isPI := condition[CondPageIndex]; isPI {
 		condPageIndex, isOk := condition[CondPageIndex].(int)
 		if !isOk {
 			condPageIndex, _ = strconv.Atoi(condition[CondPageIndex].(string))
 		}
-		condPageSize := 20
-		if _, isPS := condition[CondPageSize]; isPS {
-			condPageSize, isOk = condition[CondPageSize].(int)
-			if !isOk {
-				condPageSize, _ = strconv.Atoi(condition[CondPageSize].(string))
-			}
-		}
-		s = fmt.Sprintf("%s LIMIT %d,%d", s, (condPageIndex-1)*condPageSize, condPageSize)
-	} else if _, isPS := condition[CondPageSize]; isPS {
-		condPageSize, isOk := condition[CondPageSize].(int)
-		if !isOk {
-			condPageSize, _ = strconv.Atoi(condition[CondPageSize].(string))
-		}
-		s = fmt.Sprintf("%s LIMIT 0,%d", s, condPageSize)
-	} else {
-		s = fmt.Sprintf("%s LIMIT 0,20", s)
+		return (condPageIndex - 1) * condPageSize, condPageSize
 	}
-	return s
+	return 0, condPageSize
 }
 
 // AddCondTimeMust 为 sql 增加 created_at 字段的时间之间条件
@@ -342,7 +611,8 @@ func (that *BaseDao) AddCondTimeMust(condition map[string]interface{}, sql strin
 	return that.AddCondTime(condition, sql, params, "", alias)
 }
 
-// AddCondTime 为 sql 增加 指定 tableField 字段的时间之间条件
+// AddCondTime 为 sql 增加 指定 tableField 字段的时间之间条件，条件值原样作为参数；
+// 标准方法在此之前按创建时间字段的精度与时区转换条件值
 func (that *BaseDao) AddCondTime(condition map[string]interface{}, sql string, params []any, tableField, alias string) (string, []any) {
 	if "" == tableField {
 		tableField = "created_at"
@@ -434,3 +704,13 @@ func (that *BaseDao) AddCondFieldSQLIn(whereSQL, fieldName string, params []any,
 	params = append(params, val)
 	return whereSQL, params
 }
+
+// callModelMethod 反射调用 model 的方法，args 按顺序作为参数
+func callModelMethod(modVal reflect.Value, name string, args ...interface{}) []reflect.Value {
+	method := modVal.MethodByName(name)
+	params := make([]reflect.Value, method.Type().NumIn())
+	for i := range args {
+		params[i] = reflect.ValueOf(args[i])
+	}
+	return method.Call(params)
+}
@@ -0,0 +1,370 @@
+package at
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// modelMeta model 生成 SQL 需要的信息
+type modelMeta struct {
+	tableName          string
+	alias              string
+	pkField            string
+	pkFields           []string // 联合主键时有多个，pkField 为第一个
+	listTableFields    []string
+	mapModelTableField map[string]TableField
+	modType            reflect.Type
+}
+
+// getModelMeta 读取 model 的表名、别名、主键与字段映射，主键优先使用 pk tag，model 未实现 GetDefaultAlias、GetPKTableField 时
+// 别名默认为 a，主键默认为第一个表字段
+func getModelMeta(modPointer interface{}) *modelMeta {
+	modVal := reflect.ValueOf(modPointer)
+	meta := &modelMeta{alias: "a", modType: reflect.Indirect(modVal).Type()}
+	meta.listTableFields, meta.mapModelTableField = (&BaseModel{}).ModelToTableFields(modPointer)
+	meta.tableName = callModelMethod(modVal, "GetTableName")[0].String()
+	if modVal.MethodByName("GetDefaultAlias").IsValid() {
+		meta.alias = callModelMethod(modVal, "GetDefaultAlias")[0].String()
+	}
+	if meta.pkFields = pkTableFields(meta.listTableFields, meta.mapModelTableField); 0 != len(meta.pkFields) {
+		meta.pkField = meta.pkFields[0]
+	} else if modVal.MethodByName("GetPKTableField").IsValid() {
+		meta.pkField = callModelMethod(modVal, "GetPKTableField")[0].String()
+	} else if 0 != len(meta.listTableFields) {
+		meta.pkField = meta.listTableFields[0]
+	}
+	if 0 == len(meta.pkFields) {
+		meta.pkFields = []string{meta.pkField}
+	}
+	return meta
+}
+
+// fieldByTable 根据表字段名找到 model 字段
+func (that *modelMeta) fieldByTable(tableField string) (string, TableField, bool) {
+	for k, v := range that.mapModelTableField {
+		if tableField == v.FieldNameByTable {
+			return k, v, true
+		}
+	}
+	return "", TableField{}, false
+}
+
+// pkModelField 主键对应的 model 字段名
+func (that *modelMeta) pkModelField() string {
+	name, _, _ := that.fieldByTable(that.pkField)
+	return name
+}
+
+// modelFieldByKey 条件名支持 model 字段名、tag json、tag table
+func (that *modelMeta) modelFieldByKey(k string) (string, TableField, bool) {
+	for k2, v2 := range that.mapModelTableField {
+		if k == k2 || k == v2.FieldNameByTable || k == v2.FieldNameByJSON {
+			return k2, v2, true
+		}
+	}
+	return "", TableField{}, false
+}
+
+// createTimeField 创建时间的表字段，CondBeginTime、CondEndTime 以此字段作为条件，没有时为 created_at
+func (that *modelMeta) createTimeField() string {
+	for _, v := range that.mapModelTableField {
+		if PropertyCreateTime == v.FieldProperty {
+			return v.FieldNameByTable
+		}
+	}
+	return "created_at"
+}
+
+// intTimestamp model 字段 name 是否存储 unix 时间戳
+func (that *modelMeta) intTimestamp(name string, tf TableField) bool {
+	return isIntTimestamp(tf, reflect.New(that.modType).Elem().FieldByName(name))
+}
+
+// timeCondition 时间条件按字段的精度与时区转为参数，有转换时返回新的 condition：
+// CondBeginTime、CondEndTime 按创建时间字段转换；有 tz、precision tag 的字段，time.Time 条件值按该字段转换
+func (that *modelMeta) timeCondition(condition map[string]interface{}) (map[string]interface{}, error) {
+	var cond map[string]interface{}
+	set := func(k string, v interface{}) {
+		if nil == cond {
+			cond = make(map[string]interface{}, len(condition))
+			for k2, v2 := range condition {
+				cond[k2] = v2
+			}
+		}
+		cond[k] = v
+	}
+	createName, createField, hasCreate := that.fieldByTable(that.createTimeField())
+	for k, v := range condition {
+		if CondBeginTime == k || CondEndTime == k {
+			if !hasCreate {
+				continue
+			}
+			tm, err := condTime(v, createField)
+			if nil != err {
+				return nil, errors.New(fmt.Sprintf("error:%s %s", k, err.Error()))
+			}
+			p, err := timestampParam(createField, that.intTimestamp(createName, createField), tm)
+			if nil != err {
+				return nil, err
+			}
+			set(k, p)
+			continue
+		}
+		tm, isOk := derefValue(v).(time.Time)
+		if !isOk {
+			continue
+		}
+		name, tf, isOk := that.modelFieldByKey(that.conditionField(k))
+		if !isOk || ("" == tf.FieldTZ && "" == tf.FieldPrecision) {
+			continue
+		}
+		p, err := timestampParam(tf, that.intTimestamp(name, tf), tm)
+		if nil != err {
+			return nil, err
+		}
+		set(k, p)
+	}
+	if nil == cond {
+		return condition, nil
+	}
+	return cond, nil
+}
+
+// conditionField 去掉条件名的 !、操作符与别名前缀
+func (that *modelMeta) conditionField(k string) string {
+	k = strings.TrimPrefix(k, "!")
+	if strings.HasPrefix(k, "?") && 3 <= len(k) {
+		k = k[3:]
+	}
+	if "" != that.alias {
+		k = strings.TrimPrefix(k, that.alias+".")
+	}
+	return k
+}
+
+// buildWhere 按 condition 生成 WHERE 语句，包括字段条件、时间条件与租户条件
+func (that *BaseDao) buildWhere(ctx context.Context, meta *modelMeta, condition map[string]interface{}) (string, []interface{}, error) {
+	condition, err := meta.timeCondition(condition)
+	if nil != err {
+		return "", nil, err
+	}
+	where, params := (&BaseModel{}).GetModelFieldCondition(condition, meta.alias, meta.mapModelTableField)
+	where, params = that.AddCondTime(condition, where, params, meta.createTimeField(), meta.alias)
+	tf, tenantID, scoped, err := tenantScope(ctx, meta)
+	if nil != err {
+		return where, params, err
+	}
+	if scoped {
+		where, params = that.AddCondTenant(where, params, meta.alias, tf.FieldNameByTable, tenantID)
+	}
+	return where, params, nil
+}
+
+// buildOrder 按 condition 生成 ORDER BY，condition 中的排序字段转为表字段，未指定时按主键降序；
+// 多个排序字段使用相同的排序方向，与 MemoryStorage、分表合并排序一致
+func (that *BaseDao) buildOrder(meta *modelMeta, condition map[string]interface{}) string {
+	v, isOk := condition[CondORDERField]
+	if !isOk {
+		orders := make([]string, len(meta.pkFields))
+		for i, f := range meta.pkFields {
+			orders[i] = fmt.Sprintf("%s.%s DESC", meta.alias, f)
+		}
+		return fmt.Sprintf(" ORDER BY %s", strings.Join(orders, ","))
+	}
+	orderBy := "DESC"
+	if "1" == condition[CondORDERType] || 1 == condition[CondORDERType] {
+		orderBy = "ASC"
+	}
+	fields := strings.Split(fmt.Sprint(v), ",")
+	orders := make([]string, len(fields))
+	for i, f := range fields {
+		f = strings.TrimSpace(f)
+		if _, tf, isOk := meta.modelFieldByKey(f); isOk {
+			f = tf.FieldNameByTable
+		}
+		orders[i] = fmt.Sprintf("%s.%s %s", meta.alias, f, orderBy)
+	}
+	return fmt.Sprintf(" ORDER BY %s", strings.Join(orders, ","))
+}
+
+// buildSelect 生成查询全部表字段的 SELECT 语句，包括条件与排序，不包括 LIMIT
+func (that *BaseDao) buildSelect(ctx context.Context, meta *modelMeta, condition map[string]interface{}) (string, []interface{}, error) {
+	fieldStr, _ := (&BaseModel{}).GetModelFieldsToFieldStr(meta.alias, meta.listTableFields)
+	where, params, err := that.buildWhere(ctx, meta, condition)
+	s := fmt.Sprintf("SELECT %s FROM %s AS %s %s", fieldStr, meta.tableName, meta.alias, where)
+	return fmt.Sprintf("%s%s", s, that.buildOrder(meta, condition)), params, err
+}
+
+// FindByIDContext	标准：根据主键查询一条数据，结果装入 modPointer
+// ctx context.Context	上下文
+// q Querier	*sql.DB 或 *sql.Tx
+// modPointer interface{}	model 的指针，主键需有值
+// bool	是否找到
+// error	err 不为 nil 时失败
+func (that *BaseDao) FindByIDContext(ctx context.Context, q Querier, modPointer interface{}) (bool, error) {
+	meta := getModelMeta(modPointer)
+	pkValues := meta.pkValues(reflect.ValueOf(modPointer).Elem())
+	cacheKey, ttl, cached := queryCacheKey(ctx, q, modPointer, meta.tableName, OpFindByID, pkValues)
+	if cached && cacheGet(cacheKey, modPointer) {
+		return true, nil
+	}
+	tableName, err := shardTable(meta.tableName, modPointer)
+	if nil != err {
+		return false, err
+	}
+	meta.tableName = tableName
+	fieldStr, _ := (&BaseModel{}).GetModelFieldsToFieldStr(meta.alias, meta.listTableFields)
+	where, params := fmt.Sprintf("WHERE %s ", meta.pkWhere(meta.alias)), pkValues
+	tf, tenantID, scoped, err := tenantScope(ctx, meta)
+	if nil != err {
+		return false, err
+	}
+	if scoped {
+		where, params = that.AddCondTenant(where, params, meta.alias, tf.FieldNameByTable, tenantID)
+	}
+	s := fmt.Sprintf("SELECT %s FROM %s AS %s %sLIMIT 1", fieldStr, meta.tableName, meta.alias, where)
+
+	list, err := that.queryModels(ctx, q, meta, OpFindByID, s, params, modPointer)
+	if nil != err {
+		return false, err
+	}
+	if 0 == list.Len() {
+		return false, nil
+	}
+	reflect.ValueOf(modPointer).Elem().Set(list.Index(0).Elem())
+	if cached {
+		cacheSet(cacheKey, modPointer, ttl)
+	}
+	return true, nil
+}
+
+// FindByID	标准：根据主键查询一条数据，见 FindByIDContext
+func (that *BaseDao) FindByID(q Querier, modPointer interface{}) (bool, error) {
+	return that.FindByIDContext(context.Background(), q, modPointer)
+}
+
+// FindListContext	标准：按 condition 查询列表，支持字段条件、时间条件、排序与分页（见 Constanst.go），
+// 分表涉及多个物理表时分别查询后合并排序与分页（排序字段末尾追加主键）
+// ctx context.Context	上下文
+// q Querier	*sql.DB 或 *sql.Tx
+// condition map[string]interface{}	查询条件
+// modPointer interface{}	model 的指针，用于取得表信息
+// listPointer interface{}	结果切片的指针，如 *[]*User
+// error	err 不为 nil 时失败
+func (that *BaseDao) FindListContext(ctx context.Context, q Querier, condition map[string]interface{}, modPointer interface{}, listPointer interface{}) error {
+	meta := getModelMeta(modPointer)
+	cacheKey, ttl, cached := queryCacheKey(ctx, q, modPointer, meta.tableName, OpFindList, condition)
+	if cached && cacheGet(cacheKey, listPointer) {
+		return nil
+	}
+	metas, err := shardMetas(meta, modPointer, condition)
+	if nil != err {
+		return err
+	}
+	var list reflect.Value
+	if 1 != len(metas) {
+		// 分表查询涉及多个物理表，合并排序后分页
+		list, err = that.findListSharded(ctx, q, metas, condition, modPointer)
+	} else {
+		s, params, err2 := that.buildSelect(ctx, metas[0], condition)
+		if nil != err2 {
+			return err2
+		}
+		s = that.AddLimit(condition, s)
+		list, err = that.queryModels(ctx, q, metas[0], OpFindList, s, params, modPointer)
+	}
+	if nil != err {
+		return err
+	}
+	reflect.ValueOf(listPointer).Elem().Set(list)
+	if cached {
+		cacheSet(cacheKey, listPointer, ttl)
+	}
+	return nil
+}
+
+// FindList	标准：按 condition 查询列表，见 FindListContext
+func (that *BaseDao) FindList(q Querier, condition map[string]interface{}, modPointer interface{}, listPointer interface{}) error {
+	return that.FindListContext(context.Background(), q, condition, modPointer, listPointer)
+}
+
+// CountContext	标准：按 condition 统计数量，忽略排序与分页，分表时合计每个物理表的数量
+// ctx context.Context	上下文
+// q Querier	*sql.DB 或 *sql.Tx
+// condition map[string]interface{}	查询条件
+// modPointer interface{}	model 的指针，用于取得表信息
+// int64	数量
+// error	err 不为 nil 时失败
+func (that *BaseDao) CountContext(ctx context.Context, q Querier, condition map[string]interface{}, modPointer interface{}) (int64, error) {
+	meta := getModelMeta(modPointer)
+	cacheKey, ttl, cached := queryCacheKey(ctx, q, modPointer, meta.tableName, OpCount, condition)
+	var total int64
+	if cached && cacheGet(cacheKey, &total) {
+		return total, nil
+	}
+	metas, err := shardMetas(meta, modPointer, condition)
+	if nil != err {
+		return 0, err
+	}
+	// 分表时合计每个物理表的数量
+	for _, meta := range metas {
+		where, params, err := that.buildWhere(ctx, meta, condition)
+		if nil != err {
+			return 0, err
+		}
+		s := fmt.Sprintf("SELECT COUNT(*) FROM %s AS %s %s", meta.tableName, meta.alias, where)
+		var count int64
+		if err = that.queryRowScanContext(ctx, q, meta.tableName, OpCount, s, params, &count); nil != err {
+			return 0, err
+		}
+		total += count
+	}
+	if cached {
+		cacheSet(cacheKey, total, ttl)
+	}
+	return total, nil
+}
+
+// Count	标准：按 condition 统计数量，见 CountContext
+func (that *BaseDao) Count(q Querier, condition map[string]interface{}, modPointer interface{}) (int64, error) {
+	return that.CountContext(context.Background(), q, condition, modPointer)
+}
+
+// queryModels 执行查询并将每一行按列名装入新的 model，返回 []*Model 的 reflect.Value
+func (that *BaseDao) queryModels(ctx context.Context, q Querier, meta *modelMeta, op, s string, params []interface{}, modPointer interface{}) (reflect.Value, error) {
+	modType := reflect.TypeOf(modPointer)
+	list := reflect.MakeSlice(reflect.SliceOf(modType), 0, 0)
+
+	event := that.beginEvent(ctx, meta.tableName, op, s, params)
+	rows, err := q.QueryContext(ctx, s, params...)
+	if nil != err {
+		err = wrapDaoError(meta.tableName, op, err)
+		that.endEvent(ctx, event, -1, err)
+		return list, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if nil != err {
+		that.endEvent(ctx, event, -1, err)
+		return list, err
+	}
+	indexes := columnIndexes(modType.Elem(), columns)
+	values := make([]interface{}, len(columns))
+	for rows.Next() {
+		m := reflect.New(modType.Elem())
+		setScanTargets(values, m.Elem(), indexes)
+		if err = rows.Scan(values...); nil != err {
+			that.endEvent(ctx, event, int64(list.Len()), err)
+			return list, err
+		}
+		list = reflect.Append(list, m)
+	}
+	err = rows.Err()
+	that.endEvent(ctx, event, int64(list.Len()), err)
+	return list, err
+}
@@ -0,0 +1,112 @@
+package at
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+)
+
+func TestTransactionBeginError(t *testing.T) {
+	f, db := newFakeDB()
+	defer db.Close()
+	f.failBegin = errors.New("begin failed")
+	called := false
+	err := GetInstanceByBaseDao().TransactionContext(context.Background(), db, nil, func(tx *sql.Tx) error {
+		called = true
+		return nil
+	})
+	if !errors.Is(err, f.failBegin) {
+		t.Fatalf("err = %v, want begin error", err)
+	}
+	if called {
+		t.Error("callBack called without a transaction")
+	}
+}
+
+func TestTransactionCommitError(t *testing.T) {
+	f, db := newFakeDB()
+	defer db.Close()
+	f.failCommit = errors.New("commit failed")
+	err := GetInstanceByBaseDao().TransactionContext(context.Background(), db, nil, func(tx *sql.Tx) error {
+		return nil
+	})
+	if !errors.Is(err, f.failCommit) {
+		t.Fatalf("err = %v, want commit error", err)
+	}
+}
+
+func TestTransactionRollbackErrorJoined(t *testing.T) {
+	f, db := newFakeDB()
+	defer db.Close()
+	f.failRollback = errors.New("rollback failed")
+	errCallBack := errors.New("callBack failed")
+	err := GetInstanceByBaseDao().TransactionContext(context.Background(), db, nil, func(tx *sql.Tx) error {
+		return errCallBack
+	})
+	if !errors.Is(err, errCallBack) || !errors.Is(err, f.failRollback) {
+		t.Fatalf("err = %v, want both callBack and rollback errors", err)
+	}
+	if 1 != f.rollbacks || 0 != f.commits {
+		t.Errorf("rollbacks = %d commits = %d", f.rollbacks, f.commits)
+	}
+}
+
+func TestTransactionRePanic(t *testing.T) {
+	f, db := newFakeDB()
+	defer db.Close()
+	type panicValue struct{ msg string }
+	want := &panicValue{msg: "boom"}
+	defer func() {
+		if p := recover(); p != want {
+			t.Fatalf("recovered %v, want the original panic value", p)
+		}
+		if 1 != f.rollbacks || 0 != f.commits {
+			t.Errorf("rollbacks = %d commits = %d", f.rollbacks, f.commits)
+		}
+	}()
+	_ = GetInstanceByBaseDao().TransactionContext(context.Background(), db, nil, func(tx *sql.Tx) error {
+		panic(want)
+	})
+	t.Fatal("panic not propagated")
+}
+
+func TestTransactionOptionsReadOnly(t *testing.T) {
+	f, db := newFakeDB()
+	defer db.Close()
+	SetDb(db)
+	defer SetDb(nil)
+	err := GetInstanceByBaseService().TransactionOptions(context.Background(), &sql.TxOptions{ReadOnly: true}, func(ctx context.Context) error {
+		return nil
+	})
+	if nil != err {
+		t.Fatal(err)
+	}
+	if 1 != len(f.beginOpts) || !f.beginOpts[0].ReadOnly {
+		t.Fatalf("begin options = %+v, want read only", f.beginOpts)
+	}
+}
+
+func TestTransactionJoinsContextTx(t *testing.T) {
+	f, db := newFakeDB()
+	defer db.Close()
+	SetDb(db)
+	defer SetDb(nil)
+	service := GetInstanceByBaseService()
+	err := service.TransactionContext(context.Background(), func(ctx context.Context) error {
+		outer, _ := TxFromContext(ctx)
+		return service.TransactionContext(ctx, func(ctx context.Context) error {
+			if inner, _ := TxFromContext(ctx); inner != outer {
+				t.Error("nested TransactionContext began a new transaction")
+			}
+			_, err := service.AddModelContext(ctx, &testPerson{Name: "tom"})
+			return err
+		})
+	})
+	if nil != err {
+		t.Fatal(err)
+	}
+	if 1 != len(f.beginOpts) || 1 != f.commits {
+		t.Errorf("begins = %d commits = %d, want one transaction", len(f.beginOpts), f.commits)
+	}
+}
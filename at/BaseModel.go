@@ -16,6 +16,14 @@ type TableField struct {
 	FieldNameByJSON  string
 	FieldProperty    FieldProperty // thing、search、imgurl
 	FieldType        string
+	FieldCodec       string // codec tag，见 Codec
+	FieldEncrypt     bool   // 有 encrypt tag，见 Encrypt.go
+	FieldBlindIndex  string // encrypt tag 的值，盲索引列
+	FieldIDGen       string // idgen tag，主键生成器，见 IDGen.go
+	FieldPK          bool   // 有 pk tag，见 PK.go
+	FieldPKAuto      bool   // pk:"auto"，数据库自增主键
+	FieldPrecision   string // precision tag，创建时间、最后更新的精度，见 Clock.go
+	FieldTZ          string // tz tag，创建时间、最后更新的时区
 }
 
 type FieldProperty uint
@@ -28,6 +36,7 @@ const (
 	PropertyCreateTime
 	PropertyUpdateTime
 	PropertyDeleteTime
+	PropertyTenant
 )
 
 // GetModelFieldsToFieldStr	将字段数组拼成 alias.Field,...
@@ -50,7 +59,7 @@ func (*BaseModel) GetModelFieldsToFieldStr(alias string, fields []string) (field
 	return
 }
 
-// GetModelFieldsNotPkToFieldStr	将字段数组拼成 alias.Field,...	不包括主键
+// GetModelFieldsNotPkToFieldStr	将字段数组拼成 alias.Field,...	不包括数据库自增的主键，见 PK.go
 // alias string	查询表的别名
 // fields []string	table字段数组
 // mapModelTableField map[string]TableField  表字段与 Model 字段映射
@@ -58,9 +67,9 @@ func (*BaseModel) GetModelFieldsToFieldStr(alias string, fields []string) (field
 // length int	字段数量
 func (*BaseModel) GetModelFieldsByInsertToFieldStr(alias string, fields []string, mapModelTableField map[string]TableField) (fieldStr, values string, length int) {
 	length = len(fields) - 1
-	now := time.Now().Unix()
+	pks := pkTableFields(fields, mapModelTableField)
 	for inx, v := range fields {
-		if 0 == inx {
+		if insertSkipField(inx, v, pks, mapModelTableField) {
 			continue
 		}
 
@@ -70,21 +79,12 @@ func (*BaseModel) GetModelFieldsByInsertToFieldStr(alias string, fields []string
 			if v != field.FieldNameByTable {
 				continue
 			}
-			if PropertyUpdateTime == field.FieldProperty || PropertyCreateTime == field.FieldProperty {
-				// 判断字段类型，date、datetime 等时间类型使用 NOW()，int 类型值为 time.Now().Unix()
-				if strings.Contains(field.FieldType, "INT") {
-					values = fmt.Sprintf("%s%d", values, now)
-				} else {
-					values = fmt.Sprintf("%sNOW()", values)
-				}
-				break
-			}
 			if PropertyDeleteTime == field.FieldProperty {
 				// 兼容 gorm 以删除时间 非 NULL 作为判断是否删除，跳过
 				isContinue = false
 				break
 			}
-			// 非创建和最后更新字段
+			// 创建时间和最后更新也作为参数，值由 Clock 提供
 			values = fmt.Sprintf("%s?", values)
 		}
 
@@ -102,10 +102,13 @@ func (*BaseModel) GetModelFieldsByInsertToFieldStr(alias string, fields []string
 			values = fmt.Sprintf("%s,", values)
 		}
 	}
+	// 主键不是第一个字段时，最后一个字段可能被跳过
+	fieldStr = strings.TrimSuffix(fieldStr, ",")
+	values = strings.TrimSuffix(values, ",")
 	return
 }
 
-// GetModelFieldsByUpdateToFieldStr	将字段数组拼成更新语句	alias.Field = ?,...,最后更新 = 【NOW()|time.Now().Unix()】
+// GetModelFieldsByUpdateToFieldStr	将字段数组拼成更新语句	alias.Field = ?,...	不包括主键与创建时间，最后更新的值由 Clock 提供
 // alias string	查询表的别名
 // fields []string	table字段数组
 // mapModelTableField map[string]TableField  表字段与 Model 字段映射
@@ -113,10 +116,11 @@ func (*BaseModel) GetModelFieldsByInsertToFieldStr(alias string, fields []string
 // length int	字段数量
 func (*BaseModel) GetModelFieldsByUpdateToFieldStr(alias string, fields []string, mapModelTableField map[string]TableField) (fieldStr string, length int) {
 	length = len(fields) - 1
+	pks := pkTableFields(fields, mapModelTableField)
 	// 遍历字段
 	for inx, v := range fields {
 		isAppend := true
-		if 0 == inx {
+		if isPKTableField(inx, v, pks) {
 			continue
 		}
 		// 遍历 fieldMap 找到字段对应 field，要区分字段类型，有的字段赋值默认值
@@ -125,25 +129,7 @@ func (*BaseModel) GetModelFieldsByUpdateToFieldStr(alias string, fields []string
 				continue
 			}
 			// 找到 fields 字段对应的 field，去字段类型进行区分
-			if PropertyUpdateTime == field.FieldProperty {
-				// 最后更新，判断字段类型，date、datetime 等时间类型使用 NOW()，int 类型值为 time.Now().Unix()
-				if strings.Contains(field.FieldType, "INT") {
-					// 非数据库标准的时间类型
-					if "" != alias {
-						fieldStr = fmt.Sprintf("%s%s.%s = %d", fieldStr, alias, v, time.Now().Unix())
-					} else {
-						fieldStr = fmt.Sprintf("%s%s = %d", fieldStr, v, time.Now().Unix())
-					}
-					break
-				}
-				// 数据库标准时间类型用 NOW
-				if "" != alias {
-					fieldStr = fmt.Sprintf("%s%s.%s = NOW()", fieldStr, alias, v)
-				} else {
-					fieldStr = fmt.Sprintf("%s%s = NOW()", fieldStr, v)
-				}
-				break
-			} else if PropertyCreateTime == field.FieldProperty {
+			if PropertyCreateTime == field.FieldProperty {
 				// 更新语句不需要 创建时间
 				isAppend = false
 				break
@@ -154,7 +140,7 @@ func (*BaseModel) GetModelFieldsByUpdateToFieldStr(alias string, fields []string
 			//	isAppend = false
 			//	break
 			//}
-			// 其它字段
+			// 其它字段，最后更新的值由 Clock 提供
 			if "" != alias {
 				fieldStr = fmt.Sprintf("%s%s.%s = ?", fieldStr, alias, v)
 			} else {
@@ -166,6 +152,7 @@ func (*BaseModel) GetModelFieldsByUpdateToFieldStr(alias string, fields []string
 			fieldStr = fmt.Sprintf("%s,", fieldStr)
 		}
 	}
+	fieldStr = strings.TrimSuffix(fieldStr, ",")
 	return
 }
 
@@ -187,8 +174,8 @@ func (instance *BaseModel) ModelToTableFields(model interface{}) (listTableField
 	} else {
 		ty = reflect.TypeOf(model)
 	}
-	for i := 0; i < ty.NumField(); i++ {
-		t := ty.Field(i)
+	// 匿名嵌入结构体的表字段展开，字段名可通过 FieldByName 直接访问
+	for _, t := range modelTableStructFields(ty) {
 		tableTag := t.Tag.Get("table")
 		commentTag := t.Tag.Get("comment")
 		jsonTag := t.Tag.Get("json")
@@ -202,6 +189,18 @@ func (instance *BaseModel) ModelToTableFields(model interface{}) (listTableField
 				FieldType:        typeTag,
 				FieldNameByModel: t.Name,
 				FieldProperty:    PropertyNull,
+				FieldCodec:       t.Tag.Get("codec"),
+				FieldIDGen:       t.Tag.Get(idGenTag),
+				FieldPrecision:   normalizePrecision(t.Tag.Get(precisionTag)),
+				FieldTZ:          t.Tag.Get(tzTag),
+			}
+			if pk, isOk := t.Tag.Lookup(pkTag); isOk {
+				tf.FieldPK = true
+				tf.FieldPKAuto = PKAuto == pk
+			}
+			if bidx, isOk := t.Tag.Lookup(encryptTag); isOk {
+				tf.FieldEncrypt = true
+				tf.FieldBlindIndex = bidx
 			}
 			if strings.HasPrefix(commentTag, "thing") {
 				tf.FieldProperty = PropertyThing
@@ -212,6 +211,9 @@ func (instance *BaseModel) ModelToTableFields(model interface{}) (listTableField
 			if strings.HasPrefix(commentTag, "imgurl") {
 				tf.FieldProperty = PropertyImgUrl
 			}
+			if _, isOk := t.Tag.Lookup(tenantTag); isOk || ("" != tenantColumn && tenantColumn == tableTag) {
+				tf.FieldProperty = PropertyTenant
+			}
 			if "创建时间" == commentTag || "create_date" == tableTag {
 				tf.FieldProperty = PropertyCreateTime
 			}
@@ -288,7 +290,7 @@ func (instance *BaseModel) GetModelFieldCondition(condition map[string]interface
 
 	isConditionAlias := false
 	for k, _ := range condition {
-		if strings.HasPrefix(k, alias) {
+		if "" != alias && strings.HasPrefix(strings.TrimLeft(k, "!"), alias+".") {
 			isConditionAlias = true
 			break
 		}
@@ -312,13 +314,13 @@ func (instance *BaseModel) GetModelFieldCondition(condition map[string]interface
 
 		if isConditionAlias {
 			// 用了别名，又没有以别名开头，跳过
-			if !strings.HasPrefix(k, alias) {
+			if !strings.HasPrefix(k, alias+".") {
 				continue
 			}
 		}
 
 		// 以别名开头
-		if strings.HasPrefix(k, alias) {
+		if "" != alias && strings.HasPrefix(k, alias+".") {
 			k = k[len(alias)+1:]
 		}
 
@@ -330,6 +332,11 @@ func (instance *BaseModel) GetModelFieldCondition(condition map[string]interface
 				if nil != v && "" != v {
 					fieldName = v2.FieldNameByTable
 					fieldProperty = v2.FieldProperty
+					// 加密字段的等于、不等于条件改为盲索引列
+					if "" != v2.FieldBlindIndex && "" == operator {
+						fieldName = v2.FieldBlindIndex
+						v = blindIndexValue{value: v}
+					}
 				}
 				break
 			}
@@ -400,6 +407,8 @@ func (instance *BaseModel) GetModelFieldCondition(condition map[string]interface
 }
 
 // SetModelInstanceToListAddr	Model有值参数按顺序存入切片
+// 按 table tag 字段的顺序（与 ModelToTableFields 一致）装入，支持 time.Time、指针、sql.Null* 与匿名嵌入结构体，
+// 按列名装入见 SetModelInstanceToListAddrByColumns
 // values []interface{}	用于装指针的切片
 // begin int	装入起始位置
 // toPointer interface{}	Model指针
@@ -411,31 +420,16 @@ func (*BaseModel) SetModelInstanceToListAddr(values []interface{}, begin int, to
 		return
 	}
 	elem := refInstance.Elem()
-	dataIndex := 0
-	for i := 0; i < elem.NumField(); i++ {
+	for dataIndex, t := range modelTableStructFields(elem.Type()) {
 		if dataIndex == length {
 			break
 		}
-		field := elem.Field(i)
-		fKind := field.Kind()
-		//if reflect.Ptr == fKind {
-		//	continue
-		//}
-		if reflect.Struct == fKind {
-			// 不支持嵌套结构体导入
-			continue
-		}
-		fName := field.Type().Name()
-		if "BaseModel" == fName {
-			//	忽略 BaseModel
-			continue
-		}
-		values[dataIndex+begin] = field.Addr().Interface()
-		dataIndex++
+		values[dataIndex+begin] = scanTarget(elem.FieldByIndex(t.Index), newScanField(t))
 	}
 }
 
-// GetModelTableFieldValueList	分拣出 INSERT 和 UPDATE 语句的参数，自动忽略创建时间和最后更新时间
+// GetModelTableFieldValueList	分拣出 INSERT 和 UPDATE 语句的参数，创建时间和最后更新为 Clock 的当前时间（见 Clock.go），有 codec tag 的字段由 codec 编码，
+// 有 encrypt tag 的字段加密，盲索引列由加密字段计算
 // alias string	查询表的别名
 // fieldSQL string	SQL语句
 // tableFields map[string]TableField	表字段与Model字段映射
@@ -446,6 +440,8 @@ func (*BaseModel) GetModelTableFieldValueList(alias string, fieldSQL string, tab
 	if reflect.Ptr == mValue.Kind() {
 		mValue = mValue.Elem()
 	}
+	// 同一条语句的时间字段使用同一时间
+	now := nowTime()
 	arrStr := strings.Split(fieldSQL, ",")
 	if strings.Contains(fieldSQL, "=") {
 		// is update sql
@@ -466,12 +462,32 @@ func (*BaseModel) GetModelTableFieldValueList(alias string, fieldSQL string, tab
 				continue
 			}
 			if PropertyUpdateTime == v.FieldProperty || PropertyCreateTime == v.FieldProperty {
-				// 跳过创建时间和最后更新
-				continue
+				fv := mValue.FieldByName(k)
+				list = append(list, timestampValue{field: v, intValue: isIntTimestamp(v, fv), now: now})
+				// 与 MemoryStorage 相同，写入的时间写回 model，时区错误由 timestampValue 在执行时返回
+				if fv.CanSet() {
+					_ = setTimestamp(fv, v, now)
+				}
+				break
 			}
 			fie := mValue.FieldByName(k)
 			fieType := fie.Type()
 			va := mValue.FieldByName(k).Interface()
+			if v.FieldEncrypt {
+				// 加密字段，加密错误在执行 SQL 时返回
+				list = append(list, encryptValue{codec: v.FieldCodec, value: va})
+				break
+			}
+			if source, isOk := blindIndexSource(v.FieldNameByTable, tableFields); isOk {
+				// 盲索引列由加密字段的明文计算
+				list = append(list, blindIndexValue{value: mValue.FieldByName(source).Interface()})
+				break
+			}
+			if "" != v.FieldCodec {
+				// 由 codec 编码，编码错误在执行 SQL 时返回
+				list = append(list, codecValue{name: v.FieldCodec, value: va})
+				break
+			}
 			if nil != va {
 				if "Time" == fieType.Name() {
 					tm, ok := va.(time.Time)
@@ -1,7 +1,11 @@
 package at
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"reflect"
+	"strings"
 )
 
 type BaseService struct {
@@ -19,58 +23,332 @@ func SetDb(db_ *sql.DB) {
 	db = db_
 }
 
-func (*BaseService) panicRollback(tx *sql.Tx) {
-	err := recover()
-	if nil != err {
-		err := tx.Rollback()
-		if err != nil {
-			return
-		}
-		panic(err)
+// Transaction 在事务中执行 fun，fun 返回 nil 时提交，否则回滚，见 BaseDao.TransactionContext
+// db *sql.DB	数据源
+// fun func(tx *sql.Tx) error	事务中执行的函数
+// error	不为 nil 时事务没有提交，包括开启事务、提交与回滚的错误
+func (that *BaseService) Transaction(db *sql.DB, fun func(tx *sql.Tx) error) error {
+	return GetInstanceByBaseDao().TransactionContext(context.Background(), db, nil, fun)
+}
+
+// AddModel 标准：入库一个Model
+// modPointer interface{}	数据，指针
+// int64	入库的主键值， < 1 为失败
+// error	不为 nil 时失败
+func (that *BaseService) AddModel(modPointer interface{}) (int64, error) {
+	return that.AddModelContext(context.Background(), modPointer)
+}
+
+// AddModelContext 见 AddModel，ctx 用于多租户、审计操作人等
+func (that *BaseService) AddModelContext(ctx context.Context, modPointer interface{}) (int64, error) {
+	return getStorage().AddModel(ctx, modPointer)
+}
+
+// UpdateByID 标准：根据主键修改一条数据Model
+// modPointer interface{}	数据，指针
+// int64	成功修改数量
+// error	不为 nil 时失败
+func (that *BaseService) UpdateByID(modPointer interface{}) (int64, error) {
+	return that.UpdateByIDContext(context.Background(), modPointer)
+}
+
+// UpdateByIDContext 见 UpdateByID，ctx 用于多租户、审计操作人等
+func (that *BaseService) UpdateByIDContext(ctx context.Context, modPointer interface{}) (int64, error) {
+	return getStorage().UpdateByID(ctx, modPointer)
+}
+
+// DeleteByID 标准：根据主键删除一条数据Model
+// modPointer interface{}	数据，指针，只需要主键有值
+// int64	成功删除数量
+// error	不为 nil 时失败
+func (that *BaseService) DeleteByID(modPointer interface{}) (int64, error) {
+	return that.DeleteByIDContext(context.Background(), modPointer)
+}
+
+// DeleteByIDContext 见 DeleteByID，ctx 用于多租户、审计操作人等
+func (that *BaseService) DeleteByIDContext(ctx context.Context, modPointer interface{}) (int64, error) {
+	return getStorage().DeleteByID(ctx, modPointer)
+}
+
+// FindByID 标准：根据主键查询一条数据Model
+// modPointer interface{}	数据，指针，主键需有值，查询结果装入其中
+// bool	是否找到
+// error	不为 nil 时失败
+func (that *BaseService) FindByID(modPointer interface{}) (bool, error) {
+	return that.FindByIDContext(context.Background(), modPointer)
+}
+
+// FindByIDContext 见 FindByID，ctx 用于多租户、审计操作人等
+func (that *BaseService) FindByIDContext(ctx context.Context, modPointer interface{}) (bool, error) {
+	return getStorage().FindByID(ctx, modPointer)
+}
+
+// FindList 标准：按条件查询列表
+// condition map[string]interface{}	查询条件，见 Constanst.go 与 GetModelFieldCondition
+// modPointer interface{}	model 指针，用于取得表信息
+// listPointer interface{}	结果切片的指针，如 *[]*User
+// error	不为 nil 时失败
+func (that *BaseService) FindList(condition map[string]interface{}, modPointer interface{}, listPointer interface{}) error {
+	return that.FindListContext(context.Background(), condition, modPointer, listPointer)
+}
+
+// FindListContext 见 FindList，ctx 用于多租户、审计操作人等
+func (that *BaseService) FindListContext(ctx context.Context, condition map[string]interface{}, modPointer interface{}, listPointer interface{}) error {
+	return getStorage().FindList(ctx, condition, modPointer, listPointer)
+}
+
+// Count 标准：按条件统计数量
+// condition map[string]interface{}	查询条件
+// modPointer interface{}	model 指针，用于取得表信息
+// int64	数量
+// error	不为 nil 时失败
+func (that *BaseService) Count(condition map[string]interface{}, modPointer interface{}) (int64, error) {
+	return that.CountContext(context.Background(), condition, modPointer)
+}
+
+// CountContext 见 Count，ctx 用于多租户、审计操作人等
+func (that *BaseService) CountContext(ctx context.Context, condition map[string]interface{}, modPointer interface{}) (int64, error) {
+	return getStorage().Count(ctx, condition, modPointer)
+}
+
+// TransactionContext 在事务中执行 fun，fun 中使用传入的 ctx 调用 BaseService 的方法即在同一事务中执行，
+// fun 返回错误时回滚。ctx 中已有事务时加入该事务；SetStorage 设置的非数据库存储没有事务，直接执行 fun
+// ctx context.Context	上下文
+// fun func(ctx context.Context) error	事务中执行的函数
+// error	不为 nil 时失败，已回滚
+func (that *BaseService) TransactionContext(ctx context.Context, fun func(ctx context.Context) error) error {
+	return that.TransactionOptions(ctx, nil, fun)
+}
+
+// TransactionOptions 见 TransactionContext，opts 设置隔离级别与只读，加入 ctx 中已有的事务时 opts 不生效
+// ctx context.Context	上下文
+// opts *sql.TxOptions	隔离级别与只读，nil 为数据库默认
+// fun func(ctx context.Context) error	事务中执行的函数
+// error	不为 nil 时失败，已回滚
+func (that *BaseService) TransactionOptions(ctx context.Context, opts *sql.TxOptions, fun func(ctx context.Context) error) error {
+	if _, isOk := TxFromContext(ctx); isOk {
+		return fun(ctx)
+	}
+	if _, isSQL := getStorage().(*sqlStorage); !isSQL {
+		return fun(ctx)
 	}
+	if nil == db {
+		return errNoDb
+	}
+	return GetInstanceByBaseDao().TransactionContext(ctx, db, opts, func(tx *sql.Tx) error {
+		return fun(WithTx(ctx, tx))
+	})
 }
 
-func (that *BaseService) Transaction(db *sql.DB, fun func(tx *sql.Tx) error) error {
-	tx, _ := db.Begin()
-	defer that.panicRollback(tx)
-	err := fun(tx)
+// Get 标准：根据主键查询一条数据Model，没有时返回 ErrNotFound
+// modPointer interface{}	数据，指针，主键需有值，查询结果装入其中
+// error	不为 nil 时失败，没有数据时 errors.Is(err, ErrNotFound)
+func (that *BaseService) Get(modPointer interface{}) error {
+	return that.GetContext(context.Background(), modPointer)
+}
+
+// GetContext 见 Get
+func (that *BaseService) GetContext(ctx context.Context, modPointer interface{}) error {
+	isFound, err := that.FindByIDContext(ctx, modPointer)
 	if nil != err {
-		tx.Rollback()
 		return err
-	} else {
-		tx.Commit()
+	}
+	if !isFound {
+		return newDaoError(getModelMeta(modPointer).tableName, OpFindByID, ErrNotFound)
 	}
 	return nil
 }
 
-// AddModel 标准：入库一个Model
-// modPointer interface{}	数据，指针
-// int64	入库的主键值， < 1 为失败
+// Exists 标准：是否存在符合条件的数据
+// condition map[string]interface{}	查询条件
+// modPointer interface{}	model 指针，用于取得表信息
+// bool	是否存在
 // error	不为 nil 时失败
-func (that *BaseService) AddModel(modPointer interface{}) (int64, error) {
-	tx, _ := db.Begin()
-	defer that.panicRollback(tx)
-	result, err := GetInstanceByBaseDao().AddModel(tx, modPointer)
-	if nil != err || 1 > result {
-		tx.Rollback()
+func (that *BaseService) Exists(condition map[string]interface{}, modPointer interface{}) (bool, error) {
+	return that.ExistsContext(context.Background(), condition, modPointer)
+}
+
+// ExistsContext 见 Exists
+func (that *BaseService) ExistsContext(ctx context.Context, condition map[string]interface{}, modPointer interface{}) (bool, error) {
+	count, err := that.CountContext(ctx, condition, modPointer)
+	return 0 < count, err
+}
+
+// 每次查询的数量
+const listBatchSize = 500
+
+// List 标准：查询全部符合条件的数据，忽略分页条件，内部按每批 500 条查询。
+// 数据库存储使用游标（keyset）分批，排序字段末尾追加主键，每批从上一批最后一行之后读取，不随批数变慢（见 FindListByCursorContext）；
+// 全部批次在一个只读事务中查询（ctx 中已有事务时加入该事务），批次之间是否为同一快照取决于数据库的隔离级别（如 MySQL InnoDB 默认的 REPEATABLE READ）。
+// 分表且条件跨多个物理表时无法使用游标，按页码分批，每批都从头查询每个物理表（见 ShardStrategy），数据量大时查询量为批数的平方
+// condition map[string]interface{}	查询条件，可以包括排序
+// modPointer interface{}	model 指针，用于取得表信息
+// listPointer interface{}	结果切片的指针，如 *[]*User
+// error	不为 nil 时失败
+func (that *BaseService) List(condition map[string]interface{}, modPointer interface{}, listPointer interface{}) error {
+	return that.ListContext(context.Background(), condition, modPointer, listPointer)
+}
+
+// ListContext 见 List
+func (that *BaseService) ListContext(ctx context.Context, condition map[string]interface{}, modPointer interface{}, listPointer interface{}) error {
+	cond := make(map[string]interface{}, len(condition)+2)
+	for k, v := range condition {
+		if CondLimitBegin == k || CondPageIndex == k || CondPageSize == k || CondCursor == k {
+			continue
+		}
+		cond[k] = v
+	}
+	cond[CondPageSize] = listBatchSize
+	storage, isSQL := getStorage().(*sqlStorage)
+	if isSQL {
+		if _, err := singleShardMeta(getModelMeta(modPointer), modPointer, cond); errors.Is(err, ErrShardSpan) {
+			isSQL = false
+		} else if nil != err {
+			return err
+		}
+	}
+	listVal := reflect.ValueOf(listPointer).Elem()
+	return that.TransactionOptions(ctx, &sql.TxOptions{ReadOnly: true}, func(ctx context.Context) error {
+		result := reflect.MakeSlice(listVal.Type(), 0, 0)
+		for pageIndex, next := 1, ""; ; pageIndex++ {
+			page := reflect.New(listVal.Type())
+			hasNext := false
+			if isSQL {
+				cond[CondCursor] = next
+				cp, err := storage.findListByCursor(ctx, cond, modPointer, page.Interface())
+				if nil != err {
+					return err
+				}
+				hasNext, next = cp.HasNext, cp.Next
+			} else {
+				cond[CondPageIndex] = pageIndex
+				if 1 == pageIndex {
+					orderByPK(getModelMeta(modPointer), cond)
+				}
+				if err := that.FindListContext(ctx, cond, modPointer, page.Interface()); nil != err {
+					return err
+				}
+				hasNext = listBatchSize <= page.Elem().Len()
+			}
+			result = reflect.AppendSlice(result, page.Elem())
+			if !hasNext {
+				break
+			}
+		}
+		listVal.Set(result)
+		return nil
+	})
+}
+
+// orderByPK 按页码分批时排序字段末尾追加主键，保证批次之间顺序唯一
+func orderByPK(meta *modelMeta, cond map[string]interface{}) {
+	fields, asc := cursorOrder(meta, cond)
+	cond[CondORDERField] = strings.Join(fields, ",")
+	cond[CondORDERType] = CondORDERTypeDESC
+	if asc {
+		cond[CondORDERType] = CondORDERTypeAES
+	}
+}
+
+// Page 标准：分页查询，返回符合条件的总数
+// condition map[string]interface{}	查询条件，包括 CondPageIndex、CondPageSize
+// modPointer interface{}	model 指针，用于取得表信息
+// listPointer interface{}	当前页结果切片的指针，如 *[]*User
+// int64	符合条件的总数
+// error	不为 nil 时失败
+func (that *BaseService) Page(condition map[string]interface{}, modPointer interface{}, listPointer interface{}) (int64, error) {
+	return that.PageContext(context.Background(), condition, modPointer, listPointer)
+}
+
+// PageContext 见 Page，总数与当前页在一个只读事务中查询（ctx 中已有事务时加入该事务）
+func (that *BaseService) PageContext(ctx context.Context, condition map[string]interface{}, modPointer interface{}, listPointer interface{}) (int64, error) {
+	var total int64
+	err := that.TransactionOptions(ctx, &sql.TxOptions{ReadOnly: true}, func(ctx context.Context) error {
+		var err error
+		if total, err = that.CountContext(ctx, condition, modPointer); nil != err {
+			return err
+		}
+		if 0 == total {
+			listVal := reflect.ValueOf(listPointer).Elem()
+			listVal.Set(reflect.MakeSlice(listVal.Type(), 0, 0))
+			return nil
+		}
+		return that.FindListContext(ctx, condition, modPointer, listPointer)
+	})
+	if nil != err {
 		return 0, err
 	}
-	tx.Commit()
-	return result, nil
+	return total, nil
 }
 
-// UpdateByID 标准：根据主键修改一条数据Model
+// Upsert 标准：入库一个Model，主键或唯一索引冲突时修改，见 BaseDao.UpsertContext
 // modPointer interface{}	数据，指针
-// int64	成功修改数量
+// int64	受影响行数
 // error	不为 nil 时失败
-func (that *BaseService) UpdateByID(modPointer interface{}) (int64, error) {
-	tx, _ := db.Begin()
-	defer that.panicRollback(tx)
-	result, err := GetInstanceByBaseDao().UpdateByID(tx, modPointer)
-	if nil != err || 1 > result {
-		tx.Rollback()
+func (that *BaseService) Upsert(modPointer interface{}) (int64, error) {
+	return that.UpsertContext(context.Background(), modPointer)
+}
+
+// UpsertContext 见 Upsert
+func (that *BaseService) UpsertContext(ctx context.Context, modPointer interface{}) (int64, error) {
+	return getStorage().Upsert(ctx, modPointer)
+}
+
+// AddModelBatch 标准：批量入库，一条语句插入
+// modPointerList interface{}	数据，[]*Model
+// int64	受影响行数
+// error	不为 nil 时失败
+func (that *BaseService) AddModelBatch(modPointerList interface{}) (int64, error) {
+	return that.AddModelBatchContext(context.Background(), modPointerList)
+}
+
+// AddModelBatchContext 见 AddModelBatch
+func (that *BaseService) AddModelBatchContext(ctx context.Context, modPointerList interface{}) (int64, error) {
+	return getStorage().AddModelBatch(ctx, modPointerList)
+}
+
+// UpdateBatch 标准：在一个事务中逐条根据主键修改，任一失败时全部回滚
+// modPointerList interface{}	数据，[]*Model
+// int64	受影响行数
+// error	不为 nil 时失败
+func (that *BaseService) UpdateBatch(modPointerList interface{}) (int64, error) {
+	return that.UpdateBatchContext(context.Background(), modPointerList)
+}
+
+// UpdateBatchContext 见 UpdateBatch
+func (that *BaseService) UpdateBatchContext(ctx context.Context, modPointerList interface{}) (int64, error) {
+	return that.eachInTransaction(ctx, modPointerList, that.UpdateByIDContext)
+}
+
+// DeleteBatch 标准：在一个事务中逐条根据主键删除，任一失败时全部回滚
+// modPointerList interface{}	数据，[]*Model，只需要主键有值
+// int64	受影响行数
+// error	不为 nil 时失败
+func (that *BaseService) DeleteBatch(modPointerList interface{}) (int64, error) {
+	return that.DeleteBatchContext(context.Background(), modPointerList)
+}
+
+// DeleteBatchContext 见 DeleteBatch
+func (that *BaseService) DeleteBatchContext(ctx context.Context, modPointerList interface{}) (int64, error) {
+	return that.eachInTransaction(ctx, modPointerList, that.DeleteByIDContext)
+}
+
+// eachInTransaction 在一个事务中对每个 model 执行 fun，返回受影响行数之和
+func (that *BaseService) eachInTransaction(ctx context.Context, modPointerList interface{}, fun func(ctx context.Context, modPointer interface{}) (int64, error)) (int64, error) {
+	var rows int64
+	err := that.TransactionContext(ctx, func(ctx context.Context) error {
+		modLst := reflect.ValueOf(modPointerList)
+		for i := 0; i < modLst.Len(); i++ {
+			n, err := fun(ctx, modLst.Index(i).Interface())
+			if nil != err {
+				return err
+			}
+			rows += n
+		}
+		return nil
+	})
+	if nil != err {
 		return 0, err
 	}
-	tx.Commit()
-	return result, nil
+	return rows, nil
 }
@@ -0,0 +1,147 @@
+package at
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestListOrdersByPKInReadOnlyTx(t *testing.T) {
+	f, db := newFakeDB()
+	defer db.Close()
+	SetDb(db)
+	defer SetDb(nil)
+	list := make([]*testPerson, 0)
+	err := GetInstanceByBaseService().ListContext(context.Background(), map[string]interface{}{CondORDERField: "name", CondPageIndex: 3}, &testPerson{}, &list)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if 1 != len(f.beginOpts) || !f.beginOpts[0].ReadOnly || 1 != f.commits {
+		t.Errorf("begin options = %+v, commits = %d, want one read only transaction", f.beginOpts, f.commits)
+	}
+	if 1 != len(f.execs) || !strings.Contains(f.execs[0], "ORDER BY p.name DESC,p.id DESC LIMIT 501") {
+		t.Errorf("queries = %v", f.execs)
+	}
+}
+
+// queryRecorder 记录 BaseDao 执行的语句
+type queryRecorder struct {
+	sqls []string
+}
+
+func (that *queryRecorder) BeforeQuery(context.Context, *QueryEvent) {
+}
+
+func (that *queryRecorder) AfterQuery(_ context.Context, event *QueryEvent) {
+	that.sqls = append(that.sqls, event.SQL)
+}
+
+func TestListByKeyset(t *testing.T) {
+	db, err := sql.Open("sqlite3", "file:"+filepath.Join(t.TempDir(), "list.db"))
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err = db.Exec("CREATE TABLE item (id INTEGER PRIMARY KEY, title TEXT)"); nil != err {
+		t.Fatal(err)
+	}
+	const total = 2*listBatchSize + 1
+	err = GetInstanceByBaseDao().Transaction(db, func(tx *sql.Tx) error {
+		for i := 1; i <= total; i++ {
+			// title 重复，分批依靠追加的主键保证顺序唯一
+			if _, err := tx.Exec("INSERT INTO item (id, title) VALUES (?, ?)", i, fmt.Sprintf("t%d", i%3)); nil != err {
+				return err
+			}
+		}
+		return nil
+	})
+	if nil != err {
+		t.Fatal(err)
+	}
+	SetDb(db)
+	defer SetDb(nil)
+	recorder := &queryRecorder{}
+	AddObserver(recorder)
+	defer ClearObserver()
+
+	list := make([]*testItem, 0)
+	err = GetInstanceByBaseService().ListContext(context.Background(), map[string]interface{}{CondORDERField: "title", CondORDERType: CondORDERTypeAES, CondPageIndex: 2}, &testItem{}, &list)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if total != len(list) {
+		t.Fatalf("len = %d, want %d", len(list), total)
+	}
+	seen := make(map[int64]bool, total)
+	for i, m := range list {
+		if seen[m.Id] {
+			t.Fatalf("id %d listed twice", m.Id)
+		}
+		seen[m.Id] = true
+		if 0 < i && (list[i-1].Title > m.Title || list[i-1].Title == m.Title && list[i-1].Id > m.Id) {
+			t.Fatalf("%+v before %+v", list[i-1], m)
+		}
+	}
+	if 3 != len(recorder.sqls) {
+		t.Fatalf("queries = %v, want 3 batches", recorder.sqls)
+	}
+	for i, s := range recorder.sqls {
+		if strings.Contains(s, "LIMIT 500,") || strings.Contains(s, "LIMIT 1000,") {
+			t.Errorf("batch %d uses OFFSET: %s", i, s)
+		}
+		if 0 < i && !strings.Contains(s, "i.title > ?") {
+			t.Errorf("batch %d has no keyset condition: %s", i, s)
+		}
+	}
+}
+
+func TestListMemoryStorage(t *testing.T) {
+	SetStorage(NewMemoryStorage())
+	defer SetStorage(nil)
+	ctx := context.Background()
+	for i := 0; i < listBatchSize+1; i++ {
+		if _, err := GetInstanceByBaseService().AddModelContext(ctx, &testItem{Title: "t"}); nil != err {
+			t.Fatal(err)
+		}
+	}
+	list := make([]*testItem, 0)
+	if err := GetInstanceByBaseService().ListContext(ctx, map[string]interface{}{}, &testItem{}, &list); nil != err {
+		t.Fatal(err)
+	}
+	if listBatchSize+1 != len(list) {
+		t.Errorf("len = %d", len(list))
+	}
+}
+
+func TestPageInReadOnlyTx(t *testing.T) {
+	f, db := newFakeDB()
+	defer db.Close()
+	f.rows = func(query string) ([]string, [][]driver.Value) {
+		if strings.HasPrefix(query, "SELECT COUNT") {
+			return []string{"n"}, [][]driver.Value{{int64(2)}}
+		}
+		return []string{"id", "name"}, [][]driver.Value{{int64(1), "a"}, {int64(2), "b"}}
+	}
+	SetDb(db)
+	defer SetDb(nil)
+	list := make([]*testPerson, 0)
+	total, err := GetInstanceByBaseService().PageContext(context.Background(), map[string]interface{}{CondPageIndex: 1, CondPageSize: 10}, &testPerson{}, &list)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if 2 != total || 2 != len(list) {
+		t.Errorf("total = %d len = %d", total, len(list))
+	}
+	if 1 != len(f.beginOpts) || !f.beginOpts[0].ReadOnly || 1 != f.commits {
+		t.Errorf("begin options = %+v, commits = %d, want one read only transaction", f.beginOpts, f.commits)
+	}
+	if 2 != len(f.execs) || !strings.HasPrefix(f.execs[0], "SELECT COUNT") {
+		t.Errorf("queries = %v, want count and list", f.execs)
+	}
+}
@@ -0,0 +1,386 @@
+package at
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Binder 将 HTTP 请求的查询参数与 JSON body 转为 condition，参数名与 condition 相同：
+//
+//	GET /users?name=tom&%3F%3E%3Dage=18&condPageIndex=2&condPageSize=50&condORDERField=age&condORDERType=1
+//
+// 字段参数名支持 model 字段名、tag json、tag table，可以带 ! 与操作符前缀（见 Lt、Gt、LTeq、GTeq、In），
+// 其它操作符返回 BindErrors；值按 model 字段类型转换，转换后的 key 为表字段；IN 条件与 thing 字段只支持数字字段，多个值以逗号分隔或重复参数。
+// 分页、排序、时间参数按类型转换与校验，不合法时返回 BindErrors，StatusCode 为 400。
+type Binder struct {
+	// Fields 可以作为条件的字段，nil 为全部表字段（盲索引列除外）
+	Fields []string
+	// SortFields 可以排序的字段，nil 与 Fields 相同
+	SortFields []string
+	// MaxPageSize 每页最大数量，< 1 时为 DefaultMaxPageSize
+	MaxPageSize int
+	// Strict 为 true 时不认识的参数返回错误，否则忽略
+	Strict bool
+}
+
+// 参数可以使用的操作符
+var bindOperators = map[string]bool{Lt: true, Gt: true, LTeq: true, GTeq: true, In: true}
+
+// DefaultMaxPageSize Binder 默认的每页最大数量
+const DefaultMaxPageSize = 100
+
+// JSON body 最大字节数
+const maxBindBodySize = 1 << 20
+
+// BindError 单个参数的转换失败信息
+type BindError struct {
+	// Param 参数名
+	Param string `json:"param"`
+	// Message 失败描述
+	Message string `json:"message"`
+}
+
+func (that *BindError) Error() string {
+	return fmt.Sprintf("%s: %s", that.Param, that.Message)
+}
+
+// StatusCode 对应的 HTTP 状态码
+func (that *BindError) StatusCode() int {
+	return http.StatusBadRequest
+}
+
+// BindErrors 参数转换失败集合
+type BindErrors []*BindError
+
+func (that BindErrors) Error() string {
+	msg := strings.Builder{}
+	msg.WriteString("bind failed: ")
+	for inx, v := range that {
+		if 0 != inx {
+			msg.WriteString("; ")
+		}
+		msg.WriteString(v.Error())
+	}
+	return msg.String()
+}
+
+// StatusCode 对应的 HTTP 状态码
+func (that BindErrors) StatusCode() int {
+	return http.StatusBadRequest
+}
+
+// Fields 参数名与失败描述的映射
+func (that BindErrors) Fields() map[string]string {
+	m := make(map[string]string, len(that))
+	for _, v := range that {
+		if _, isOk := m[v.Param]; !isOk {
+			m[v.Param] = v.Message
+		}
+	}
+	return m
+}
+
+// BindCondition 使用默认的 Binder（全部字段可查询、每页最多 DefaultMaxPageSize）转换请求参数
+func BindCondition(r *http.Request, modPointer interface{}) (map[string]interface{}, error) {
+	return (&Binder{}).Bind(r, modPointer)
+}
+
+// Bind 将请求的查询参数与 JSON body（Content-Type 为 application/json 时）转为 condition，同名参数查询参数优先
+// r *http.Request	请求
+// modPointer interface{}	model 指针，用于字段类型转换
+// map[string]interface{}	condition
+// error	参数不合法时为 BindErrors，body 不是合法 JSON 时为 *BindError
+func (that *Binder) Bind(r *http.Request, modPointer interface{}) (map[string]interface{}, error) {
+	values := url.Values{}
+	if nil != r.Body && http.MethodGet != r.Method {
+		if mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); "application/json" == mt {
+			body, err := bindJSONBody(r.Body)
+			if nil != err {
+				return nil, err
+			}
+			values = body
+		}
+	}
+	for k, v := range r.URL.Query() {
+		values[k] = v
+	}
+	return that.BindValues(values, modPointer)
+}
+
+// bindJSONBody 将 JSON 对象转为 url.Values，数组为多个值
+func bindJSONBody(body io.Reader) (url.Values, error) {
+	dec := json.NewDecoder(io.LimitReader(body, maxBindBodySize))
+	dec.UseNumber()
+	var m map[string]interface{}
+	if err := dec.Decode(&m); nil != err {
+		if io.EOF == err {
+			return url.Values{}, nil
+		}
+		return nil, &BindError{Param: "body", Message: "invalid json: " + err.Error()}
+	}
+	values := make(url.Values, len(m))
+	for k, v := range m {
+		if arr, isOk := v.([]interface{}); isOk {
+			for _, item := range arr {
+				values.Add(k, fmt.Sprint(item))
+			}
+			continue
+		}
+		if nil != v {
+			values.Set(k, fmt.Sprint(v))
+		}
+	}
+	return values, nil
+}
+
+// BindValues 将参数转为 condition，见 Bind
+func (that *Binder) BindValues(values url.Values, modPointer interface{}) (map[string]interface{}, error) {
+	meta := getModelMeta(modPointer)
+	ty := reflect.TypeOf(modPointer).Elem()
+	condition := make(map[string]interface{}, len(values))
+	errs := make(BindErrors, 0)
+	fail := func(param, format string, a ...interface{}) {
+		errs = append(errs, &BindError{Param: param, Message: fmt.Sprintf(format, a...)})
+	}
+
+	for param, vs := range values {
+		if 0 == len(vs) || (1 == len(vs) && "" == vs[0]) {
+			continue
+		}
+		if IsBaseCond(param) {
+			if 1 != len(vs) {
+				fail(param, "must have one value")
+				continue
+			}
+			v, err := that.bindBaseCond(meta, ty, param, vs[0])
+			if nil != err {
+				fail(param, "%s", err.Error())
+				continue
+			}
+			condition[param] = v
+			continue
+		}
+
+		key, prefix, operator := param, "", ""
+		if strings.HasPrefix(key, NOeq) {
+			prefix, key = NOeq, key[1:]
+		}
+		if strings.HasPrefix(key, "?") && 3 <= len(key) {
+			operator, key = key[:3], key[3:]
+			prefix += operator
+			if !bindOperators[operator] {
+				fail(param, "unknown operator %s", operator)
+				continue
+			}
+		}
+		name, tf, isOk := meta.modelFieldByKey(key)
+		if !isOk || !that.filterable(meta, tf) {
+			if isOk || that.Strict {
+				fail(param, "not filterable")
+			}
+			continue
+		}
+		if "" != operator && tf.FieldEncrypt {
+			fail(param, "encrypted field only supports equal")
+			continue
+		}
+		sf, _ := ty.FieldByName(name)
+		if In == operator || PropertyThing == tf.FieldProperty {
+			// IN 的值直接拼入 SQL，只允许数字
+			v, err := bindInValue(sf.Type, vs)
+			if nil != err {
+				fail(param, "%s", err.Error())
+				continue
+			}
+			condition[prefix+tf.FieldNameByTable] = v
+			continue
+		}
+		if 1 != len(vs) {
+			fail(param, "must have one value")
+			continue
+		}
+		var v interface{}
+		var err error
+		if timeType == derefType(sf.Type) {
+			v, err = bindTime(vs[0], tf)
+		} else {
+			v, err = bindFieldValue(sf.Type, vs[0])
+		}
+		if nil != err {
+			fail(param, "%s", err.Error())
+			continue
+		}
+		condition[prefix+tf.FieldNameByTable] = v
+	}
+	if 0 != len(errs) {
+		sort.Slice(errs, func(i, j int) bool { return errs[i].Param < errs[j].Param })
+		return nil, errs
+	}
+	return condition, nil
+}
+
+// filterable 字段是否可以作为条件
+func (that *Binder) filterable(meta *modelMeta, tf TableField) bool {
+	if nil == that.Fields {
+		_, isBlindIndex := blindIndexSource(tf.FieldNameByTable, meta.mapModelTableField)
+		return !isBlindIndex
+	}
+	return bindFieldIn(that.Fields, tf)
+}
+
+// sortable 字段是否可以排序
+func (that *Binder) sortable(meta *modelMeta, tf TableField) bool {
+	if nil == that.SortFields {
+		return that.filterable(meta, tf)
+	}
+	return bindFieldIn(that.SortFields, tf)
+}
+
+func bindFieldIn(fields []string, tf TableField) bool {
+	for _, f := range fields {
+		if f == tf.FieldNameByModel || f == tf.FieldNameByJSON || f == tf.FieldNameByTable {
+			return true
+		}
+	}
+	return false
+}
+
+// bindBaseCond 转换分页、排序、时间与游标参数
+func (that *Binder) bindBaseCond(meta *modelMeta, ty reflect.Type, param, s string) (interface{}, error) {
+	switch param {
+	case CondPageIndex, CondLimitBegin:
+		minValue := 0
+		if CondPageIndex == param {
+			minValue = 1
+		}
+		n, err := strconv.Atoi(s)
+		if nil != err || minValue > n {
+			return nil, fmt.Errorf("must be an integer >= %d", minValue)
+		}
+		return n, nil
+	case CondPageSize:
+		maxSize := that.MaxPageSize
+		if 1 > maxSize {
+			maxSize = DefaultMaxPageSize
+		}
+		n, err := strconv.Atoi(s)
+		if nil != err || 1 > n || maxSize < n {
+			return nil, fmt.Errorf("must be an integer between 1 and %d", maxSize)
+		}
+		return n, nil
+	case CondORDERType:
+		switch strings.ToLower(s) {
+		case "1", "asc":
+			return CondORDERTypeAES, nil
+		case "2", "desc":
+			return CondORDERTypeDESC, nil
+		}
+		return nil, fmt.Errorf("must be 1 (asc) or 2 (desc)")
+	case CondORDERField:
+		fields := make([]string, 0)
+		for _, f := range strings.Split(s, ",") {
+			_, tf, isOk := meta.modelFieldByKey(strings.TrimSpace(f))
+			if !isOk || !that.sortable(meta, tf) {
+				return nil, fmt.Errorf("%s is not sortable", strings.TrimSpace(f))
+			}
+			fields = append(fields, tf.FieldNameByTable)
+		}
+		return strings.Join(fields, ","), nil
+	case CondBeginTime, CondEndTime:
+		// 按创建时间字段的时区解析，查询时按其精度与类型转换
+		_, tf, _ := meta.fieldByTable(meta.createTimeField())
+		return bindTime(s, tf)
+	}
+	// CondCursor
+	return s, nil
+}
+
+func derefType(ty reflect.Type) reflect.Type {
+	for reflect.Ptr == ty.Kind() {
+		ty = ty.Elem()
+	}
+	return ty
+}
+
+// bindTime 时间参数支持 unix 秒与时间字符串，时间字符串按字段 tf 的 tz tag 解析
+func bindTime(s string, tf TableField) (time.Time, error) {
+	tm, err := condTime(s, tf)
+	if nil != err {
+		return tm, fmt.Errorf("must be unix seconds or a time like 2006-01-02 15:04:05")
+	}
+	return tm, nil
+}
+
+// bindFieldValue 按字段类型转换参数，sql.Null* 按其值类型转换
+func bindFieldValue(ty reflect.Type, s string) (interface{}, error) {
+	ty = derefType(ty)
+	if timeType == ty {
+		return bindTime(s, TableField{})
+	}
+	if reflect.Struct == ty.Kind() && 2 == ty.NumField() && "Valid" == ty.Field(1).Name {
+		return bindFieldValue(ty.Field(0).Type, s)
+	}
+	switch {
+	case reflect.Int <= ty.Kind() && reflect.Int64 >= ty.Kind():
+		n, err := strconv.ParseInt(s, 10, ty.Bits())
+		if nil != err {
+			return nil, fmt.Errorf("must be an integer")
+		}
+		return n, nil
+	case reflect.Uint <= ty.Kind() && reflect.Uint64 >= ty.Kind():
+		n, err := strconv.ParseUint(s, 10, ty.Bits())
+		if nil != err {
+			return nil, fmt.Errorf("must be a non-negative integer")
+		}
+		return n, nil
+	case reflect.Float32 == ty.Kind() || reflect.Float64 == ty.Kind():
+		n, err := strconv.ParseFloat(s, ty.Bits())
+		if nil != err {
+			return nil, fmt.Errorf("must be a number")
+		}
+		return n, nil
+	case reflect.Bool == ty.Kind():
+		b, err := strconv.ParseBool(s)
+		if nil != err {
+			return nil, fmt.Errorf("must be true or false")
+		}
+		return b, nil
+	}
+	return s, nil
+}
+
+// bindInValue IN 条件的值，每个值需为数字，转换后以逗号拼接
+func bindInValue(ty reflect.Type, vs []string) (string, error) {
+	items := make([]string, 0)
+	for _, v := range vs {
+		for _, item := range strings.Split(v, ",") {
+			item = strings.TrimSpace(item)
+			if "" == item {
+				continue
+			}
+			n, err := bindFieldValue(ty, item)
+			if nil != err {
+				return "", err
+			}
+			switch n.(type) {
+			case int64, uint64, float64:
+			default:
+				return "", fmt.Errorf("in only supports numeric fields")
+			}
+			items = append(items, fmt.Sprint(n))
+		}
+	}
+	if 0 == len(items) {
+		return "", fmt.Errorf("must have at least one value")
+	}
+	return strings.Join(items, ","), nil
+}
@@ -0,0 +1,134 @@
+package at
+
+import (
+	"errors"
+	"net/url"
+	"strconv"
+	"testing"
+)
+
+func TestBindUnknownOperator(t *testing.T) {
+	b := &Binder{}
+	for _, param := range []string{"?xyid", "?!=id", "!?abid"} {
+		_, err := b.BindValues(url.Values{param: {"1"}}, &testPerson{})
+		var errs BindErrors
+		if !errors.As(err, &errs) || 1 != len(errs) || param != errs[0].Param {
+			t.Errorf("%s: err = %v, want BindErrors", param, err)
+		}
+	}
+	for _, param := range []string{Lt + "id", Gt + "id", LTeq + "id", GTeq + "id", In + "id", NOeq + "id"} {
+		if _, err := b.BindValues(url.Values{param: {"1"}}, &testPerson{}); nil != err {
+			t.Errorf("%s: %v", param, err)
+		}
+	}
+}
+
+// testTicket 测试用的 model，status 与 label 为 thing 字段
+type testTicket struct {
+	BaseModel
+	Id     int64   `json:"id" table:"id"`
+	Status int     `json:"status" table:"status" comment:"thing 状态"`
+	Label  string  `json:"label" table:"label" comment:"thing 标签"`
+	Score  float64 `json:"score" table:"score"`
+	Title  string  `json:"title" table:"title"`
+}
+
+func (*testTicket) GetTableName() string {
+	return "ticket"
+}
+
+// bindParamErr 只有参数 param 转换失败
+func bindParamErr(t *testing.T, err error, param string) {
+	t.Helper()
+	var errs BindErrors
+	if !errors.As(err, &errs) || 1 != len(errs) || param != errs[0].Param {
+		t.Errorf("%s: err = %v, want BindErrors for it", param, err)
+	}
+}
+
+func TestBindSortFields(t *testing.T) {
+	b := &Binder{SortFields: []string{"id", "name"}}
+	condition, err := b.BindValues(url.Values{CondORDERField: {"name, id"}, CondORDERType: {"desc"}}, &testPerson{})
+	if nil != err || "name,id" != condition[CondORDERField] || CondORDERTypeDESC != condition[CondORDERType] {
+		t.Fatalf("condition = %v, err = %v", condition, err)
+	}
+	// 不在白名单、不存在的字段都不能排序，白名单之外的字段仍可作为条件
+	for _, s := range []string{"phone", "id,phone", "nope", "id; DROP TABLE person"} {
+		_, err = b.BindValues(url.Values{CondORDERField: {s}}, &testPerson{})
+		bindParamErr(t, err, CondORDERField)
+	}
+	if _, err = b.BindValues(url.Values{"phone": {"138"}}, &testPerson{}); nil != err {
+		t.Error(err)
+	}
+	// SortFields 为 nil 时与 Fields 相同
+	b = &Binder{Fields: []string{"name"}}
+	if _, err = b.BindValues(url.Values{CondORDERField: {"name"}}, &testPerson{}); nil != err {
+		t.Error(err)
+	}
+	_, err = b.BindValues(url.Values{CondORDERField: {"id"}}, &testPerson{})
+	bindParamErr(t, err, CondORDERField)
+}
+
+func TestBindInNumericOnly(t *testing.T) {
+	b := &Binder{}
+	condition, err := b.BindValues(url.Values{In + "id": {"1, 2", "3"}, "status": {"4,5"}, In + "score": {"1.5"}}, &testTicket{})
+	if nil != err {
+		t.Fatal(err)
+	}
+	if "1,2,3" != condition[In+"id"] || "4,5" != condition["status"] || "1.5" != condition[In+"score"] {
+		t.Errorf("condition = %v", condition)
+	}
+	for _, c := range []struct {
+		param string
+		value string
+	}{
+		{In + "id", "1,x"},
+		{In + "id", ",,"},
+		{In + "title", "a,b"},
+		{"label", "a"},
+		{"status", "1) OR (1=1"},
+	} {
+		_, err = b.BindValues(url.Values{c.param: {c.value}}, &testTicket{})
+		bindParamErr(t, err, c.param)
+	}
+}
+
+func TestBindPaging(t *testing.T) {
+	condition, err := (&Binder{}).BindValues(url.Values{CondPageIndex: {"1"}, CondPageSize: {"100"}}, &testPerson{})
+	if nil != err || 1 != condition[CondPageIndex] || 100 != condition[CondPageSize] {
+		t.Fatalf("condition = %v, err = %v", condition, err)
+	}
+	for _, s := range []string{"0", "-1", "x"} {
+		_, err = (&Binder{}).BindValues(url.Values{CondPageIndex: {s}}, &testPerson{})
+		bindParamErr(t, err, CondPageIndex)
+	}
+	// 超过 MaxPageSize 返回错误，MaxPageSize < 1 时为 DefaultMaxPageSize
+	for b, max := range map[*Binder]int{{}: DefaultMaxPageSize, {MaxPageSize: -1}: DefaultMaxPageSize, {MaxPageSize: 20}: 20} {
+		if _, err = b.BindValues(url.Values{CondPageSize: {strconv.Itoa(max)}}, &testPerson{}); nil != err {
+			t.Errorf("page size %d: %v", max, err)
+		}
+		for _, s := range []string{strconv.Itoa(max + 1), "0", "x"} {
+			_, err = b.BindValues(url.Values{CondPageSize: {s}}, &testPerson{})
+			bindParamErr(t, err, CondPageSize)
+		}
+	}
+}
+
+func TestBindMalformedValues(t *testing.T) {
+	b := &Binder{}
+	for _, param := range []string{"id", NOeq + "id", Lt + "id", Gt + "id", LTeq + "id", GTeq + "id", In + "id", "score", Gt + "score"} {
+		_, err := b.BindValues(url.Values{param: {"abc"}}, &testTicket{})
+		bindParamErr(t, err, param)
+	}
+	// 操作符只能用于未加密字段，非 IN 字段只能有一个值
+	_, err := b.BindValues(url.Values{Gt + "phone": {"1"}}, &testPerson{})
+	bindParamErr(t, err, Gt+"phone")
+	_, err = b.BindValues(url.Values{"title": {"a", "b"}}, &testTicket{})
+	bindParamErr(t, err, "title")
+	// 多个参数失败时按参数名排序全部返回
+	_, err = b.BindValues(url.Values{"score": {"x"}, "id": {"y"}}, &testTicket{})
+	var errs BindErrors
+	if !errors.As(err, &errs) || 2 != len(errs) || "id" != errs[0].Param || "score" != errs[1].Param {
+		t.Errorf("err = %v", err)
+	}
+}
@@ -0,0 +1,246 @@
+package at
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 创建时间、最后更新字段的时间由 Clock 提供，作为参数绑定（不再拼接 NOW() 或 unix 秒），
+// 同一条语句中的时间字段值相同。字段可以通过 tag 设置精度与时区：
+//
+//	CreatedAt int64     `json:"createdAt" table:"created_at" comment:"创建时间" type:"BIGINT" precision:"ms"`
+//	UpdatedAt time.Time `json:"updatedAt" table:"updated_at" comment:"最后更新" precision:"us" tz:"UTC"`
+//
+// precision	s（默认）、ms、us（也可以写 second、milli、micro）；整型字段为对应精度的 unix 时间戳，其它字段为带小数秒的时间字符串
+// tz	time.LoadLocation 的时区名，如 UTC、Asia/Shanghai，默认为 Local；时间字符串按该时区写入
+//
+// 扫描到 time.Time 字段时同样按 precision 解析 unix 时间戳，按 tz 解析时间字符串（有 tz 时驱动返回的 time.Time 按该时区的墙上时间解释）；
+// CondBeginTime、CondEndTime 的值（time.Time、unix 秒或时间字符串）按创建时间字段的精度与时区转换后作为参数。
+// SQL 与 MemoryStorage 写入时都会将生成的时间写回 model。
+const (
+	precisionTag = "precision"
+	tzTag        = "tz"
+)
+
+// 时间精度
+const (
+	PrecisionSecond = "s"
+	PrecisionMilli  = "ms"
+	PrecisionMicro  = "us"
+)
+
+// normalizePrecision precision tag 的别名转为 PrecisionSecond、PrecisionMilli、PrecisionMicro
+func normalizePrecision(precision string) string {
+	switch precision {
+	case "second", "sec":
+		return PrecisionSecond
+	case "milli", "millisecond":
+		return PrecisionMilli
+	case "micro", "microsecond":
+		return PrecisionMicro
+	}
+	return precision
+}
+
+// Clock 当前时间的来源，测试时可以替换为固定时间
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock 系统时间
+type SystemClock struct{}
+
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// FixedClock 固定时间，用于测试
+type FixedClock struct {
+	T time.Time
+}
+
+func (that FixedClock) Now() time.Time {
+	return that.T
+}
+
+var clock Clock = SystemClock{}
+var clockLock sync.RWMutex
+
+// SetClock 设置时间来源，nil 时恢复为系统时间
+func SetClock(c Clock) {
+	if nil == c {
+		c = SystemClock{}
+	}
+	clockLock.Lock()
+	defer clockLock.Unlock()
+	clock = c
+}
+
+// nowTime 当前时间
+func nowTime() time.Time {
+	clockLock.RLock()
+	c := clock
+	clockLock.RUnlock()
+	return c.Now()
+}
+
+var locations sync.Map
+
+// timestampLocation 字段 tz tag 对应的时区
+func timestampLocation(tf TableField) (*time.Location, error) {
+	if "" == tf.FieldTZ {
+		return time.Local, nil
+	}
+	if loc, isOk := locations.Load(tf.FieldTZ); isOk {
+		return loc.(*time.Location), nil
+	}
+	loc, err := time.LoadLocation(tf.FieldTZ)
+	if nil != err {
+		return nil, errors.New(fmt.Sprintf("error:field %s tz %s: %s", tf.FieldNameByTable, tf.FieldTZ, err.Error()))
+	}
+	locations.Store(tf.FieldTZ, loc)
+	return loc, nil
+}
+
+// timestampTime 按字段的时区与精度转换时间
+func timestampTime(tf TableField, now time.Time) (time.Time, error) {
+	loc, err := timestampLocation(tf)
+	if nil != err {
+		return now, err
+	}
+	switch tf.FieldPrecision {
+	case PrecisionMilli:
+		now = now.Truncate(time.Millisecond)
+	case PrecisionMicro:
+		now = now.Truncate(time.Microsecond)
+	default:
+		now = now.Truncate(time.Second)
+	}
+	return now.In(loc), nil
+}
+
+// timestampUnix 按字段精度转为 unix 时间戳
+func timestampUnix(tf TableField, t time.Time) int64 {
+	switch tf.FieldPrecision {
+	case PrecisionMilli:
+		return t.UnixMilli()
+	case PrecisionMicro:
+		return t.UnixMicro()
+	}
+	return t.Unix()
+}
+
+// timestampFromUnix 按字段精度将 unix 时间戳转为时间
+func timestampFromUnix(tf TableField, n int64) time.Time {
+	switch tf.FieldPrecision {
+	case PrecisionMilli:
+		return time.UnixMilli(n)
+	case PrecisionMicro:
+		return time.UnixMicro(n)
+	}
+	return time.Unix(n, 0)
+}
+
+// timestampParam 时间转为字段的参数：整型字段为对应精度的 unix 时间戳，其它字段为字段时区的时间字符串
+func timestampParam(tf TableField, intValue bool, t time.Time) (interface{}, error) {
+	if intValue {
+		return timestampUnix(tf, t), nil
+	}
+	loc, err := timestampLocation(tf)
+	if nil != err {
+		return nil, err
+	}
+	return t.In(loc).Format(timestampLayout(tf)), nil
+}
+
+// timestampLayout 按字段精度的时间字符串格式
+func timestampLayout(tf TableField) string {
+	switch tf.FieldPrecision {
+	case PrecisionMilli:
+		return "2006-01-02 15:04:05.000"
+	case PrecisionMicro:
+		return "2006-01-02 15:04:05.000000"
+	}
+	return "2006-01-02 15:04:05"
+}
+
+// isIntTimestamp 字段是否存储 unix 时间戳：type tag 为整型或 model 字段为整型
+func isIntTimestamp(tf TableField, fv reflect.Value) bool {
+	return strings.Contains(strings.ToUpper(tf.FieldType), "INT") || isIntKind(fv.Kind())
+}
+
+// timestampValue 创建时间、最后更新字段的参数，时区错误在执行 SQL 时返回
+type timestampValue struct {
+	field    TableField
+	intValue bool
+	now      time.Time
+}
+
+func (that timestampValue) Value() (driver.Value, error) {
+	t, err := timestampTime(that.field, that.now)
+	if nil != err {
+		return nil, err
+	}
+	if that.intValue {
+		return timestampUnix(that.field, t), nil
+	}
+	return t.Format(timestampLayout(that.field)), nil
+}
+
+// setTimestamp 将时间按字段的精度与时区写入 model 字段，用于 MemoryStorage
+func setTimestamp(fv reflect.Value, tf TableField, now time.Time) error {
+	t, err := timestampTime(tf, now)
+	if nil != err {
+		return err
+	}
+	switch {
+	case isIntKind(fv.Kind()):
+		setReflectInt(fv, timestampUnix(tf, t))
+	case reflect.String == fv.Kind():
+		fv.SetString(t.Format(timestampLayout(tf)))
+	case fv.Type() == reflect.TypeOf(t):
+		fv.Set(reflect.ValueOf(t))
+	case fv.Type() == reflect.TypeOf(&t):
+		fv.Set(reflect.ValueOf(&t))
+	}
+	return nil
+}
+
+// toTime 时间值转为时间，支持 time.Time、unix 时间戳（整数或数字字符串，按字段精度）与时间字符串（按字段时区），零值与 nil 为零时间
+func toTime(v interface{}, tf TableField) (time.Time, error) {
+	v = derefValue(v)
+	if nil == v {
+		return time.Time{}, nil
+	}
+	if tm, isOk := v.(time.Time); isOk {
+		return tm, nil
+	}
+	loc, err := timestampLocation(tf)
+	if nil != err {
+		return time.Time{}, err
+	}
+	rv := reflect.ValueOf(v)
+	if isIntKind(rv.Kind()) {
+		n := reflectInt(rv)
+		if 0 == n {
+			return time.Time{}, nil
+		}
+		return timestampFromUnix(tf, n).In(loc), nil
+	}
+	s := fmt.Sprint(v)
+	if n, err := strconv.ParseInt(s, 10, 64); nil == err {
+		return timestampFromUnix(tf, n).In(loc), nil
+	}
+	return parseTimeStringIn(s, loc)
+}
+
+// condTime CondBeginTime、CondEndTime 的值转为时间，整数为 unix 秒，时间字符串按字段时区解析
+func condTime(v interface{}, tf TableField) (time.Time, error) {
+	return toTime(v, TableField{FieldNameByTable: tf.FieldNameByTable, FieldTZ: tf.FieldTZ})
+}
@@ -0,0 +1,96 @@
+package at
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTimestampWrittenBack(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 678000000, time.UTC)
+	SetClock(FixedClock{T: now})
+	defer SetClock(nil)
+
+	f, db := newFakeDB()
+	defer db.Close()
+	mod := &testEvent{Id: 1}
+	err := GetInstanceByBaseDao().Transaction(db, func(tx *sql.Tx) error {
+		_, err := GetInstanceByBaseDao().AddModelContext(context.Background(), tx, mod)
+		return err
+	})
+	if nil != err {
+		t.Fatal(err)
+	}
+	if now.UnixMilli() != mod.CreatedAt {
+		t.Errorf("CreatedAt = %d, want %d", mod.CreatedAt, now.UnixMilli())
+	}
+	if "2024-01-02 11:04:05" != mod.UpdatedAt {
+		t.Errorf("UpdatedAt = %q, want Asia/Shanghai time", mod.UpdatedAt)
+	}
+	found := 0
+	for _, args := range f.args {
+		for _, a := range args {
+			if a == mod.CreatedAt || a == mod.UpdatedAt {
+				found++
+			}
+		}
+	}
+	if 2 != found {
+		t.Errorf("driver args %v do not match the model", f.args)
+	}
+}
+
+func TestTimeConditionUsesFieldPrecisionAndTZ(t *testing.T) {
+	meta := getModelMeta(&testEvent{})
+	cond, err := meta.timeCondition(map[string]interface{}{
+		CondBeginTime:       "2024-01-02 00:00:00",
+		GTeq + "updated_at": time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC),
+	})
+	if nil != err {
+		t.Fatal(err)
+	}
+	begin := time.Date(2024, 1, 1, 16, 0, 0, 0, time.UTC).UnixMilli()
+	if begin != cond[CondBeginTime] {
+		t.Errorf("%s = %v, want %d", CondBeginTime, cond[CondBeginTime], begin)
+	}
+	if "2024-01-02 08:00:00" != cond[GTeq+"updated_at"] {
+		t.Errorf("updated_at = %v, want Asia/Shanghai time", cond[GTeq+"updated_at"])
+	}
+}
+
+func TestParseTimeValueUsesFieldPrecisionAndTZ(t *testing.T) {
+	want := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	tf := TableField{FieldNameByTable: "t", FieldTZ: "Asia/Shanghai", FieldPrecision: PrecisionMilli}
+	for _, src := range []interface{}{"2024-01-02 08:00:00", want.UnixMilli(), time.Date(2024, 1, 2, 8, 0, 0, 0, time.UTC)} {
+		tm, err := parseTimeValue(src, tf)
+		if nil != err {
+			t.Fatal(err)
+		}
+		if !tm.Equal(want) {
+			t.Errorf("parseTimeValue(%v) = %v, want %v", src, tm, want)
+		}
+	}
+}
+
+func TestSetClockConcurrent(t *testing.T) {
+	defer SetClock(nil)
+	fixed := FixedClock{T: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			SetClock(fixed)
+		}()
+		go func() {
+			defer wg.Done()
+			_ = nowTime()
+		}()
+	}
+	wg.Wait()
+	if !fixed.T.Equal(nowTime()) {
+		t.Errorf("nowTime = %v, want %v", nowTime(), fixed.T)
+	}
+}
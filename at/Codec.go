@@ -0,0 +1,285 @@
+package at
+
+import (
+	"database/sql/driver"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Codec 字段值与数据库值的转换器，model 字段通过 codec tag 指定，如 `codec:"json"`。
+// 写入（INSERT、UPDATE 参数）时调用 Encode，读取（扫描查询结果）时调用 Decode。
+type Codec interface {
+	// Encode 将字段值转为写入数据库的值
+	Encode(value interface{}) (driver.Value, error)
+	// Decode 将数据库返回的值装入字段，src 为 nil 时表示 NULL，destPointer 为字段的指针
+	Decode(src interface{}, destPointer interface{}) error
+}
+
+// 内置 codec 名称
+const (
+	CodecJSON    = "json"
+	CodecCSV     = "csv"
+	CodecDecimal = "decimal"
+)
+
+var codecs = map[string]Codec{
+	CodecJSON:    JSONCodec{},
+	CodecCSV:     CSVCodec{},
+	CodecDecimal: DecimalCodec{},
+}
+var codecsLock sync.RWMutex
+
+// RegisterCodec 注册 codec，同名覆盖，可用于枚举等自定义类型
+// name string	codec tag 中使用的名称
+// codec Codec	转换器
+func RegisterCodec(name string, codec Codec) {
+	codecsLock.Lock()
+	defer codecsLock.Unlock()
+	codecs[name] = codec
+}
+
+// GetCodec 根据名称取得 codec
+func GetCodec(name string) (Codec, bool) {
+	codecsLock.RLock()
+	defer codecsLock.RUnlock()
+	c, isOk := codecs[name]
+	return c, isOk
+}
+
+func mustCodec(name string) (Codec, error) {
+	c, isOk := GetCodec(name)
+	if !isOk {
+		return nil, errors.New(fmt.Sprintf("error:codec %s not registered", name))
+	}
+	return c, nil
+}
+
+// codecValue 延迟编码的参数，编码错误由 database/sql 在执行时返回
+type codecValue struct {
+	name  string
+	value interface{}
+}
+
+func (that codecValue) Value() (driver.Value, error) {
+	c, err := mustCodec(that.name)
+	if nil != err {
+		return nil, err
+	}
+	return c.Encode(that.value)
+}
+
+// codecScanner 扫描时通过 codec 解码到字段
+type codecScanner struct {
+	name string
+	dest interface{}
+}
+
+func (that *codecScanner) Scan(src interface{}) error {
+	c, err := mustCodec(that.name)
+	if nil != err {
+		return err
+	}
+	return c.Decode(src, that.dest)
+}
+
+// EncodeValue 按 codec 名称编码字段值，name 为 "" 时原样返回
+func EncodeValue(name string, value interface{}) (interface{}, error) {
+	if "" == name {
+		return value, nil
+	}
+	return codecValue{name: name, value: value}.Value()
+}
+
+// isNilValue 值为 nil 或 nil 的指针、切片、map
+func isNilValue(value interface{}) bool {
+	if nil == value {
+		return true
+	}
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Map, reflect.Interface:
+		return rv.IsNil()
+	}
+	return false
+}
+
+// srcString 数据库返回的文本值
+func srcString(src interface{}) (string, error) {
+	switch v := src.(type) {
+	case []byte:
+		return string(v), nil
+	case string:
+		return v, nil
+	case int64, float64, bool:
+		return fmt.Sprint(v), nil
+	}
+	return "", errors.New(fmt.Sprintf("error:unsupported scan, storing %T into codec field", src))
+}
+
+// JSONCodec 字段以 JSON 文本存储，nil 的指针、切片、map 写入 NULL，NULL 与 "" 读取为零值
+type JSONCodec struct{}
+
+func (JSONCodec) Encode(value interface{}) (driver.Value, error) {
+	if isNilValue(value) {
+		return nil, nil
+	}
+	buf, err := json.Marshal(value)
+	if nil != err {
+		return nil, err
+	}
+	return string(buf), nil
+}
+
+func (JSONCodec) Decode(src interface{}, destPointer interface{}) error {
+	dest := reflect.ValueOf(destPointer).Elem()
+	dest.Set(reflect.Zero(dest.Type()))
+	if nil == src {
+		return nil
+	}
+	s, err := srcString(src)
+	if nil != err || "" == s {
+		return err
+	}
+	return json.Unmarshal([]byte(s), destPointer)
+}
+
+// CSVCodec 切片字段以 CSV 的一行存储，如 []string{"a","b"} 存为 "a,b"，含逗号、引号的元素按 CSV 规则加引号，
+// 元素支持字符串、整数、浮点数与 bool
+type CSVCodec struct{}
+
+func (CSVCodec) Encode(value interface{}) (driver.Value, error) {
+	rv := reflect.ValueOf(value)
+	if reflect.Slice != rv.Kind() {
+		return nil, errors.New(fmt.Sprintf("error:csv codec needs slice, got %T", value))
+	}
+	if 0 == rv.Len() {
+		return "", nil
+	}
+	items := make([]string, rv.Len())
+	for i := 0; i < rv.Len(); i++ {
+		items[i] = fmt.Sprint(rv.Index(i).Interface())
+	}
+	var buf strings.Builder
+	w := csv.NewWriter(&buf)
+	if err := w.Write(items); nil != err {
+		return nil, err
+	}
+	w.Flush()
+	if err := w.Error(); nil != err {
+		return nil, err
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+func (CSVCodec) Decode(src interface{}, destPointer interface{}) error {
+	dest := reflect.ValueOf(destPointer).Elem()
+	if reflect.Slice != dest.Kind() {
+		return errors.New(fmt.Sprintf("error:csv codec needs slice, got %s", dest.Type()))
+	}
+	dest.Set(reflect.Zero(dest.Type()))
+	if nil == src {
+		return nil
+	}
+	s, err := srcString(src)
+	if nil != err || "" == s {
+		return err
+	}
+	// 兼容以前未加引号写入的数据：允许元素中有引号、逗号后有空格
+	r := csv.NewReader(strings.NewReader(s))
+	r.LazyQuotes = true
+	r.TrimLeadingSpace = true
+	items, err := r.Read()
+	if nil != err {
+		return errors.New(fmt.Sprintf("error:invalid csv %q: %s", s, err.Error()))
+	}
+	list := reflect.MakeSlice(dest.Type(), len(items), len(items))
+	for i, item := range items {
+		if err = setStringValue(list.Index(i), item); nil != err {
+			return err
+		}
+	}
+	dest.Set(list)
+	return nil
+}
+
+// setStringValue 将字符串转为 fv 的类型并赋值
+func setStringValue(fv reflect.Value, s string) error {
+	switch {
+	case reflect.String == fv.Kind():
+		fv.SetString(s)
+	case reflect.Bool == fv.Kind():
+		b, err := strconv.ParseBool(s)
+		if nil != err {
+			return err
+		}
+		fv.SetBool(b)
+	case isIntKind(fv.Kind()):
+		i, err := strconv.ParseInt(s, 10, 64)
+		if nil != err {
+			return err
+		}
+		setReflectInt(fv, i)
+	case reflect.Float32 == fv.Kind() || reflect.Float64 == fv.Kind():
+		f, err := strconv.ParseFloat(s, 64)
+		if nil != err {
+			return err
+		}
+		fv.SetFloat(f)
+	default:
+		return errors.New(fmt.Sprintf("error:cannot convert %q to %s", s, fv.Type()))
+	}
+	return nil
+}
+
+var decimalRegexp = regexp.MustCompile(`^[-+]?\d+(\.\d+)?$`)
+
+// DecimalCodec 金额等 DECIMAL 字段以 string 保存，避免 float 精度丢失，字段只能是 string 或 *string。
+// 写入时校验格式，"" 写入 NULL；读取时保留数据库返回的文本，如 "12.50"。
+type DecimalCodec struct{}
+
+func (DecimalCodec) Encode(value interface{}) (driver.Value, error) {
+	if isNilValue(value) {
+		return nil, nil
+	}
+	s, isOk := derefValue(value).(string)
+	if !isOk {
+		return nil, errors.New(fmt.Sprintf("error:decimal codec needs string, got %T", value))
+	}
+	if "" == s {
+		return nil, nil
+	}
+	if !decimalRegexp.MatchString(s) {
+		return nil, errors.New(fmt.Sprintf("error:invalid decimal %q", s))
+	}
+	return s, nil
+}
+
+func (DecimalCodec) Decode(src interface{}, destPointer interface{}) error {
+	dest := reflect.ValueOf(destPointer).Elem()
+	if reflect.String != derefType(dest.Type()).Kind() || (reflect.Ptr == dest.Kind() && reflect.Ptr == dest.Type().Elem().Kind()) {
+		return errors.New(fmt.Sprintf("error:decimal codec needs string, got %s", dest.Type()))
+	}
+	if nil == src {
+		dest.Set(reflect.Zero(dest.Type()))
+		return nil
+	}
+	s, err := srcString(src)
+	if nil != err {
+		return err
+	}
+	if reflect.Ptr == dest.Kind() {
+		p := reflect.New(dest.Type().Elem())
+		p.Elem().SetString(s)
+		dest.Set(p)
+		return nil
+	}
+	dest.SetString(s)
+	return nil
+}
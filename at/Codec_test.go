@@ -0,0 +1,46 @@
+package at
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDecimalCodecNonString(t *testing.T) {
+	var f float64
+	if err := (DecimalCodec{}).Decode([]byte("12.50"), &f); nil == err {
+		t.Error("Decode into float64 should fail")
+	}
+	if _, err := (DecimalCodec{}).Encode(12.5); nil == err {
+		t.Error("Encode float64 should fail")
+	}
+	var s *string
+	if err := (DecimalCodec{}).Decode([]byte("12.50"), &s); nil != err || "12.50" != *s {
+		t.Errorf("Decode into *string = %v, %v", s, err)
+	}
+	if v, err := (DecimalCodec{}).Encode("12.50"); nil != err || "12.50" != v {
+		t.Errorf("Encode string = %v, %v", v, err)
+	}
+}
+
+func TestCSVCodecComma(t *testing.T) {
+	for _, want := range [][]string{{"a,b", `say "hi"`, "c"}, {"a", "b"}, {"line\nbreak"}} {
+		v, err := (CSVCodec{}).Encode(want)
+		if nil != err {
+			t.Fatal(err)
+		}
+		var got []string
+		if err = (CSVCodec{}).Decode(v, &got); nil != err {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(want, got) {
+			t.Errorf("round trip %q: stored %q, got %q", want, v, got)
+		}
+	}
+	if v, _ := (CSVCodec{}).Encode([]int{1, 2}); "1,2" != v {
+		t.Errorf("Encode([]int{1, 2}) = %q", v)
+	}
+	var old []string
+	if err := (CSVCodec{}).Decode([]byte(`a, b"c`), &old); nil != err || !reflect.DeepEqual([]string{"a", `b"c`}, old) {
+		t.Errorf("Decode legacy value = %q, %v", old, err)
+	}
+}
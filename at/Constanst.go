@@ -20,6 +20,8 @@ const (
 	CondPageIndex = "condPageIndex"
 	// CondPageSize 页数据数量
 	CondPageSize = "condPageSize"
+	// CondCursor 游标分页的游标，值为上一页返回的 CursorPage.Next 或 CursorPage.Prev，第一页为 ""
+	CondCursor = "condCursor"
 )
 
 func IsBaseCond(key string) bool {
@@ -37,6 +39,8 @@ func IsBaseCond(key string) bool {
 	case CondPageIndex:
 		fallthrough
 	case CondPageSize:
+		fallthrough
+	case CondCursor:
 		return true
 	default:
 		return false
@@ -0,0 +1,279 @@
+package at
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrCursorInvalid 游标被篡改、格式错误或与当前的表、条件、排序不一致
+var ErrCursorInvalid = errors.New("error:cursor invalid")
+
+// 游标签名密钥，默认进程启动时随机生成（重启后旧游标失效），多实例部署需通过 SetCursorSecret 设置相同密钥
+var cursorSecret = func() []byte {
+	key := make([]byte, 32)
+	rand.Read(key)
+	return key
+}()
+
+// SetCursorSecret 设置游标签名密钥
+func SetCursorSecret(key []byte) {
+	cursorSecret = key
+}
+
+// CursorPage 游标分页结果
+type CursorPage struct {
+	// Next 下一页游标，没有下一页时为 ""
+	Next string `json:"next"`
+	// Prev 上一页游标，没有上一页时为 ""
+	Prev    string `json:"prev"`
+	HasNext bool   `json:"hasNext"`
+	HasPrev bool   `json:"hasPrev"`
+}
+
+// cursorToken 游标内容：排序字段、最后一行的排序值、排序方向、是否向前翻页，以及所属查询的摘要
+type cursorToken struct {
+	Fields   []string      `json:"f"`
+	Values   []interface{} `json:"v"`
+	Asc      bool          `json:"a"`
+	Backward bool          `json:"b,omitempty"`
+	Scope    string        `json:"s"`
+}
+
+// cursorScope 游标所属查询的摘要：逻辑表名与条件（包括排序，不包括分页与游标），
+// 游标只能用于生成它的查询，不能换到其它表或其它条件
+func cursorScope(tableName string, condition map[string]interface{}) string {
+	keys := make([]string, 0, len(condition))
+	for k := range condition {
+		if CondCursor == k || CondPageIndex == k || CondPageSize == k || CondLimitBegin == k {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	h := sha256.New()
+	h.Write([]byte(tableName))
+	for _, k := range keys {
+		fmt.Fprintf(h, "\x00%s=%v", k, cursorValue(condition[k]))
+	}
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil)[:16])
+}
+
+func encodeCursor(token *cursorToken) (string, error) {
+	payload, err := json.Marshal(token)
+	if nil != err {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, cursorSecret)
+	mac.Write(payload)
+	return fmt.Sprintf("%s.%s", base64.RawURLEncoding.EncodeToString(payload), base64.RawURLEncoding.EncodeToString(mac.Sum(nil))), nil
+}
+
+func decodeCursor(s string) (*cursorToken, error) {
+	parts := strings.Split(s, ".")
+	if 2 != len(parts) {
+		return nil, ErrCursorInvalid
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if nil != err {
+		return nil, ErrCursorInvalid
+	}
+	sign, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if nil != err {
+		return nil, ErrCursorInvalid
+	}
+	mac := hmac.New(sha256.New, cursorSecret)
+	mac.Write(payload)
+	if !hmac.Equal(sign, mac.Sum(nil)) {
+		return nil, ErrCursorInvalid
+	}
+	token := &cursorToken{}
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+	if err = decoder.Decode(token); nil != err {
+		return nil, ErrCursorInvalid
+	}
+	// 数字还原为 int64 或 float64，避免大整数主键丢失精度
+	for i, v := range token.Values {
+		if n, isOk := v.(json.Number); isOk {
+			if i64, err := n.Int64(); nil == err {
+				token.Values[i] = i64
+			} else if f, err := n.Float64(); nil == err {
+				token.Values[i] = f
+			}
+		}
+	}
+	return token, nil
+}
+
+// cursorValue 排序值写入游标前统一格式，时间使用数据库可比较的字符串
+func cursorValue(v interface{}) interface{} {
+	v = derefValue(v)
+	if tm, isOk := v.(time.Time); isOk {
+		return tm.Format("2006-01-02 15:04:05.999999")
+	}
+	return v
+}
+
+// cursorOrder 游标分页的排序字段与方向，排序字段末尾总是追加主键保证顺序唯一
+func cursorOrder(meta *modelMeta, condition map[string]interface{}) ([]string, bool) {
+	fields := make([]string, 0)
+	if v, isOk := condition[CondORDERField]; isOk {
+		for _, f := range strings.Split(fmt.Sprint(v), ",") {
+			if _, tf, isOk := meta.modelFieldByKey(strings.TrimSpace(f)); isOk {
+				fields = append(fields, tf.FieldNameByTable)
+			}
+		}
+	}
+	for _, pk := range meta.pkFields {
+		hasPK := false
+		for _, f := range fields {
+			hasPK = hasPK || f == pk
+		}
+		if !hasPK {
+			fields = append(fields, pk)
+		}
+	}
+	asc := "1" == condition[CondORDERType] || 1 == condition[CondORDERType]
+	return fields, asc
+}
+
+// AddCondCursor 为 sql 增加游标条件：(f1 > ?) OR (f1 = ? AND f2 > ?) ...，升序为 >，降序为 <
+func (that *BaseDao) AddCondCursor(whereSQL string, params []interface{}, alias string, fields []string, values []interface{}, asc bool) (string, []interface{}) {
+	op := "<"
+	if asc {
+		op = ">"
+	}
+	ors := make([]string, 0, len(fields))
+	for i := range fields {
+		ands := make([]string, 0, i+1)
+		for j := 0; j < i; j++ {
+			ands = append(ands, fmt.Sprintf("%s.%s = ?", alias, fields[j]))
+			params = append(params, values[j])
+		}
+		ands = append(ands, fmt.Sprintf("%s.%s %s ?", alias, fields[i], op))
+		params = append(params, values[i])
+		ors = append(ors, fmt.Sprintf("(%s)", strings.Join(ands, " AND ")))
+	}
+	cond := fmt.Sprintf("(%s)", strings.Join(ors, " OR "))
+	if strings.Contains(whereSQL, "WHERE ") || strings.Contains(whereSQL, "Where ") {
+		return fmt.Sprintf("%s AND %s ", whereSQL, cond), params
+	}
+	return fmt.Sprintf("%s WHERE %s ", whereSQL, cond), params
+}
+
+// FindListByCursorContext	标准：游标（keyset）分页查询列表，翻页性能不随页码下降。
+// condition 中 CondCursor 为上一页返回的游标（第一页为 "" 或不传），CondPageSize 为每页数量，
+// CondORDERField、CondORDERType 为排序（排序字段末尾自动追加主键），CondPageIndex、CondLimitBegin 被忽略。
+// 游标绑定表与条件，翻页过程中条件与排序不能改变，否则返回 ErrCursorInvalid。分表时条件需解析为一个物理表，否则返回 ErrShardSpan。
+// ctx context.Context	上下文
+// q Querier	*sql.DB 或 *sql.Tx
+// condition map[string]interface{}	查询条件
+// modPointer interface{}	model 的指针，用于取得表信息
+// listPointer interface{}	结果切片的指针，如 *[]*User
+// *CursorPage	上一页、下一页游标
+// error	err 不为 nil 时失败
+func (that *BaseDao) FindListByCursorContext(ctx context.Context, q Querier, condition map[string]interface{}, modPointer interface{}, listPointer interface{}) (*CursorPage, error) {
+	scope := cursorScope(getModelMeta(modPointer).tableName, condition)
+	meta, err := singleShardMeta(getModelMeta(modPointer), modPointer, condition)
+	if nil != err {
+		return nil, err
+	}
+	fields, asc := cursorOrder(meta, condition)
+
+	var token *cursorToken
+	if v, isOk := condition[CondCursor]; isOk && nil != v && "" != v {
+		token, err = decodeCursor(fmt.Sprint(v))
+		if nil != err {
+			return nil, err
+		}
+		if scope != token.Scope || token.Asc != asc || strings.Join(token.Fields, ",") != strings.Join(fields, ",") || len(token.Values) != len(fields) {
+			return nil, ErrCursorInvalid
+		}
+	}
+	backward := nil != token && token.Backward
+
+	// 向前翻页时反向查询，结果再反转
+	queryAsc := asc != backward
+	fieldStr, _ := (&BaseModel{}).GetModelFieldsToFieldStr(meta.alias, meta.listTableFields)
+	where, params, err := that.buildWhere(ctx, meta, condition)
+	if nil != err {
+		return nil, err
+	}
+	if nil != token {
+		where, params = that.AddCondCursor(where, params, meta.alias, fields, token.Values, queryAsc)
+	}
+	orderBy := "DESC"
+	if queryAsc {
+		orderBy = "ASC"
+	}
+	orders := make([]string, 0, len(fields))
+	for _, f := range fields {
+		orders = append(orders, fmt.Sprintf("%s.%s %s", meta.alias, f, orderBy))
+	}
+	_, size := LimitOffset(condition)
+	s := fmt.Sprintf("SELECT %s FROM %s AS %s %s ORDER BY %s LIMIT %d", fieldStr, meta.tableName, meta.alias, where, strings.Join(orders, ","), size+1)
+
+	list, err := that.queryModels(ctx, q, meta, OpFindList, s, params, modPointer)
+	if nil != err {
+		return nil, err
+	}
+	hasMore := list.Len() > size
+	if hasMore {
+		list = list.Slice(0, size)
+	}
+	if backward {
+		for i, j := 0, list.Len()-1; i < j; i, j = i+1, j-1 {
+			a, b := list.Index(i).Interface(), list.Index(j).Interface()
+			list.Index(i).Set(reflect.ValueOf(b))
+			list.Index(j).Set(reflect.ValueOf(a))
+		}
+	}
+	reflect.ValueOf(listPointer).Elem().Set(list)
+
+	page := &CursorPage{HasNext: hasMore, HasPrev: nil != token}
+	if backward {
+		page.HasNext = true
+		page.HasPrev = hasMore
+	}
+	if 0 == list.Len() {
+		return page, nil
+	}
+	if page.HasNext {
+		if page.Next, err = encodeCursor(rowCursor(meta, scope, fields, list.Index(list.Len()-1), asc, false)); nil != err {
+			return nil, err
+		}
+	}
+	if page.HasPrev {
+		if page.Prev, err = encodeCursor(rowCursor(meta, scope, fields, list.Index(0), asc, true)); nil != err {
+			return nil, err
+		}
+	}
+	return page, nil
+}
+
+// FindListByCursor	标准：游标分页查询列表，见 FindListByCursorContext
+func (that *BaseDao) FindListByCursor(q Querier, condition map[string]interface{}, modPointer interface{}, listPointer interface{}) (*CursorPage, error) {
+	return that.FindListByCursorContext(context.Background(), q, condition, modPointer, listPointer)
+}
+
+// rowCursor 取一行的排序值生成游标
+func rowCursor(meta *modelMeta, scope string, fields []string, row reflect.Value, asc, backward bool) *cursorToken {
+	row = row.Elem()
+	values := make([]interface{}, len(fields))
+	for i, f := range fields {
+		name, _, _ := meta.fieldByTable(f)
+		values[i] = cursorValue(row.FieldByName(name).Interface())
+	}
+	return &cursorToken{Fields: fields, Values: values, Asc: asc, Backward: backward, Scope: scope}
+}
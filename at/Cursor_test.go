@@ -0,0 +1,37 @@
+package at
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"testing"
+)
+
+func TestCursorBoundToTableAndCondition(t *testing.T) {
+	f, db := newFakeDB()
+	defer db.Close()
+	f.rows = func(string) ([]string, [][]driver.Value) {
+		return []string{"id", "name", "phone", "phone_bidx"}, [][]driver.Value{{int64(2), "tom", "", ""}, {int64(1), "tom", "", ""}}
+	}
+	dao := GetInstanceByBaseDao()
+	ctx := context.Background()
+	persons := make([]*testPerson, 0)
+	page, err := dao.FindListByCursorContext(ctx, db, map[string]interface{}{"name": "tom", CondPageSize: 1}, &testPerson{}, &persons)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if "" == page.Next {
+		t.Fatal("no next cursor")
+	}
+
+	if _, err = dao.FindListByCursorContext(ctx, db, map[string]interface{}{"name": "tom", CondPageSize: 1, CondCursor: page.Next}, &testPerson{}, &persons); nil != err {
+		t.Errorf("same query: %v", err)
+	}
+	if _, err = dao.FindListByCursorContext(ctx, db, map[string]interface{}{"name": "eve", CondPageSize: 1, CondCursor: page.Next}, &testPerson{}, &persons); !errors.Is(err, ErrCursorInvalid) {
+		t.Errorf("other condition: err = %v, want ErrCursorInvalid", err)
+	}
+	items := make([]*testItem, 0)
+	if _, err = dao.FindListByCursorContext(ctx, db, map[string]interface{}{"name": "tom", CondPageSize: 1, CondCursor: page.Next}, &testItem{}, &items); !errors.Is(err, ErrCursorInvalid) {
+		t.Errorf("other table: err = %v, want ErrCursorInvalid", err)
+	}
+}
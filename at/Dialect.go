@@ -0,0 +1,39 @@
+package at
+
+import "sync"
+
+// Dialect 数据库方言，影响字段信息读取、错误识别等与数据库相关的行为
+type Dialect int
+
+const (
+	DialectMySQL Dialect = iota
+	DialectSQLite
+	DialectPostgreSQL
+)
+
+func (that Dialect) String() string {
+	switch that {
+	case DialectSQLite:
+		return "sqlite"
+	case DialectPostgreSQL:
+		return "postgresql"
+	}
+	return "mysql"
+}
+
+var dialect = DialectMySQL
+var dialectLock sync.RWMutex
+
+// SetDialect 设置数据库方言，默认 MySQL
+func SetDialect(d Dialect) {
+	dialectLock.Lock()
+	defer dialectLock.Unlock()
+	dialect = d
+}
+
+// GetDialect 当前数据库方言
+func GetDialect() Dialect {
+	dialectLock.RLock()
+	defer dialectLock.RUnlock()
+	return dialect
+}
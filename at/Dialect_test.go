@@ -0,0 +1,26 @@
+package at
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestSetDialectConcurrent(t *testing.T) {
+	defer SetDialect(DialectMySQL)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			SetDialect(DialectSQLite)
+		}()
+		go func() {
+			defer wg.Done()
+			_ = GetDialect()
+		}()
+	}
+	wg.Wait()
+	if DialectSQLite != GetDialect() {
+		t.Errorf("dialect = %s", GetDialect())
+	}
+}
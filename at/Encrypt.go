@@ -0,0 +1,292 @@
+package at
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"strings"
+)
+
+// 字段加密：model 字段加 encrypt tag 后，写入前以 AES-GCM 加密，扫描时解密，字段类型为 string、*string 或 []byte。
+//
+//	Phone     string `json:"phone" table:"phone" encrypt:"phone_bidx"`
+//	PhoneBidx string `json:"-" table:"phone_bidx"`
+//
+// encrypt:"" 只加密；encrypt:"phone_bidx" 同时写入盲索引列 phone_bidx（HMAC-SHA256），
+// condition 中以 phone 为条件的等于、不等于查询自动改为 phone_bidx = BlindIndex(值)。
+// 密文格式为 "密钥ID:base64(nonce+密文)"，"" 与 NULL 不加密。
+const encryptTag = "encrypt"
+
+// AuditMask 审计记录、日志与 QueryEvent.Args 中加密字段的值
+const AuditMask = "***"
+
+// ErrKeyProviderNotSet 使用加密字段前未调用 SetKeyProvider
+var ErrKeyProviderNotSet = errors.New("error:key provider not set")
+
+// KeyProvider 提供加密密钥，支持按密钥 ID 轮换：新数据使用 CurrentKey 加密，旧数据按密文中的 ID 通过 Key 解密。
+// 密钥长度为 16、24 或 32 字节（AES-128、AES-192、AES-256）。
+type KeyProvider interface {
+	// CurrentKey 当前用于加密的密钥及其 ID，ID 不能包含 ":"
+	CurrentKey() (keyID string, key []byte, err error)
+	// Key 根据 ID 取得解密密钥
+	Key(keyID string) ([]byte, error)
+	// BlindIndexKey 盲索引的 HMAC 密钥，更换后已有的盲索引全部失效，不参与轮换
+	BlindIndexKey() ([]byte, error)
+}
+
+var keyProvider KeyProvider
+
+// SetKeyProvider 设置加密字段使用的 KeyProvider
+func SetKeyProvider(p KeyProvider) {
+	keyProvider = p
+}
+
+// StaticKeyProvider 固定密钥的 KeyProvider，适合从配置读取密钥
+type StaticKeyProvider struct {
+	currentID string
+	keys      map[string][]byte
+	blindKey  []byte
+}
+
+// NewStaticKeyProvider 创建固定密钥的 KeyProvider
+// currentID string	当前加密使用的密钥 ID
+// keys map[string][]byte	全部密钥，k=密钥 ID，轮换后旧密钥需保留用于解密
+// blindKey []byte	盲索引密钥
+func NewStaticKeyProvider(currentID string, keys map[string][]byte, blindKey []byte) *StaticKeyProvider {
+	return &StaticKeyProvider{currentID: currentID, keys: keys, blindKey: blindKey}
+}
+
+func (that *StaticKeyProvider) CurrentKey() (string, []byte, error) {
+	key, err := that.Key(that.currentID)
+	return that.currentID, key, err
+}
+
+func (that *StaticKeyProvider) Key(keyID string) ([]byte, error) {
+	key, isOk := that.keys[keyID]
+	if !isOk {
+		return nil, errors.New(fmt.Sprintf("error:encrypt key %s not found", keyID))
+	}
+	return key, nil
+}
+
+func (that *StaticKeyProvider) BlindIndexKey() ([]byte, error) {
+	if 0 == len(that.blindKey) {
+		return nil, errors.New("error:blind index key not set")
+	}
+	return that.blindKey, nil
+}
+
+// Encrypt 使用当前密钥加密
+func Encrypt(plain []byte) (string, error) {
+	if nil == keyProvider {
+		return "", ErrKeyProviderNotSet
+	}
+	keyID, key, err := keyProvider.CurrentKey()
+	if nil != err {
+		return "", err
+	}
+	gcm, err := newGCM(key)
+	if nil != err {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = rand.Read(nonce); nil != err {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, plain, nil)
+	return fmt.Sprintf("%s:%s", keyID, base64.StdEncoding.EncodeToString(sealed)), nil
+}
+
+// Decrypt 按密文中的密钥 ID 解密
+func Decrypt(s string) ([]byte, error) {
+	if nil == keyProvider {
+		return nil, ErrKeyProviderNotSet
+	}
+	inx := strings.Index(s, ":")
+	if -1 == inx {
+		return nil, errors.New("error:invalid ciphertext")
+	}
+	key, err := keyProvider.Key(s[:inx])
+	if nil != err {
+		return nil, err
+	}
+	sealed, err := base64.StdEncoding.DecodeString(s[inx+1:])
+	if nil != err {
+		return nil, err
+	}
+	gcm, err := newGCM(key)
+	if nil != err {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, errors.New("error:invalid ciphertext")
+	}
+	return gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if nil != err {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// BlindIndex 计算盲索引，相同明文得到相同结果，用于等值查询
+func BlindIndex(plain string) (string, error) {
+	if nil == keyProvider {
+		return "", ErrKeyProviderNotSet
+	}
+	key, err := keyProvider.BlindIndexKey()
+	if nil != err {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(plain))
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// plainText 字段值转为明文，nil 与 nil 指针返回 false
+func plainText(value interface{}) (string, bool) {
+	value = derefValue(value)
+	if nil == value {
+		return "", false
+	}
+	switch v := value.(type) {
+	case []byte:
+		if nil == v {
+			return "", false
+		}
+		return string(v), true
+	case string:
+		return v, true
+	}
+	return fmt.Sprint(value), true
+}
+
+// encryptValue 延迟加密的参数，有 codec 时先编码再加密，错误由 database/sql 在执行时返回
+type encryptValue struct {
+	codec string
+	value interface{}
+}
+
+func (that encryptValue) Value() (driver.Value, error) {
+	v, err := EncodeValue(that.codec, that.value)
+	if nil != err {
+		return nil, err
+	}
+	plain, isOk := plainText(v)
+	if !isOk {
+		return nil, nil
+	}
+	if "" == plain {
+		return "", nil
+	}
+	return Encrypt([]byte(plain))
+}
+
+// String 日志与 QueryEvent.Args 输出时不暴露明文
+func (that encryptValue) String() string {
+	return AuditMask
+}
+
+// LogValue 见 String
+func (that encryptValue) LogValue() slog.Value {
+	return slog.StringValue(AuditMask)
+}
+
+// blindIndexValue 延迟计算的盲索引参数
+type blindIndexValue struct {
+	value interface{}
+}
+
+func (that blindIndexValue) Value() (driver.Value, error) {
+	plain, isOk := plainText(that.value)
+	if !isOk {
+		return nil, nil
+	}
+	if "" == plain {
+		return "", nil
+	}
+	return BlindIndex(plain)
+}
+
+// String 日志与 QueryEvent.Args 输出时不暴露明文
+func (that blindIndexValue) String() string {
+	return AuditMask
+}
+
+// LogValue 见 String
+func (that blindIndexValue) LogValue() slog.Value {
+	return slog.StringValue(AuditMask)
+}
+
+// decryptScanner 扫描时解密到字段，有 codec 时解密后再由 codec 解码
+type decryptScanner struct {
+	codec string
+	dest  interface{}
+}
+
+func (that *decryptScanner) Scan(src interface{}) error {
+	var plain interface{}
+	if nil != src {
+		s, err := srcString(src)
+		if nil != err {
+			return err
+		}
+		if "" != s {
+			b, err := Decrypt(s)
+			if nil != err {
+				return err
+			}
+			s = string(b)
+		}
+		plain = s
+	}
+	if "" != that.codec {
+		c, err := mustCodec(that.codec)
+		if nil != err {
+			return err
+		}
+		return c.Decode(plain, that.dest)
+	}
+
+	dest := reflect.ValueOf(that.dest).Elem()
+	if nil == plain {
+		dest.Set(reflect.Zero(dest.Type()))
+		return nil
+	}
+	s := plain.(string)
+	switch {
+	case reflect.String == dest.Kind():
+		dest.SetString(s)
+	case reflect.Ptr == dest.Kind() && reflect.String == dest.Type().Elem().Kind():
+		p := reflect.New(dest.Type().Elem())
+		p.Elem().SetString(s)
+		dest.Set(p)
+	case reflect.Slice == dest.Kind() && reflect.Uint8 == dest.Type().Elem().Kind():
+		dest.SetBytes([]byte(s))
+	default:
+		return errors.New(fmt.Sprintf("error:encrypt field must be string, *string or []byte, got %s", dest.Type()))
+	}
+	return nil
+}
+
+// blindIndexSource 盲索引列对应的加密字段
+func blindIndexSource(tableField string, tableFields map[string]TableField) (string, bool) {
+	for k, v := range tableFields {
+		if "" != v.FieldBlindIndex && tableField == v.FieldBlindIndex {
+			return k, true
+		}
+	}
+	return "", false
+}
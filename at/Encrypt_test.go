@@ -0,0 +1,67 @@
+package at
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+type bufferLogs struct {
+	buf bytes.Buffer
+}
+
+func (that *bufferLogs) Debug(msg string) {
+	that.buf.WriteString(msg + "\n")
+}
+
+func (that *bufferLogs) Error(msg string, err error) {
+	that.buf.WriteString(msg + " " + err.Error() + "\n")
+}
+
+func TestEncryptArgsNotLogged(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+	SetKeyProvider(NewStaticKeyProvider("k1", map[string][]byte{"k1": key}, key))
+	defer SetKeyProvider(nil)
+
+	var out bytes.Buffer
+	InitDaoSlog(slog.NewTextHandler(&out, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	defer InitDao(nil)
+	slow := &bufferLogs{}
+	AddObserver(NewSlowQueryLogger(0, slow))
+	defer ClearObserver()
+
+	f, db := newFakeDB()
+	defer db.Close()
+	const phone = "13800000000"
+	mod := &testPerson{Id: 1, Name: "tom", Phone: phone}
+	err := GetInstanceByBaseDao().Transaction(db, func(tx *sql.Tx) error {
+		_, err := GetInstanceByBaseDao().AddModelContext(context.Background(), tx, mod)
+		return err
+	})
+	if nil != err {
+		t.Fatal(err)
+	}
+	list := make([]*testPerson, 0)
+	if err := GetInstanceByBaseDao().FindListContext(context.Background(), db, map[string]interface{}{"phone": phone}, &testPerson{}, &list); nil != err {
+		t.Fatal(err)
+	}
+
+	for name, s := range map[string]string{"slog": out.String(), "slow query": slow.buf.String()} {
+		if !strings.Contains(s, AuditMask) {
+			t.Errorf("%s log has no mask: %s", name, s)
+		}
+		if strings.Contains(s, phone) {
+			t.Errorf("%s log contains plaintext: %s", name, s)
+		}
+	}
+	for _, args := range f.args {
+		for _, a := range args {
+			if s, isOk := a.(string); isOk && strings.Contains(s, phone) {
+				t.Errorf("driver received plaintext %q", s)
+			}
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package at
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// 标准错误，标准方法与执行 SQL 返回的错误为 *DaoError，可以用 errors.Is 判断：
+//
+//	if errors.Is(err, at.ErrDuplicateKey) {
+//		...
+//	}
+//
+// 数据库驱动的原始错误同样可以用 errors.As 取出，如 *mysql.MySQLError。
+var (
+	// ErrNoRowsAffected UpdateByID、DeleteByID、UpdateMustAffected 没有受影响行
+	ErrNoRowsAffected = errors.New("error:no rows affected")
+	// ErrInsertFailed 插入后 LastInsertId 为 0
+	ErrInsertFailed = errors.New("error:insert fail")
+	// ErrNotFound 查询一行时没有数据，同时可以用 errors.Is(err, sql.ErrNoRows) 判断
+	ErrNotFound = errors.New("error:not found")
+	// ErrDuplicateKey 主键或唯一索引重复
+	ErrDuplicateKey = errors.New("error:duplicate key")
+	// ErrForeignKey 外键约束失败
+	ErrForeignKey = errors.New("error:foreign key constraint")
+	// ErrDeadlock 死锁或锁冲突，事务可以重试
+	ErrDeadlock = errors.New("error:deadlock")
+)
+
+// DaoError 带表名与操作的错误
+type DaoError struct {
+	Table string
+	// Op 操作，见 Observer.go 中的 Op 常量
+	Op string
+	// Kind 标准错误，如 ErrDuplicateKey，无法分类时为 nil
+	Kind error
+	// Err 原始错误，如驱动返回的错误，没有时为 nil
+	Err error
+}
+
+func (that *DaoError) Error() string {
+	kind := that.Kind
+	if nil == kind {
+		kind = that.Err
+	}
+	msg := fmt.Sprintf("%s op=%s", kind.Error(), that.Op)
+	if "" != that.Table {
+		msg = fmt.Sprintf("%s table=%s", msg, that.Table)
+	}
+	if nil != that.Kind && nil != that.Err {
+		msg = fmt.Sprintf("%s: %s", msg, that.Err.Error())
+	}
+	return msg
+}
+
+// Unwrap errors.Is、errors.As 同时匹配 Kind 与 Err
+func (that *DaoError) Unwrap() []error {
+	errs := make([]error, 0, 2)
+	if nil != that.Kind {
+		errs = append(errs, that.Kind)
+	}
+	if nil != that.Err {
+		errs = append(errs, that.Err)
+	}
+	return errs
+}
+
+// newDaoError 创建标准错误
+func newDaoError(tableName, op string, kind error) error {
+	return &DaoError{Table: tableName, Op: op, Kind: kind}
+}
+
+// wrapDaoError 分类并包装执行 SQL 的错误，已经是 *DaoError 时原样返回
+func wrapDaoError(tableName, op string, err error) error {
+	if nil == err {
+		return nil
+	}
+	var de *DaoError
+	if errors.As(err, &de) {
+		return err
+	}
+	return &DaoError{Table: tableName, Op: op, Kind: ClassifyError(err), Err: err}
+}
+
+// ErrorClassifier 将驱动错误映射为标准错误，无法识别时返回 nil
+type ErrorClassifier func(err error) error
+
+var errorClassifiers []ErrorClassifier
+var errorClassifiersLock sync.RWMutex
+
+// RegisterErrorClassifier 注册错误分类，先于内置的 MySQL、PostgreSQL、SQLite 分类执行
+func RegisterErrorClassifier(classifier ErrorClassifier) {
+	errorClassifiersLock.Lock()
+	defer errorClassifiersLock.Unlock()
+	errorClassifiers = append(errorClassifiers, classifier)
+}
+
+// ClassifyError 将错误映射为标准错误，无法识别时返回 nil。
+// 内置分类通过反射读取驱动错误的字段，不依赖驱动包：
+// MySQL 读取类型名为 MySQLError 的 Number（go-sql-driver/mysql），PostgreSQL 读取 Code（lib/pq、pgx），
+// SQLite 读取 ExtendedCode、Code 字段（mattn/go-sqlite3）或 Code() 方法（modernc.org/sqlite）。
+// 其它驱动或字段含义不同的错误类型（如 Code 字符串不是 SQLSTATE）可能被误判，需用 RegisterErrorClassifier 先行分类
+func ClassifyError(err error) error {
+	if nil == err {
+		return nil
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	errorClassifiersLock.RLock()
+	classifiers := errorClassifiers
+	errorClassifiersLock.RUnlock()
+	for _, c := range classifiers {
+		if kind := c(err); nil != kind {
+			return kind
+		}
+	}
+	for e := err; nil != e; e = errors.Unwrap(e) {
+		if kind := classifyDriverError(e); nil != kind {
+			return kind
+		}
+	}
+	return nil
+}
+
+// MySQL 错误号
+var mysqlErrors = map[int64]error{
+	1062: ErrDuplicateKey, // ER_DUP_ENTRY
+	1586: ErrDuplicateKey, // ER_DUP_ENTRY_WITH_KEY_NAME
+	1216: ErrForeignKey,   // ER_NO_REFERENCED_ROW
+	1217: ErrForeignKey,   // ER_ROW_IS_REFERENCED
+	1451: ErrForeignKey,   // ER_ROW_IS_REFERENCED_2
+	1452: ErrForeignKey,   // ER_NO_REFERENCED_ROW_2
+	1213: ErrDeadlock,     // ER_LOCK_DEADLOCK
+	1205: ErrDeadlock,     // ER_LOCK_WAIT_TIMEOUT
+}
+
+// PostgreSQL SQLSTATE
+var postgresErrors = map[string]error{
+	"23505": ErrDuplicateKey, // unique_violation
+	"23503": ErrForeignKey,   // foreign_key_violation
+	"40P01": ErrDeadlock,     // deadlock_detected
+	"40001": ErrDeadlock,     // serialization_failure
+	"55P03": ErrDeadlock,     // lock_not_available
+}
+
+// SQLite 扩展错误码与基本错误码
+var sqliteErrors = map[int64]error{
+	2067: ErrDuplicateKey, // SQLITE_CONSTRAINT_UNIQUE
+	1555: ErrDuplicateKey, // SQLITE_CONSTRAINT_PRIMARYKEY
+	787:  ErrForeignKey,   // SQLITE_CONSTRAINT_FOREIGNKEY
+	5:    ErrDeadlock,     // SQLITE_BUSY
+	6:    ErrDeadlock,     // SQLITE_LOCKED
+}
+
+// classifyDriverError 按驱动错误的字段分类，MySQL 按类型名识别，避免其它错误类型的 Number 字段被当作 MySQL 错误号
+func classifyDriverError(err error) error {
+	v := reflect.ValueOf(err)
+	if reflect.Ptr == v.Kind() {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if reflect.Struct != v.Kind() {
+		return nil
+	}
+	// MySQL：*mysql.MySQLError 的 Number uint16
+	if f := v.FieldByName("Number"); "MySQLError" == v.Type().Name() && f.IsValid() && isIntKind(f.Kind()) {
+		return mysqlErrors[reflectInt(f)]
+	}
+	// SQLite（mattn）：ExtendedCode 与 Code 为整型
+	if f := v.FieldByName("ExtendedCode"); f.IsValid() && isIntKind(f.Kind()) {
+		if kind, isOk := sqliteErrors[reflectInt(f)]; isOk {
+			return kind
+		}
+		if c := v.FieldByName("Code"); c.IsValid() && isIntKind(c.Kind()) {
+			return sqliteErrors[reflectInt(c)]
+		}
+		return nil
+	}
+	// PostgreSQL：Code 为 SQLSTATE 字符串
+	if f := v.FieldByName("Code"); f.IsValid() && reflect.String == f.Kind() {
+		return postgresErrors[f.String()]
+	}
+	// SQLite（modernc）：Code() int
+	if c, isOk := err.(interface{ Code() int }); isOk {
+		code := int64(c.Code())
+		if kind, isOk := sqliteErrors[code]; isOk {
+			return kind
+		}
+		return sqliteErrors[code&0xff]
+	}
+	return nil
+}
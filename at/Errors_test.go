@@ -0,0 +1,156 @@
+package at
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// 以下类型模拟各驱动的错误，字段与驱动一致
+
+// MySQLError go-sql-driver/mysql
+type MySQLError struct {
+	Number  uint16
+	Message string
+}
+
+func (that *MySQLError) Error() string {
+	return that.Message
+}
+
+// sqliteError mattn/go-sqlite3
+type sqliteError struct {
+	Code         int
+	ExtendedCode int
+}
+
+func (that sqliteError) Error() string {
+	return "sqlite"
+}
+
+// pqError lib/pq、pgx
+type pqError struct {
+	Code string
+}
+
+func (that *pqError) Error() string {
+	return "pq"
+}
+
+// moderncError modernc.org/sqlite
+type moderncError struct {
+	code int
+}
+
+func (that *moderncError) Error() string {
+	return "modernc"
+}
+
+func (that *moderncError) Code() int {
+	return that.code
+}
+
+// numberError 与数据库无关、有 Number 字段的错误
+type numberError struct {
+	Number int
+	Code   int
+}
+
+func (that *numberError) Error() string {
+	return "number"
+}
+
+func TestClassifyError(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"no rows", sql.ErrNoRows, ErrNotFound},
+		{"mysql duplicate", &MySQLError{Number: 1062}, ErrDuplicateKey},
+		{"mysql foreign key", &MySQLError{Number: 1452}, ErrForeignKey},
+		{"mysql deadlock", &MySQLError{Number: 1213}, ErrDeadlock},
+		{"mysql unknown", &MySQLError{Number: 1146}, nil},
+		{"sqlite extended code", sqliteError{Code: 19, ExtendedCode: 2067}, ErrDuplicateKey},
+		{"sqlite primary key", sqliteError{Code: 19, ExtendedCode: 1555}, ErrDuplicateKey},
+		{"sqlite foreign key", sqliteError{Code: 19, ExtendedCode: 787}, ErrForeignKey},
+		{"sqlite base code", sqliteError{Code: 5, ExtendedCode: 261}, ErrDeadlock},
+		{"postgres duplicate", &pqError{Code: "23505"}, ErrDuplicateKey},
+		{"postgres serialization", &pqError{Code: "40001"}, ErrDeadlock},
+		{"postgres unknown", &pqError{Code: "42P01"}, nil},
+		{"modernc extended code", &moderncError{code: 2067}, ErrDuplicateKey},
+		{"modernc base code", &moderncError{code: 261}, ErrDeadlock},
+		{"wrapped", fmt.Errorf("insert: %w", &MySQLError{Number: 1062}), ErrDuplicateKey},
+		{"wrapped twice", fmt.Errorf("a: %w", fmt.Errorf("b: %w", &pqError{Code: "23503"})), ErrForeignKey},
+		{"unrelated Number field", &numberError{Number: 1062}, nil},
+		{"nil pointer", (*pqError)(nil), nil},
+		{"plain", errors.New("plain"), nil},
+	}
+	for _, c := range cases {
+		if got := ClassifyError(c.err); got != c.want {
+			t.Errorf("%s: ClassifyError = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestClassifyErrorRegisteredFirst(t *testing.T) {
+	errorClassifiersLock.Lock()
+	saved := errorClassifiers
+	errorClassifiersLock.Unlock()
+	defer func() {
+		errorClassifiersLock.Lock()
+		errorClassifiers = saved
+		errorClassifiersLock.Unlock()
+	}()
+
+	RegisterErrorClassifier(func(err error) error {
+		var e *numberError
+		if errors.As(err, &e) && 7 == e.Code {
+			return ErrDeadlock
+		}
+		var m *MySQLError
+		if errors.As(err, &m) {
+			return ErrForeignKey
+		}
+		return nil
+	})
+	if kind := ClassifyError(&numberError{Code: 7}); ErrDeadlock != kind {
+		t.Errorf("custom = %v, want ErrDeadlock", kind)
+	}
+	// 注册的分类先于内置分类执行
+	if kind := ClassifyError(&MySQLError{Number: 1062}); ErrForeignKey != kind {
+		t.Errorf("mysql = %v, want the registered classifier's result", kind)
+	}
+	if kind := ClassifyError(&pqError{Code: "23505"}); ErrDuplicateKey != kind {
+		t.Errorf("postgres = %v, want the built-in result when the registered classifier returns nil", kind)
+	}
+}
+
+func TestClassifySQLiteDriverError(t *testing.T) {
+	db, err := sql.Open("sqlite3", "file:"+filepath.Join(t.TempDir(), "err.db")+"?_foreign_keys=1")
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for _, s := range []string{
+		"CREATE TABLE parent (id INTEGER PRIMARY KEY)",
+		"CREATE TABLE child (id INTEGER PRIMARY KEY, parent_id INTEGER REFERENCES parent(id))",
+		"INSERT INTO parent (id) VALUES (1)",
+	} {
+		if _, err = db.Exec(s); nil != err {
+			t.Fatal(err)
+		}
+	}
+	_, err = db.Exec("INSERT INTO parent (id) VALUES (1)")
+	if kind := ClassifyError(err); ErrDuplicateKey != kind {
+		t.Errorf("duplicate %v = %v, want ErrDuplicateKey", err, kind)
+	}
+	_, err = db.Exec("INSERT INTO child (id, parent_id) VALUES (1, 2)")
+	if kind := ClassifyError(err); ErrForeignKey != kind {
+		t.Errorf("foreign key %v = %v, want ErrForeignKey", err, kind)
+	}
+}
@@ -0,0 +1,177 @@
+package at
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"sync"
+)
+
+// fakeDB 测试用的数据库驱动，记录执行的语句，可以让开启、提交、回滚事务失败
+type fakeDB struct {
+	mu           sync.Mutex
+	execs        []string
+	args         [][]driver.Value
+	beginOpts    []driver.TxOptions
+	commits      int
+	rollbacks    int
+	failBegin    error
+	failCommit   error
+	failRollback error
+	// rows 查询返回的列与数据，为 nil 时返回空结果
+	rows func(query string) ([]string, [][]driver.Value)
+}
+
+func newFakeDB() (*fakeDB, *sql.DB) {
+	f := &fakeDB{}
+	return f, sql.OpenDB(f)
+}
+
+func (that *fakeDB) Connect(context.Context) (driver.Conn, error) {
+	return &fakeConn{db: that}, nil
+}
+
+func (that *fakeDB) Driver() driver.Driver {
+	return fakeDriver{db: that}
+}
+
+type fakeDriver struct {
+	db *fakeDB
+}
+
+func (that fakeDriver) Open(string) (driver.Conn, error) {
+	return &fakeConn{db: that.db}, nil
+}
+
+type fakeConn struct {
+	db *fakeDB
+}
+
+func (that *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{conn: that, query: query}, nil
+}
+
+func (that *fakeConn) Close() error {
+	return nil
+}
+
+func (that *fakeConn) Begin() (driver.Tx, error) {
+	return that.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (that *fakeConn) BeginTx(_ context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	that.db.mu.Lock()
+	defer that.db.mu.Unlock()
+	if nil != that.db.failBegin {
+		return nil, that.db.failBegin
+	}
+	that.db.beginOpts = append(that.db.beginOpts, opts)
+	return &fakeTx{db: that.db}, nil
+}
+
+func (that *fakeConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	that.db.record(query, args)
+	return fakeResult{}, nil
+}
+
+// fakeResult LastInsertId、RowsAffected 均为 1
+type fakeResult struct{}
+
+func (fakeResult) LastInsertId() (int64, error) {
+	return 1, nil
+}
+
+func (fakeResult) RowsAffected() (int64, error) {
+	return 1, nil
+}
+
+func (that *fakeConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	that.db.record(query, args)
+	r := &fakeRows{}
+	if nil != that.db.rows {
+		r.columns, r.values = that.db.rows(query)
+	}
+	return r, nil
+}
+
+func (that *fakeDB) record(query string, args []driver.NamedValue) {
+	that.mu.Lock()
+	defer that.mu.Unlock()
+	values := make([]driver.Value, len(args))
+	for i, a := range args {
+		values[i] = a.Value
+	}
+	that.execs = append(that.execs, query)
+	that.args = append(that.args, values)
+}
+
+type fakeStmt struct {
+	conn  *fakeConn
+	query string
+}
+
+func (that *fakeStmt) Close() error {
+	return nil
+}
+
+func (that *fakeStmt) NumInput() int {
+	return -1
+}
+
+func namedValues(args []driver.Value) []driver.NamedValue {
+	named := make([]driver.NamedValue, len(args))
+	for i, a := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: a}
+	}
+	return named
+}
+
+func (that *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return that.conn.ExecContext(context.Background(), that.query, namedValues(args))
+}
+
+func (that *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return that.conn.QueryContext(context.Background(), that.query, namedValues(args))
+}
+
+type fakeTx struct {
+	db *fakeDB
+}
+
+func (that *fakeTx) Commit() error {
+	that.db.mu.Lock()
+	defer that.db.mu.Unlock()
+	that.db.commits++
+	return that.db.failCommit
+}
+
+func (that *fakeTx) Rollback() error {
+	that.db.mu.Lock()
+	defer that.db.mu.Unlock()
+	that.db.rollbacks++
+	return that.db.failRollback
+}
+
+type fakeRows struct {
+	columns []string
+	values  [][]driver.Value
+	inx     int
+}
+
+func (that *fakeRows) Columns() []string {
+	return that.columns
+}
+
+func (that *fakeRows) Close() error {
+	return nil
+}
+
+func (that *fakeRows) Next(dest []driver.Value) error {
+	if that.inx >= len(that.values) {
+		return io.EOF
+	}
+	copy(dest, that.values[that.inx])
+	that.inx++
+	return nil
+}
@@ -0,0 +1,343 @@
+package at
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// Handler 为一个 model 提供 REST 接口，读写通过 BaseService（见 SetStorage）：
+//
+//	GET    /        列表，参数见 Binder（不支持 condCursor），返回 {"list": [...], "total": n}
+//	POST   /        新增，body 为 model 的 JSON，返回 201 与 model
+//	GET    /{id}    查询
+//	PUT    /{id}    修改，body 覆盖已存在的数据（PATCH 相同），返回 model，数据没有变化时同样返回 200
+//	DELETE /{id}    删除，返回 204
+//
+// 联合主键的 id 按主键顺序以 , 分隔。挂载时去掉前缀：
+//
+//	mux.Handle("/users/", http.StripPrefix("/users", at.NewHandler(&User{})))
+type Handler struct {
+	// Binder 列表参数的转换与白名单
+	Binder *Binder
+	// Service 读写数据，默认为 GetInstanceByBaseService()
+	Service *BaseService
+	// Authorize 每个操作执行前调用，返回错误时不执行，错误实现 StatusCode() int 时使用其状态码，否则为 403。
+	// op 见 HandlerList 等常量；target 在列表时为 condition，可以修改以限定范围；
+	// 查询、删除时为已存在的数据，新增时为请求的数据；
+	// 修改时 op 均为 HandlerUpdate 并调用两次：先为已存在的数据，再为写入请求 body 后将要保存的数据（同一实例），
+	// 两次都需要通过，分别检查能否修改这一行与能否改成这些值。
+	// 新增、修改时请求不能写入只读字段（生成的主键、创建时间、最后更新、删除时间、租户），
+	// 新增时为零值，修改时保留已存在的值，其它字段需要在 Authorize 中检查
+	Authorize func(r *http.Request, op string, target interface{}) error
+	modType   reflect.Type
+}
+
+// Handler 的操作名，Authorize 的 op 参数
+const (
+	HandlerList   = "list"
+	HandlerGet    = "get"
+	HandlerCreate = "create"
+	HandlerUpdate = "update"
+	HandlerDelete = "delete"
+)
+
+// ErrForbidden Authorize 可以返回的标准错误
+var ErrForbidden = errors.New("error:forbidden")
+
+var errMethodNotAllowed = errors.New("error:method not allowed")
+
+// NewHandler 创建 model 的 REST Handler，列表使用默认的 Binder
+// modPointer interface{}	model 指针，只用于取得类型
+func NewHandler(modPointer interface{}) *Handler {
+	return &Handler{
+		Binder:  &Binder{},
+		Service: GetInstanceByBaseService(),
+		modType: reflect.TypeOf(modPointer).Elem(),
+	}
+}
+
+func (that *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	id := strings.Trim(r.URL.Path, "/")
+	switch {
+	case "" == id && http.MethodGet == r.Method:
+		that.list(w, r)
+	case "" == id && http.MethodPost == r.Method:
+		that.create(w, r)
+	case "" != id && http.MethodGet == r.Method:
+		that.get(w, r, id)
+	case "" != id && (http.MethodPut == r.Method || http.MethodPatch == r.Method):
+		that.update(w, r, id)
+	case "" != id && http.MethodDelete == r.Method:
+		that.delete(w, r, id)
+	case "" == id:
+		w.Header().Set("Allow", "GET, POST")
+		that.writeError(w, r, "", errMethodNotAllowed, http.StatusMethodNotAllowed)
+	default:
+		w.Header().Set("Allow", "GET, PUT, PATCH, DELETE")
+		that.writeError(w, r, "", errMethodNotAllowed, http.StatusMethodNotAllowed)
+	}
+}
+
+func (that *Handler) newModel() interface{} {
+	return reflect.New(that.modType).Interface()
+}
+
+func (that *Handler) list(w http.ResponseWriter, r *http.Request) {
+	mod := that.newModel()
+	condition, err := that.Binder.Bind(r, mod)
+	if nil != err {
+		that.writeError(w, r, HandlerList, err, 0)
+		return
+	}
+	// 列表使用页码分页，游标分页需要自己调用 BaseDao.FindListByCursorContext
+	if _, isOk := condition[CondCursor]; isOk {
+		that.writeError(w, r, HandlerList, BindErrors{{Param: CondCursor, Message: "not supported, use condPageIndex"}}, 0)
+		return
+	}
+	if err = that.authorize(r, HandlerList, condition); nil != err {
+		that.writeError(w, r, HandlerList, err, http.StatusForbidden)
+		return
+	}
+	list := reflect.New(reflect.SliceOf(reflect.PtrTo(that.modType)))
+	total, err := that.Service.PageContext(r.Context(), condition, mod, list.Interface())
+	if nil != err {
+		that.writeError(w, r, HandlerList, err, 0)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"list": list.Elem().Interface(), "total": total})
+}
+
+func (that *Handler) get(w http.ResponseWriter, r *http.Request, id string) {
+	mod, isOk := that.load(w, r, HandlerGet, id)
+	if !isOk {
+		return
+	}
+	writeJSON(w, http.StatusOK, mod)
+}
+
+func (that *Handler) create(w http.ResponseWriter, r *http.Request) {
+	mod := that.newModel()
+	if err := decodeBody(w, r, mod); nil != err {
+		that.writeError(w, r, HandlerCreate, err, 0)
+		return
+	}
+	keepReadOnly(mod, readOnlyValues(that.newModel()))
+	if err := that.authorize(r, HandlerCreate, mod); nil != err {
+		that.writeError(w, r, HandlerCreate, err, http.StatusForbidden)
+		return
+	}
+	if _, err := that.Service.AddModelContext(r.Context(), mod); nil != err {
+		that.writeError(w, r, HandlerCreate, err, 0)
+		return
+	}
+	writeJSON(w, http.StatusCreated, mod)
+}
+
+func (that *Handler) update(w http.ResponseWriter, r *http.Request, id string) {
+	mod, isOk := that.load(w, r, HandlerUpdate, id)
+	if !isOk {
+		return
+	}
+	readOnly := readOnlyValues(mod)
+	if err := decodeBody(w, r, mod); nil != err {
+		that.writeError(w, r, HandlerUpdate, err, 0)
+		return
+	}
+	// 主键以路径为准，只读字段保留已存在的值
+	if err := that.setPK(mod, id); nil != err {
+		that.writeError(w, r, HandlerUpdate, err, 0)
+		return
+	}
+	keepReadOnly(mod, readOnly)
+	if err := that.authorize(r, HandlerUpdate, mod); nil != err {
+		that.writeError(w, r, HandlerUpdate, err, http.StatusForbidden)
+		return
+	}
+	if _, err := that.Service.UpdateByIDContext(r.Context(), mod); nil != err {
+		// 受影响行数为 0 时可能是数据没有变化（MySQL），也可能是 load 之后数据已被删除，
+		// 重新查询，仍然存在时按成功处理，否则为 404
+		if errors.Is(err, ErrNoRowsAffected) {
+			err = that.exists(r, id)
+		}
+		if nil != err {
+			that.writeError(w, r, HandlerUpdate, err, 0)
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, mod)
+}
+
+// exists 路径中的主键对应的数据是否存在，不存在时返回 ErrNotFound
+func (that *Handler) exists(r *http.Request, id string) error {
+	mod := that.newModel()
+	if err := that.setPK(mod, id); nil != err {
+		return err
+	}
+	return that.Service.GetContext(r.Context(), mod)
+}
+
+func (that *Handler) delete(w http.ResponseWriter, r *http.Request, id string) {
+	mod, isOk := that.load(w, r, HandlerDelete, id)
+	if !isOk {
+		return
+	}
+	if _, err := that.Service.DeleteByIDContext(r.Context(), mod); nil != err {
+		that.writeError(w, r, HandlerDelete, err, 0)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// load 按路径中的主键查询并授权，失败时已写入响应
+func (that *Handler) load(w http.ResponseWriter, r *http.Request, op, id string) (interface{}, bool) {
+	mod := that.newModel()
+	if err := that.setPK(mod, id); nil != err {
+		that.writeError(w, r, op, err, 0)
+		return nil, false
+	}
+	if err := that.Service.GetContext(r.Context(), mod); nil != err {
+		that.writeError(w, r, op, err, 0)
+		return nil, false
+	}
+	if err := that.authorize(r, op, mod); nil != err {
+		that.writeError(w, r, op, err, http.StatusForbidden)
+		return nil, false
+	}
+	return mod, true
+}
+
+func (that *Handler) authorize(r *http.Request, op string, target interface{}) error {
+	if nil == that.Authorize {
+		return nil
+	}
+	return that.Authorize(r, op, target)
+}
+
+// setPK 将路径中的 id 按主键类型写入 model
+func (that *Handler) setPK(modPointer interface{}, id string) error {
+	meta := getModelMeta(modPointer)
+	val := reflect.ValueOf(modPointer).Elem()
+	ids := strings.Split(id, ",")
+	if len(meta.pkFields) != len(ids) {
+		return &BindError{Param: "id", Message: fmt.Sprintf("must have %d values", len(meta.pkFields))}
+	}
+	for i, f := range meta.pkFields {
+		name, _, _ := meta.fieldByTable(f)
+		fv := val.FieldByName(name)
+		v, err := bindFieldValue(fv.Type(), ids[i])
+		if nil != err {
+			return &BindError{Param: "id", Message: err.Error()}
+		}
+		rv := reflect.ValueOf(v)
+		if !rv.Type().ConvertibleTo(fv.Type()) {
+			return &BindError{Param: "id", Message: fmt.Sprintf("unsupported primary key type %s", fv.Type())}
+		}
+		fv.Set(rv.Convert(fv.Type()))
+	}
+	return nil
+}
+
+// isReadOnlyField 请求不能写入的字段：生成的主键、创建时间、最后更新、删除时间、租户
+func isReadOnlyField(meta *modelMeta, inx int, tf TableField) bool {
+	switch tf.FieldProperty {
+	case PropertyCreateTime, PropertyUpdateTime, PropertyDeleteTime, PropertyTenant:
+		return true
+	}
+	return isPKTableField(inx, tf.FieldNameByTable, meta.pkFields) && (meta.pkAutoIncrement() || "" != tf.FieldIDGen)
+}
+
+// readOnlyValues 只读字段的值，指针字段复制其指向的值，避免解码 body 时被修改
+func readOnlyValues(modPointer interface{}) map[string]reflect.Value {
+	meta := getModelMeta(modPointer)
+	val := reflect.ValueOf(modPointer).Elem()
+	values := make(map[string]reflect.Value)
+	for inx, f := range meta.listTableFields {
+		name, tf, _ := meta.fieldByTable(f)
+		if !isReadOnlyField(meta, inx, tf) {
+			continue
+		}
+		fv := val.FieldByName(name)
+		v := reflect.New(fv.Type()).Elem()
+		if reflect.Ptr == fv.Kind() && !fv.IsNil() {
+			p := reflect.New(fv.Type().Elem())
+			p.Elem().Set(fv.Elem())
+			v.Set(p)
+		} else {
+			v.Set(fv)
+		}
+		values[name] = v
+	}
+	return values
+}
+
+// keepReadOnly 将只读字段恢复为 values 中的值
+func keepReadOnly(modPointer interface{}, values map[string]reflect.Value) {
+	val := reflect.ValueOf(modPointer).Elem()
+	for name, v := range values {
+		val.FieldByName(name).Set(v)
+	}
+}
+
+// decodeBody 将 JSON body 解码到 model，body 最大为 1MB
+func decodeBody(w http.ResponseWriter, r *http.Request, modPointer interface{}) error {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBindBodySize))
+	if err := dec.Decode(modPointer); nil != err {
+		return &BindError{Param: "body", Message: "invalid json: " + err.Error()}
+	}
+	return nil
+}
+
+// errorStatus 错误对应的状态码，实现 StatusCode() int 的错误（如 BindErrors）优先
+func errorStatus(err error, status int) int {
+	var sc interface{ StatusCode() int }
+	var ve ValidationErrors
+	switch {
+	case errors.As(err, &sc):
+		return sc.StatusCode()
+	case errors.As(err, &ve):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, ErrNotFound), errors.Is(err, ErrNoRowsAffected):
+		return http.StatusNotFound
+	case errors.Is(err, ErrDuplicateKey), errors.Is(err, ErrForeignKey):
+		return http.StatusConflict
+	case errors.Is(err, ErrForbidden):
+		return http.StatusForbidden
+	case 0 != status:
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// writeError 输出 {"error": "...", "fields": {...}}，500 与数据库错误（如 409 的 DaoError）不输出原始错误，
+// 只输出状态码的说明，原始错误写日志
+func (that *Handler) writeError(w http.ResponseWriter, r *http.Request, op string, err error, status int) {
+	status = errorStatus(err, status)
+	body := map[string]interface{}{"error": err.Error()}
+	if f, isOk := err.(interface{ Fields() map[string]string }); isOk {
+		body["fields"] = f.Fields()
+	}
+	var de *DaoError
+	level, hide := slog.LevelWarn, errors.As(err, &de)
+	if http.StatusInternalServerError <= status {
+		level, hide = slog.LevelError, true
+	}
+	if hide {
+		GetInstanceByBaseDao().logAttrs(r.Context(), level, "Handler", err,
+			slog.String(LogKeyTable, getModelMeta(that.newModel()).tableName),
+			slog.String(LogKeyOperation, op),
+		)
+		body = map[string]interface{}{"error": http.StatusText(status)}
+	}
+	writeJSON(w, status, body)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
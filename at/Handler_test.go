@@ -0,0 +1,150 @@
+package at
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func serve(h http.Handler, method, path, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestHandlerAuthorizeUpdatedValues(t *testing.T) {
+	SetStorage(NewMemoryStorage())
+	defer SetStorage(nil)
+	h := NewHandler(&testPerson{})
+	// 只能操作 name 为 tom 的数据
+	h.Authorize = func(r *http.Request, op string, target interface{}) error {
+		if p, isOk := target.(*testPerson); isOk && "tom" != p.Name {
+			return ErrForbidden
+		}
+		return nil
+	}
+
+	w := serve(h, http.MethodPost, "/", `{"id":99,"name":"tom"}`)
+	if http.StatusCreated != w.Code {
+		t.Fatalf("create %d %s", w.Code, w.Body.String())
+	}
+	if strings.Contains(w.Body.String(), `"id":99`) {
+		t.Errorf("create wrote generated primary key: %s", w.Body.String())
+	}
+	if w = serve(h, http.MethodPatch, "/1", `{"name":"eve"}`); http.StatusForbidden != w.Code {
+		t.Errorf("update to a value Authorize rejects: %d %s", w.Code, w.Body.String())
+	}
+	p := &testPerson{Id: 1}
+	if err := GetInstanceByBaseService().Get(p); nil != err || "tom" != p.Name {
+		t.Errorf("row changed after rejected update: %+v %v", p, err)
+	}
+	if w = serve(h, http.MethodPatch, "/1", `{"name":"tom"}`); http.StatusOK != w.Code {
+		t.Errorf("allowed update: %d %s", w.Code, w.Body.String())
+	}
+}
+
+// unchangedStorage 修改时与 MySQL 数据没有变化时一样，受影响行数为 0
+type unchangedStorage struct {
+	*MemoryStorage
+}
+
+func (unchangedStorage) UpdateByID(context.Context, interface{}) (int64, error) {
+	return 0, newDaoError("person", OpUpdateByID, ErrNoRowsAffected)
+}
+
+func TestHandlerUpdateUnchanged(t *testing.T) {
+	s := unchangedStorage{NewMemoryStorage()}
+	SetStorage(s)
+	defer SetStorage(nil)
+	if _, err := GetInstanceByBaseService().AddModel(&testPerson{Name: "tom"}); nil != err {
+		t.Fatal(err)
+	}
+	h := NewHandler(&testPerson{})
+	if w := serve(h, http.MethodPut, "/1", `{"name":"tom"}`); http.StatusOK != w.Code {
+		t.Errorf("update without changes: %d %s", w.Code, w.Body.String())
+	}
+}
+
+// deletedStorage 修改前数据被并发删除，受影响行数为 0
+type deletedStorage struct {
+	*MemoryStorage
+}
+
+func (that deletedStorage) UpdateByID(ctx context.Context, modPointer interface{}) (int64, error) {
+	if _, err := that.MemoryStorage.DeleteByID(ctx, modPointer); nil != err {
+		return -1, err
+	}
+	return 0, newDaoError("person", OpUpdateByID, ErrNoRowsAffected)
+}
+
+func TestHandlerUpdateDeletedConcurrently(t *testing.T) {
+	SetStorage(deletedStorage{NewMemoryStorage()})
+	defer SetStorage(nil)
+	if _, err := GetInstanceByBaseService().AddModel(&testPerson{Name: "tom"}); nil != err {
+		t.Fatal(err)
+	}
+	if w := serve(NewHandler(&testPerson{}), http.MethodPut, "/1", `{"name":"jerry"}`); http.StatusNotFound != w.Code {
+		t.Errorf("update of a deleted row: %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestHandlerUpdateAuthorizeTwice(t *testing.T) {
+	SetStorage(NewMemoryStorage())
+	defer SetStorage(nil)
+	if _, err := GetInstanceByBaseService().AddModel(&testPerson{Name: "tom"}); nil != err {
+		t.Fatal(err)
+	}
+	h := NewHandler(&testPerson{})
+	var calls []string
+	h.Authorize = func(r *http.Request, op string, target interface{}) error {
+		calls = append(calls, op+":"+target.(*testPerson).Name)
+		return nil
+	}
+	if w := serve(h, http.MethodPatch, "/1", `{"name":"jerry"}`); http.StatusOK != w.Code {
+		t.Fatalf("update %d %s", w.Code, w.Body.String())
+	}
+	// 先为已存在的数据，再为将要保存的数据
+	if want := []string{"update:tom", "update:jerry"}; strings.Join(want, ",") != strings.Join(calls, ",") {
+		t.Errorf("Authorize calls = %v, want %v", calls, want)
+	}
+}
+
+// conflictStorage 新增时返回带驱动错误的唯一键冲突
+type conflictStorage struct {
+	*MemoryStorage
+}
+
+func (conflictStorage) AddModel(context.Context, interface{}) (int64, error) {
+	return 0, &DaoError{Table: "person", Op: OpAddModel, Kind: ErrDuplicateKey, Err: errors.New("Error 1062: Duplicate entry 'secret' for key 'uk_name'")}
+}
+
+func TestHandlerConflictHidesDriverError(t *testing.T) {
+	SetStorage(conflictStorage{NewMemoryStorage()})
+	defer SetStorage(nil)
+	w := serve(NewHandler(&testPerson{}), http.MethodPost, "/", `{"name":"tom"}`)
+	if http.StatusConflict != w.Code {
+		t.Fatalf("create %d %s", w.Code, w.Body.String())
+	}
+	if strings.Contains(w.Body.String(), "secret") || strings.Contains(w.Body.String(), "person") {
+		t.Errorf("response leaks the driver error: %s", w.Body.String())
+	}
+}
+
+func TestHandlerListRejectsCursor(t *testing.T) {
+	SetStorage(NewMemoryStorage())
+	defer SetStorage(nil)
+	h := NewHandler(&testPerson{})
+	if w := serve(h, http.MethodGet, "/?condCursor=abc", ""); http.StatusBadRequest != w.Code {
+		t.Errorf("list with condCursor: %d %s", w.Code, w.Body.String())
+	}
+	for _, p := range h.OpenAPIParameters() {
+		if CondCursor == p.Name {
+			t.Error("OpenAPIParameters documents condCursor")
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package at
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// 主键生成：主键字段加 idgen tag 后，AddModel、AddModelBatch 在插入前为值为零的主键生成 ID，插入语句包含主键列，
+// 不再依赖数据库自增与 LastInsertId，适用于分表或需要提前知道 ID 的场景。联合主键不支持 idgen，插入时返回错误。
+//
+//	Id  int64  `json:"id" table:"id" idgen:"snowflake"`
+//	Id  string `json:"id" table:"id" idgen:"ulid"`
+const idGenTag = "idgen"
+
+// 内置生成器名称
+const (
+	IDGenSnowflake = "snowflake"
+	IDGenULID      = "ulid"
+)
+
+// IDGenerator 主键生成器，返回值需可赋值（或转换）给主键字段
+type IDGenerator interface {
+	NextID() (interface{}, error)
+}
+
+var idGenerators = map[string]IDGenerator{
+	IDGenSnowflake: MustSnowflake(0, SnowflakeEpoch),
+	IDGenULID:      ULIDGenerator{},
+}
+var idGeneratorsLock sync.RWMutex
+
+// RegisterIDGenerator 注册主键生成器，同名覆盖
+// name string	idgen tag 中使用的名称
+// gen IDGenerator	生成器
+func RegisterIDGenerator(name string, gen IDGenerator) {
+	idGeneratorsLock.Lock()
+	defer idGeneratorsLock.Unlock()
+	idGenerators[name] = gen
+}
+
+// InitSnowflake 设置内置 snowflake 生成器的机器 ID 与起始时间，多实例部署时每个实例的 workerID 必须不同
+// workerID int64	机器 ID，0 ~ 1023
+// epoch time.Time	起始时间，零值时为 SnowflakeEpoch，设置后不能再更改，否则可能生成重复 ID
+func InitSnowflake(workerID int64, epoch time.Time) error {
+	s, err := NewSnowflake(workerID, epoch)
+	if nil != err {
+		return err
+	}
+	RegisterIDGenerator(IDGenSnowflake, s)
+	return nil
+}
+
+func getIDGenerator(name string) (IDGenerator, error) {
+	idGeneratorsLock.RLock()
+	defer idGeneratorsLock.RUnlock()
+	gen, isOk := idGenerators[name]
+	if !isOk {
+		return nil, errors.New(fmt.Sprintf("error:id generator %s not registered", name))
+	}
+	return gen, nil
+}
+
+// pkIDGen 主键字段的 idgen tag，没有时为 ""
+func (that *modelMeta) pkIDGen() string {
+	_, tf, _ := that.fieldByTable(that.pkField)
+	return tf.FieldIDGen
+}
+
+// fillID 主键有 idgen tag 且为零值时生成 ID 写入 model；联合主键的字段有 idgen tag 时返回错误，
+// 只生成其中一个字段无法保证主键唯一
+func fillID(meta *modelMeta, val reflect.Value) error {
+	if 1 < len(meta.pkFields) {
+		for _, f := range meta.pkFields {
+			if _, tf, _ := meta.fieldByTable(f); "" != tf.FieldIDGen {
+				return errors.New(fmt.Sprintf("error:idgen is not supported on composite primary key field %s", f))
+			}
+		}
+		return nil
+	}
+	name := meta.pkIDGen()
+	if "" == name {
+		return nil
+	}
+	pk := val.FieldByName(meta.pkModelField())
+	if !pk.IsZero() {
+		return nil
+	}
+	gen, err := getIDGenerator(name)
+	if nil != err {
+		return err
+	}
+	id, err := gen.NextID()
+	if nil != err {
+		return err
+	}
+	iv := reflect.ValueOf(id)
+	if !iv.Type().ConvertibleTo(pk.Type()) {
+		return errors.New(fmt.Sprintf("error:id %T cannot be set to %s", id, pk.Type()))
+	}
+	pk.Set(iv.Convert(pk.Type()))
+	return nil
+}
+
+// SnowflakeEpoch snowflake 默认起始时间 2024-01-01 00:00:00 UTC
+var SnowflakeEpoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+const (
+	snowflakeWorkerBits   = 10
+	snowflakeSequenceBits = 12
+	snowflakeMaxWorker    = -1 ^ (-1 << snowflakeWorkerBits)
+	snowflakeMaxSequence  = -1 ^ (-1 << snowflakeSequenceBits)
+)
+
+// Snowflake 64 位趋势递增 ID：41 位毫秒时间戳 + 10 位机器 ID + 12 位序列号，每毫秒每机器最多 4096 个
+type Snowflake struct {
+	lock     sync.Mutex
+	epoch    int64
+	workerID int64
+	lastMs   int64
+	sequence int64
+	// nowMs 当前毫秒时间戳，测试中替换
+	nowMs func() int64
+}
+
+// NewSnowflake 创建 snowflake 生成器
+// workerID int64	机器 ID，0 ~ 1023
+// epoch time.Time	起始时间，零值时为 SnowflakeEpoch
+func NewSnowflake(workerID int64, epoch time.Time) (*Snowflake, error) {
+	if 0 > workerID || snowflakeMaxWorker < workerID {
+		return nil, errors.New(fmt.Sprintf("error:snowflake worker id must be 0 ~ %d", snowflakeMaxWorker))
+	}
+	if epoch.IsZero() {
+		epoch = SnowflakeEpoch
+	}
+	return &Snowflake{epoch: epoch.UnixMilli(), workerID: workerID, nowMs: func() int64 {
+		return time.Now().UnixMilli()
+	}}, nil
+}
+
+// MustSnowflake 同 NewSnowflake，参数错误时 panic
+func MustSnowflake(workerID int64, epoch time.Time) *Snowflake {
+	s, err := NewSnowflake(workerID, epoch)
+	if nil != err {
+		panic(err)
+	}
+	return s
+}
+
+// Next 生成 ID，时钟回拨超过 1 秒时返回错误，1 秒以内等待时钟追上
+func (that *Snowflake) Next() (int64, error) {
+	that.lock.Lock()
+	defer that.lock.Unlock()
+	now := that.nowMs()
+	if now < that.lastMs {
+		if that.lastMs-now > 1000 {
+			return 0, errors.New(fmt.Sprintf("error:snowflake clock moved backwards %dms", that.lastMs-now))
+		}
+		for now < that.lastMs {
+			time.Sleep(time.Millisecond)
+			now = that.nowMs()
+		}
+	}
+	if now == that.lastMs {
+		that.sequence = (that.sequence + 1) & snowflakeMaxSequence
+		if 0 == that.sequence {
+			// 本毫秒序列号用完，等待下一毫秒
+			for now <= that.lastMs {
+				now = that.nowMs()
+			}
+		}
+	} else {
+		that.sequence = 0
+	}
+	that.lastMs = now
+	return (now-that.epoch)<<(snowflakeWorkerBits+snowflakeSequenceBits) | that.workerID<<snowflakeSequenceBits | that.sequence, nil
+}
+
+func (that *Snowflake) NextID() (interface{}, error) {
+	return that.Next()
+}
+
+// ULIDGenerator 生成 ULID：48 位毫秒时间戳 + 80 位随机数，26 位 Crockford Base32 字符串，按时间排序
+type ULIDGenerator struct{}
+
+const crockfordBase32 = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// NewULID 生成 ULID
+func NewULID() (string, error) {
+	var b [16]byte
+	ms := uint64(time.Now().UnixMilli())
+	for i := 0; i < 6; i++ {
+		b[i] = byte(ms >> (8 * (5 - i)))
+	}
+	if _, err := rand.Read(b[6:]); nil != err {
+		return "", err
+	}
+	// 128 位按 5 位一组编码，首字符只有 3 位
+	out := make([]byte, 26)
+	var acc uint64
+	bits, pos := 2, 0
+	for _, v := range b {
+		acc = acc<<8 | uint64(v)
+		bits += 8
+		for bits >= 5 {
+			bits -= 5
+			out[pos] = crockfordBase32[(acc>>uint(bits))&31]
+			pos++
+		}
+	}
+	return string(out), nil
+}
+
+func (ULIDGenerator) NextID() (interface{}, error) {
+	return NewULID()
+}
+
+// ParseSnowflakeTime 取出 snowflake ID 中的生成时间
+func ParseSnowflakeTime(id int64, epoch time.Time) time.Time {
+	if epoch.IsZero() {
+		epoch = SnowflakeEpoch
+	}
+	return time.UnixMilli(id>>(snowflakeWorkerBits+snowflakeSequenceBits) + epoch.UnixMilli())
+}
@@ -0,0 +1,166 @@
+package at
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// steppedClock 依次返回 ms 中的时间，用完后返回最后一个
+func steppedClock(ms ...int64) func() int64 {
+	var lock sync.Mutex
+	return func() int64 {
+		lock.Lock()
+		defer lock.Unlock()
+		v := ms[0]
+		if 1 < len(ms) {
+			ms = ms[1:]
+		}
+		return v
+	}
+}
+
+func TestSnowflakeConcurrent(t *testing.T) {
+	s := MustSnowflake(3, time.Time{})
+	const workers, per = 8, 2000
+	ids := make([][]int64, workers)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < per; i++ {
+				id, err := s.Next()
+				if nil != err {
+					t.Error(err)
+					return
+				}
+				ids[w] = append(ids[w], id)
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	seen := make(map[int64]bool, workers*per)
+	for _, list := range ids {
+		for i, id := range list {
+			if seen[id] {
+				t.Fatalf("duplicate id %d", id)
+			}
+			seen[id] = true
+			// 同一个 goroutine 先后取得的 ID 递增
+			if 0 < i && id <= list[i-1] {
+				t.Fatalf("id %d after %d", id, list[i-1])
+			}
+		}
+	}
+}
+
+func TestSnowflakeSequenceWraps(t *testing.T) {
+	s := MustSnowflake(1, time.UnixMilli(0))
+	ms := make([]int64, snowflakeMaxSequence+2)
+	for i := range ms {
+		ms[i] = 100
+	}
+	s.nowMs = steppedClock(append(ms, 101)...)
+
+	var last int64
+	for i := 0; i <= snowflakeMaxSequence; i++ {
+		id, err := s.Next()
+		if nil != err {
+			t.Fatal(err)
+		}
+		if int64(i) != id&snowflakeMaxSequence || 100 != ParseSnowflakeTime(id, time.UnixMilli(0)).UnixMilli() {
+			t.Fatalf("id %d: sequence %d at %v", i, id&snowflakeMaxSequence, ParseSnowflakeTime(id, time.UnixMilli(0)))
+		}
+		last = id
+	}
+	// 4096 个用完后等待下一毫秒，序列号从 0 开始
+	id, err := s.Next()
+	if nil != err {
+		t.Fatal(err)
+	}
+	if 0 != id&snowflakeMaxSequence || 101 != ParseSnowflakeTime(id, time.UnixMilli(0)).UnixMilli() || id <= last {
+		t.Fatalf("id after wrap = %d, sequence %d", id, id&snowflakeMaxSequence)
+	}
+	if 1 != (id>>snowflakeSequenceBits)&snowflakeMaxWorker {
+		t.Errorf("worker id = %d", (id>>snowflakeSequenceBits)&snowflakeMaxWorker)
+	}
+}
+
+func TestSnowflakeClockBackwards(t *testing.T) {
+	s := MustSnowflake(0, time.UnixMilli(0))
+	// 回拨 500ms 时等待追上，回拨超过 1 秒返回错误
+	s.nowMs = steppedClock(5000, 4500, 4800, 5000, 3999)
+	first, err := s.Next()
+	if nil != err {
+		t.Fatal(err)
+	}
+	id, err := s.Next()
+	if nil != err {
+		t.Fatal(err)
+	}
+	if id <= first {
+		t.Errorf("id %d after %d", id, first)
+	}
+	if _, err = s.Next(); nil == err || !strings.Contains(err.Error(), "backwards") {
+		t.Fatalf("err = %v, want clock moved backwards", err)
+	}
+}
+
+func TestULID(t *testing.T) {
+	a, err := NewULID()
+	if nil != err {
+		t.Fatal(err)
+	}
+	time.Sleep(2 * time.Millisecond)
+	b, err := NewULID()
+	if nil != err {
+		t.Fatal(err)
+	}
+	for _, id := range []string{a, b} {
+		if 26 != len(id) {
+			t.Errorf("len(%s) = %d", id, len(id))
+		}
+		for _, c := range id {
+			if !strings.ContainsRune(crockfordBase32, c) {
+				t.Errorf("%s has %q outside the Crockford alphabet", id, c)
+			}
+		}
+		// 128 位的首字符只有 3 位
+		if '7' < id[0] {
+			t.Errorf("%s overflows 128 bits", id)
+		}
+	}
+	if a >= b {
+		t.Errorf("%s not before %s", a, b)
+	}
+}
+
+// testIDGenPair 联合主键的字段有 idgen tag
+type testIDGenPair struct {
+	BaseModel
+	Id    int64 `table:"id" pk:"" idgen:"snowflake"`
+	Shard int64 `table:"shard" pk:""`
+}
+
+func (*testIDGenPair) GetTableName() string {
+	return "pair"
+}
+
+func TestFillIDCompositeKey(t *testing.T) {
+	mod := &testIDGenPair{}
+	if err := fillID(getModelMeta(mod), reflect.ValueOf(mod).Elem()); nil == err {
+		t.Fatal("idgen on a composite key filled without error")
+	}
+	if 0 != mod.Id {
+		t.Errorf("Id = %d, want unchanged", mod.Id)
+	}
+
+	item := &testItem{}
+	if err := fillID(getModelMeta(item), reflect.ValueOf(item).Elem()); nil != err || 0 != item.Id {
+		t.Errorf("err = %v Id = %d, want no idgen", err, item.Id)
+	}
+}
@@ -0,0 +1,184 @@
+package at
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+)
+
+// RowIterator 逐行读取查询结果，每次只装载一个 model，用于导出、对账等遍历大表的场景。
+// 使用完必须调用 Close（可重复调用），推荐使用 EachContext，由其保证关闭。
+//
+//	it, err := dao.IterateContext(ctx, db, condition, &User{})
+//	if nil != err { ... }
+//	defer it.Close()
+//	for it.Next() {
+//		u := it.Model().(*User)
+//	}
+//	if err := it.Err(); nil != err { ... }
+type RowIterator struct {
+	dao     *BaseDao
+	ctx     context.Context
+	event   *QueryEvent
+	rows    *sql.Rows
+	modType reflect.Type
+	indexes []scanField
+	values  []interface{}
+	current interface{}
+	count   int64
+	err     error
+	closed  bool
+}
+
+// IterateContext	按 condition 查询并返回逐行迭代器。
+// condition 中有 CondPageIndex、CondPageSize、CondLimitBegin 时加 LIMIT，否则遍历全部符合条件的数据。
+// 分表时条件需解析为一个物理表，否则返回 ErrShardSpan。
+// ctx context.Context	上下文，取消后 Next 返回 false，Err 为 ctx 的错误
+// q Querier	*sql.DB 或 *sql.Tx
+// condition map[string]interface{}	查询条件
+// modPointer interface{}	model 的指针，用于取得表信息
+// *RowIterator	迭代器
+// error	err 不为 nil 时查询失败，此时无需 Close
+func (that *BaseDao) IterateContext(ctx context.Context, q Querier, condition map[string]interface{}, modPointer interface{}) (*RowIterator, error) {
+	meta, err := singleShardMeta(getModelMeta(modPointer), modPointer, condition)
+	if nil != err {
+		return nil, err
+	}
+	s, params, err := that.buildSelect(ctx, meta, condition)
+	if nil != err {
+		return nil, err
+	}
+	_, isPI := condition[CondPageIndex]
+	_, isPS := condition[CondPageSize]
+	_, isLB := condition[CondLimitBegin]
+	if isPI || isPS || isLB {
+		s = that.AddLimit(condition, s)
+	}
+
+	event := that.beginEvent(ctx, meta.tableName, OpIterate, s, params)
+	rows, err := q.QueryContext(ctx, s, params...)
+	if nil != err {
+		err = wrapDaoError(meta.tableName, OpIterate, err)
+		that.endEvent(ctx, event, -1, err)
+		return nil, err
+	}
+	columns, err := rows.Columns()
+	if nil != err {
+		rows.Close()
+		that.endEvent(ctx, event, -1, err)
+		return nil, err
+	}
+	modType := reflect.TypeOf(modPointer).Elem()
+	return &RowIterator{
+		dao:     that,
+		ctx:     ctx,
+		event:   event,
+		rows:    rows,
+		modType: modType,
+		indexes: columnIndexes(modType, columns),
+		values:  make([]interface{}, len(columns)),
+	}, nil
+}
+
+// Next 读取下一行，没有更多数据、出错或已关闭时返回 false，出错时自动关闭
+func (that *RowIterator) Next() bool {
+	if that.closed {
+		return false
+	}
+	if !that.rows.Next() {
+		that.err = that.rows.Err()
+		that.Close()
+		return false
+	}
+	m := reflect.New(that.modType)
+	setScanTargets(that.values, m.Elem(), that.indexes)
+	if err := that.rows.Scan(that.values...); nil != err {
+		that.err = err
+		that.Close()
+		return false
+	}
+	that.current = m.Interface()
+	that.count++
+	return true
+}
+
+// Model 当前行的 model 指针，每一行都是新的实例
+func (that *RowIterator) Model() interface{} {
+	return that.current
+}
+
+// Err 遍历过程中的错误
+func (that *RowIterator) Err() error {
+	return that.err
+}
+
+// Count 已读取的行数
+func (that *RowIterator) Count() int64 {
+	return that.count
+}
+
+// Close 关闭结果集，提前终止遍历时也必须调用
+func (that *RowIterator) Close() error {
+	if that.closed {
+		return nil
+	}
+	that.closed = true
+	err := that.rows.Close()
+	if nil == that.err {
+		that.err = err
+	}
+	that.dao.endEvent(that.ctx, that.event, that.count, that.err)
+	return err
+}
+
+// EachContext	按 condition 逐行遍历，fun 返回 false 或 error 时终止遍历，结果集总是被关闭
+// ctx context.Context	上下文
+// q Querier	*sql.DB 或 *sql.Tx
+// condition map[string]interface{}	查询条件，见 IterateContext
+// modPointer interface{}	model 的指针，用于取得表信息
+// fun func(m interface{}) (bool, error)	处理一行，m 为 model 指针，返回 true 继续
+// error	查询、扫描或 fun 的错误
+func (that *BaseDao) EachContext(ctx context.Context, q Querier, condition map[string]interface{}, modPointer interface{}, fun func(m interface{}) (bool, error)) error {
+	it, err := that.IterateContext(ctx, q, condition, modPointer)
+	if nil != err {
+		return err
+	}
+	defer it.Close()
+	for it.Next() {
+		goOn, err := fun(it.Model())
+		if nil != err {
+			return err
+		}
+		if !goOn {
+			return nil
+		}
+	}
+	return it.Err()
+}
+
+// StreamContext	按 condition 逐行遍历，通过 channel 输出 model 指针。
+// 数据读完、出错或 ctx 取消后关闭两个 channel，错误（包括 ctx 的错误）写入 error channel。
+// 提前终止需取消 ctx，否则后台 goroutine 会阻塞在发送上。
+// buffer int	model channel 的缓冲大小
+// <-chan interface{}	model 指针
+// <-chan error	最多一个错误
+func (that *BaseDao) StreamContext(ctx context.Context, q Querier, condition map[string]interface{}, modPointer interface{}, buffer int) (<-chan interface{}, <-chan error) {
+	out := make(chan interface{}, buffer)
+	errs := make(chan error, 1)
+	go func() {
+		defer close(errs)
+		defer close(out)
+		err := that.EachContext(ctx, q, condition, modPointer, func(m interface{}) (bool, error) {
+			select {
+			case out <- m:
+				return true, nil
+			case <-ctx.Done():
+				return false, ctx.Err()
+			}
+		})
+		if nil != err {
+			errs <- err
+		}
+	}()
+	return out, errs
+}
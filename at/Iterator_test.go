@@ -0,0 +1,132 @@
+package at
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// openItems SQLite 中 10 行 item，只有一个连接，结果集未释放时 InUse 不为 0；
+// id 为 INT 而不是 INTEGER，可以写入字符串制造扫描错误
+func openItems(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", "file:"+filepath.Join(t.TempDir(), "items.db"))
+	if nil != err {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	db.SetMaxOpenConns(1)
+	if _, err = db.Exec("CREATE TABLE item (id INT PRIMARY KEY, title TEXT)"); nil != err {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 10; i++ {
+		if _, err = db.Exec("INSERT INTO item (id, title) VALUES (?, ?)", i, fmt.Sprintf("t%d", i)); nil != err {
+			t.Fatal(err)
+		}
+	}
+	return db
+}
+
+func assertReleased(t *testing.T, db *sql.DB) {
+	t.Helper()
+	if n := db.Stats().InUse; 0 != n {
+		t.Errorf("%d connections still in use", n)
+	}
+}
+
+func TestIterate(t *testing.T) {
+	db := openItems(t)
+	it, err := GetInstanceByBaseDao().IterateContext(context.Background(), db, map[string]interface{}{CondORDERField: "id"}, &testItem{})
+	if nil != err {
+		t.Fatal(err)
+	}
+	var prev *testItem
+	for it.Next() {
+		m := it.Model().(*testItem)
+		if m == prev || fmt.Sprintf("t%d", m.Id) != m.Title {
+			t.Fatalf("row = %+v", m)
+		}
+		prev = m
+	}
+	if nil != it.Err() || 10 != it.Count() {
+		t.Fatalf("err = %v count = %d", it.Err(), it.Count())
+	}
+	// 读完自动关闭，重复 Close 无副作用
+	if err = it.Close(); nil != err {
+		t.Error(err)
+	}
+	assertReleased(t, db)
+}
+
+func TestEachStopsEarly(t *testing.T) {
+	db := openItems(t)
+	n := 0
+	err := GetInstanceByBaseDao().EachContext(context.Background(), db, map[string]interface{}{}, &testItem{}, func(m interface{}) (bool, error) {
+		n++
+		return 3 > n, nil
+	})
+	if nil != err || 3 != n {
+		t.Fatalf("err = %v n = %d", err, n)
+	}
+	assertReleased(t, db)
+
+	// 提前终止的迭代器 Close 后释放连接
+	it, err := GetInstanceByBaseDao().IterateContext(context.Background(), db, map[string]interface{}{}, &testItem{})
+	if nil != err {
+		t.Fatal(err)
+	}
+	it.Next()
+	if 1 != db.Stats().InUse {
+		t.Errorf("InUse = %d while iterating", db.Stats().InUse)
+	}
+	it.Close()
+	assertReleased(t, db)
+}
+
+func TestEachClosesOnError(t *testing.T) {
+	db := openItems(t)
+	errStop := errors.New("stop")
+	err := GetInstanceByBaseDao().EachContext(context.Background(), db, map[string]interface{}{}, &testItem{}, func(m interface{}) (bool, error) {
+		return true, errStop
+	})
+	if errStop != err {
+		t.Fatalf("err = %v, want fun's error", err)
+	}
+	assertReleased(t, db)
+
+	// 扫描失败时 Next 返回 false 并关闭
+	if _, err = db.Exec("UPDATE item SET id = 'x' WHERE id = 5"); nil != err {
+		t.Fatal(err)
+	}
+	it, err := GetInstanceByBaseDao().IterateContext(context.Background(), db, map[string]interface{}{CondORDERField: "id"}, &testItem{})
+	if nil != err {
+		t.Fatal(err)
+	}
+	for it.Next() {
+	}
+	if nil == it.Err() || 10 == it.Count() {
+		t.Errorf("err = %v count = %d, want a scan error", it.Err(), it.Count())
+	}
+	assertReleased(t, db)
+}
+
+func TestStreamContextCancel(t *testing.T) {
+	db := openItems(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	out, errs := GetInstanceByBaseDao().StreamContext(ctx, db, map[string]interface{}{}, &testItem{}, 0)
+	if _, isOk := <-out; !isOk {
+		t.Fatal("no row streamed")
+	}
+	cancel()
+	for range out {
+	}
+	if err := <-errs; !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+	assertReleased(t, db)
+}
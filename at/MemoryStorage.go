@@ -0,0 +1,533 @@
+package at
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryStorage 内存存储，实现 Storage，用于单元测试。
+// 按 model 的 table tag 处理自增主键、创建时间与最后更新，
+// FindList、Count 支持与 GetModelFieldCondition 相同的条件写法以及排序、分页。
+type MemoryStorage struct {
+	lock   sync.RWMutex
+	tables map[string]*memoryTable
+}
+
+type memoryTable struct {
+	autoIncrement int64
+	// rows k=主键值，v=model 结构体（非指针）的副本
+	rows map[string]reflect.Value
+}
+
+// NewMemoryStorage 创建内存存储
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{tables: make(map[string]*memoryTable)}
+}
+
+// Reset 清空全部数据
+func (that *MemoryStorage) Reset() {
+	that.lock.Lock()
+	defer that.lock.Unlock()
+	that.tables = make(map[string]*memoryTable)
+}
+
+// tableRows 表的数据，表不存在时为 nil 且不创建，持有读锁时使用
+func (that *MemoryStorage) tableRows(name string) map[string]reflect.Value {
+	if t, isOk := that.tables[name]; isOk {
+		return t.rows
+	}
+	return nil
+}
+
+// table 取得表，不存在时创建，需持有写锁
+func (that *MemoryStorage) table(name string) *memoryTable {
+	t, isOk := that.tables[name]
+	if !isOk {
+		t = &memoryTable{rows: make(map[string]reflect.Value)}
+		that.tables[name] = t
+	}
+	return t
+}
+
+func (that *MemoryStorage) AddModel(ctx context.Context, modPointer interface{}) (int64, error) {
+	meta := getModelMeta(modPointer)
+	val := reflect.ValueOf(modPointer).Elem()
+	if err := setTenant(ctx, meta, val); nil != err {
+		return -1, err
+	}
+	if err := fillID(meta, val); nil != err {
+		return -1, err
+	}
+	if err := Validate(modPointer); nil != err {
+		return -1, err
+	}
+	pk := val.FieldByName(meta.pkModelField())
+
+	that.lock.Lock()
+	defer that.lock.Unlock()
+	t := that.table(meta.tableName)
+
+	// 自增的整型主键为 0 时自增
+	if meta.pkAutoIncrement() && isIntKind(pk.Kind()) {
+		if 0 == reflectInt(pk) {
+			t.autoIncrement++
+			setReflectInt(pk, t.autoIncrement)
+		} else if reflectInt(pk) > t.autoIncrement {
+			t.autoIncrement = reflectInt(pk)
+		}
+	}
+	key := meta.pkKey(val)
+	if _, isOk := t.rows[key]; isOk {
+		return -1, &DaoError{Table: meta.tableName, Op: OpAddModel, Kind: ErrDuplicateKey, Err: errors.New(fmt.Sprintf("error:duplicate key %s", key))}
+	}
+
+	now := nowTime()
+	for k, v := range meta.mapModelTableField {
+		if PropertyCreateTime == v.FieldProperty || PropertyUpdateTime == v.FieldProperty {
+			if err := setTimestamp(val.FieldByName(k), v, now); nil != err {
+				return -1, err
+			}
+		}
+	}
+	t.rows[key] = copyStruct(val)
+	return modelInsertID(meta, val), nil
+}
+
+func (that *MemoryStorage) UpdateByID(ctx context.Context, modPointer interface{}) (int64, error) {
+	meta := getModelMeta(modPointer)
+	val := reflect.ValueOf(modPointer).Elem()
+	if err := setTenant(ctx, meta, val); nil != err {
+		return -1, err
+	}
+	if err := Validate(modPointer); nil != err {
+		return -1, err
+	}
+	key := meta.pkKey(val)
+
+	that.lock.Lock()
+	defer that.lock.Unlock()
+	t := that.table(meta.tableName)
+	old, isOk := t.rows[key]
+	if isOk {
+		var err error
+		if isOk, err = tenantMatch(ctx, meta, old); nil != err {
+			return -1, err
+		}
+	}
+	if !isOk {
+		return 0, newDaoError(meta.tableName, OpUpdateByID, ErrNoRowsAffected)
+	}
+
+	// 与 UPDATE 语句一致：创建时间不变，最后更新为当前时间并写回 model
+	now := nowTime()
+	for k, v := range meta.mapModelTableField {
+		if PropertyUpdateTime == v.FieldProperty {
+			if err := setTimestamp(val.FieldByName(k), v, now); nil != err {
+				return -1, err
+			}
+		}
+	}
+	row := copyStruct(val)
+	for k, v := range meta.mapModelTableField {
+		if PropertyCreateTime == v.FieldProperty {
+			row.FieldByName(k).Set(old.FieldByName(k))
+		}
+	}
+	t.rows[key] = row
+	return 1, nil
+}
+
+func (that *MemoryStorage) DeleteByID(ctx context.Context, modPointer interface{}) (int64, error) {
+	meta := getModelMeta(modPointer)
+	key := meta.pkKey(reflect.ValueOf(modPointer).Elem())
+
+	that.lock.Lock()
+	defer that.lock.Unlock()
+	t := that.table(meta.tableName)
+	row, isOk := t.rows[key]
+	if isOk {
+		var err error
+		if isOk, err = tenantMatch(ctx, meta, row); nil != err {
+			return -1, err
+		}
+	}
+	if !isOk {
+		return 0, newDaoError(meta.tableName, OpDeleteByID, ErrNoRowsAffected)
+	}
+	delete(t.rows, key)
+	return 1, nil
+}
+
+func (that *MemoryStorage) FindByID(ctx context.Context, modPointer interface{}) (bool, error) {
+	meta := getModelMeta(modPointer)
+	val := reflect.ValueOf(modPointer).Elem()
+	key := meta.pkKey(val)
+
+	that.lock.RLock()
+	defer that.lock.RUnlock()
+	row, isOk := that.tableRows(meta.tableName)[key]
+	if isOk {
+		var err error
+		if isOk, err = tenantMatch(ctx, meta, row); nil != err {
+			return false, err
+		}
+	}
+	if !isOk {
+		return false, nil
+	}
+	val.Set(copyStruct(row))
+	return true, nil
+}
+
+func (that *MemoryStorage) FindList(ctx context.Context, condition map[string]interface{}, modPointer interface{}, listPointer interface{}) error {
+	meta := getModelMeta(modPointer)
+	rows, err := that.match(ctx, meta, condition)
+	if nil != err {
+		return err
+	}
+	sortRows(meta, condition, rows)
+
+	offset, size := LimitOffset(condition)
+	if offset > len(rows) {
+		offset = len(rows)
+	}
+	end := offset + size
+	if end > len(rows) || 0 > size {
+		end = len(rows)
+	}
+
+	list := reflect.MakeSlice(reflect.SliceOf(reflect.TypeOf(modPointer)), 0, end-offset)
+	for _, row := range rows[offset:end] {
+		m := reflect.New(row.Type())
+		m.Elem().Set(row)
+		list = reflect.Append(list, m)
+	}
+	reflect.ValueOf(listPointer).Elem().Set(list)
+	return nil
+}
+
+func (that *MemoryStorage) Count(ctx context.Context, condition map[string]interface{}, modPointer interface{}) (int64, error) {
+	rows, err := that.match(ctx, getModelMeta(modPointer), condition)
+	return int64(len(rows)), err
+}
+
+// AddModelBatch 逐条入库，与数据库不同，失败时已入库的数据不回滚
+func (that *MemoryStorage) AddModelBatch(ctx context.Context, modPointerList interface{}) (int64, error) {
+	if err := ValidateList(modPointerList); nil != err {
+		return 0, err
+	}
+	modLst := reflect.ValueOf(modPointerList)
+	var rows int64
+	for i := 0; i < modLst.Len(); i++ {
+		if _, err := that.AddModel(ctx, modLst.Index(i).Interface()); nil != err {
+			return rows, err
+		}
+		rows++
+	}
+	return rows, nil
+}
+
+// Upsert 主键存在时修改，否则入库
+func (that *MemoryStorage) Upsert(ctx context.Context, modPointer interface{}) (int64, error) {
+	rows, err := that.UpdateByID(ctx, modPointer)
+	if errors.Is(err, ErrNoRowsAffected) {
+		if _, err = that.AddModel(ctx, modPointer); nil != err {
+			return 0, err
+		}
+		return 1, nil
+	}
+	return rows, err
+}
+
+// match 返回符合条件且属于上下文租户的数据副本
+func (that *MemoryStorage) match(ctx context.Context, meta *modelMeta, condition map[string]interface{}) ([]reflect.Value, error) {
+	that.lock.RLock()
+	defer that.lock.RUnlock()
+	rows := make([]reflect.Value, 0)
+	for _, row := range that.tableRows(meta.tableName) {
+		isOk, err := tenantMatch(ctx, meta, row)
+		if nil != err {
+			return nil, err
+		}
+		if isOk && matchCondition(meta, row, condition) {
+			rows = append(rows, copyStruct(row))
+		}
+	}
+	return rows, nil
+}
+
+// matchCondition 按 GetModelFieldCondition 的规则判断一行数据是否符合条件
+func matchCondition(meta *modelMeta, row reflect.Value, condition map[string]interface{}) bool {
+	for k, v := range condition {
+		if IsBaseCond(k) {
+			continue
+		}
+		operator := ""
+		equal := true
+		if strings.HasPrefix(k, NOeq) {
+			equal = false
+			k = k[1:]
+		}
+		if strings.HasPrefix(k, "?") && 3 <= len(k) {
+			operator = k[:3]
+			k = k[3:]
+		}
+		if "" != meta.alias && strings.HasPrefix(k, meta.alias+".") {
+			k = k[len(meta.alias)+1:]
+		}
+		name, field, isOk := meta.modelFieldByKey(k)
+		if !isOk || nil == v || "" == v {
+			continue
+		}
+		fv := row.FieldByName(name).Interface()
+
+		var ok bool
+		if PropertyThing == field.FieldProperty || In == operator {
+			ok = inValues(fv, v)
+			if !equal {
+				ok = !ok
+			}
+		} else {
+			c := CompareValue(fv, v)
+			switch operator {
+			case Gt:
+				ok = 0 < c
+			case Lt:
+				ok = 0 > c
+			case GTeq:
+				ok = 0 <= c
+			case LTeq:
+				ok = 0 >= c
+			default:
+				ok = (0 == c) == equal
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+
+	createField := meta.createTimeField()
+	name, tf, isOk := meta.fieldByTable(createField)
+	if !isOk {
+		return true
+	}
+	// 按时间比较，字段值按其精度与时区转换
+	_, hasBegin := condition[CondBeginTime]
+	_, hasEnd := condition[CondEndTime]
+	if !hasBegin && !hasEnd {
+		return true
+	}
+	tm, err := toTime(row.FieldByName(name).Interface(), tf)
+	if nil != err {
+		return false
+	}
+	if hasBegin {
+		begin, err := condTime(condition[CondBeginTime], tf)
+		if nil != err || tm.Before(begin) {
+			return false
+		}
+	}
+	if hasEnd {
+		end, err := condTime(condition[CondEndTime], tf)
+		if nil != err || !tm.Before(end) {
+			return false
+		}
+	}
+	return true
+}
+
+// inValues IN 条件，v 可以是逗号分隔的字符串或切片
+func inValues(fv interface{}, v interface{}) bool {
+	items := make([]interface{}, 0)
+	rv := reflect.ValueOf(v)
+	if reflect.Slice == rv.Kind() || reflect.Array == rv.Kind() {
+		for i := 0; i < rv.Len(); i++ {
+			items = append(items, rv.Index(i).Interface())
+		}
+	} else {
+		for _, s := range strings.Split(fmt.Sprint(v), ",") {
+			items = append(items, strings.Trim(strings.TrimSpace(s), "'\""))
+		}
+	}
+	for _, item := range items {
+		if 0 == CompareValue(fv, item) {
+			return true
+		}
+	}
+	return false
+}
+
+// sortRows 按 CondORDERField、CondORDERType 排序，未指定时按主键降序
+func sortRows(meta *modelMeta, condition map[string]interface{}, rows []reflect.Value) {
+	fields := make([]string, len(meta.pkFields))
+	for i, f := range meta.pkFields {
+		fields[i], _, _ = meta.fieldByTable(f)
+	}
+	asc := false
+	if v, isOk := condition[CondORDERField]; isOk {
+		fields = make([]string, 0)
+		for _, f := range strings.Split(fmt.Sprint(v), ",") {
+			if name, _, isOk := meta.modelFieldByKey(strings.TrimSpace(f)); isOk {
+				fields = append(fields, name)
+			}
+		}
+		asc = "1" == condition[CondORDERType] || 1 == condition[CondORDERType]
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		for _, f := range fields {
+			c := CompareValue(rows[i].FieldByName(f).Interface(), rows[j].FieldByName(f).Interface())
+			if 0 == c {
+				continue
+			}
+			if asc {
+				return 0 > c
+			}
+			return 0 < c
+		}
+		return false
+	})
+}
+
+// CompareValue 比较两个值，数字按数值比较（字符串形式的数字也按数值），时间按时间先后，其它按字符串比较
+// int	a < b 为 -1，a == b 为 0，a > b 为 1
+func CompareValue(a, b interface{}) int {
+	a = derefValue(a)
+	b = derefValue(b)
+	if ta, isOk := a.(time.Time); isOk {
+		if tb, isOk := b.(time.Time); isOk {
+			return ta.Compare(tb)
+		}
+	}
+	fa, errA := toFloat(a)
+	fb, errB := toFloat(b)
+	if nil == errA && nil == errB {
+		if fa < fb {
+			return -1
+		}
+		if fa > fb {
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
+
+func derefValue(v interface{}) interface{} {
+	rv := reflect.ValueOf(v)
+	for reflect.Ptr == rv.Kind() {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if !rv.IsValid() {
+		return nil
+	}
+	if b, isOk := rv.Interface().([]byte); isOk {
+		return string(b)
+	}
+	return rv.Interface()
+}
+
+func toFloat(v interface{}) (float64, error) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), nil
+	case reflect.Bool:
+		if rv.Bool() {
+			return 1, nil
+		}
+		return 0, nil
+	case reflect.String:
+		return strconv.ParseFloat(rv.String(), 64)
+	}
+	return 0, errors.New("not a number")
+}
+
+func isIntKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
+func reflectInt(v reflect.Value) int64 {
+	if reflect.Int <= v.Kind() && reflect.Int64 >= v.Kind() {
+		return v.Int()
+	}
+	return int64(v.Uint())
+}
+
+func setReflectInt(v reflect.Value, n int64) {
+	if reflect.Int <= v.Kind() && reflect.Int64 >= v.Kind() {
+		v.SetInt(n)
+	} else {
+		v.SetUint(uint64(n))
+	}
+}
+
+// copyStruct 深拷贝 model 结构体，存储与返回的数据不与调用方共用切片、map 与指针
+func copyStruct(val reflect.Value) reflect.Value {
+	return deepCopy(val)
+}
+
+// deepCopy 递归复制指针、切片、map、数组、interface 与结构体的导出字段，未导出字段按值复制（如 time.Time）
+func deepCopy(val reflect.Value) reflect.Value {
+	cp := reflect.New(val.Type()).Elem()
+	switch val.Kind() {
+	case reflect.Ptr:
+		if !val.IsNil() {
+			p := reflect.New(val.Type().Elem())
+			p.Elem().Set(deepCopy(val.Elem()))
+			cp.Set(p)
+		}
+	case reflect.Slice:
+		if !val.IsNil() {
+			cp.Set(reflect.MakeSlice(val.Type(), val.Len(), val.Len()))
+			for i := 0; i < val.Len(); i++ {
+				cp.Index(i).Set(deepCopy(val.Index(i)))
+			}
+		}
+	case reflect.Array:
+		for i := 0; i < val.Len(); i++ {
+			cp.Index(i).Set(deepCopy(val.Index(i)))
+		}
+	case reflect.Map:
+		if !val.IsNil() {
+			cp.Set(reflect.MakeMapWithSize(val.Type(), val.Len()))
+			iter := val.MapRange()
+			for iter.Next() {
+				cp.SetMapIndex(iter.Key(), deepCopy(iter.Value()))
+			}
+		}
+	case reflect.Interface:
+		if !val.IsNil() {
+			cp.Set(deepCopy(val.Elem()))
+		}
+	case reflect.Struct:
+		cp.Set(val)
+		for i := 0; i < val.NumField(); i++ {
+			if f := cp.Field(i); f.CanSet() {
+				f.Set(deepCopy(val.Field(i)))
+			}
+		}
+	default:
+		cp.Set(val)
+	}
+	return cp
+}
@@ -0,0 +1,112 @@
+package at
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMemoryStorageConcurrentFirstRead(t *testing.T) {
+	s := NewMemoryStorage()
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if isOk, err := s.FindByID(context.Background(), &testItem{Id: 1}); nil != err || isOk {
+				t.Errorf("FindByID = %v, %v", isOk, err)
+			}
+			list := make([]*testItem, 0)
+			if err := s.FindList(context.Background(), map[string]interface{}{}, &testItem{}, &list); nil != err || 0 != len(list) {
+				t.Errorf("FindList = %v, %v", list, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if 0 != len(s.tables) {
+		t.Errorf("read created tables: %v", s.tables)
+	}
+}
+
+func TestMemoryStorageUpdateWritesTimestampBack(t *testing.T) {
+	s := NewMemoryStorage()
+	ctx := context.Background()
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	SetClock(FixedClock{T: created})
+	defer SetClock(nil)
+	if _, err := s.AddModel(ctx, &testEvent{Id: 1}); nil != err {
+		t.Fatal(err)
+	}
+
+	SetClock(FixedClock{T: created.Add(time.Hour)})
+	mod := &testEvent{Id: 1}
+	if _, err := s.UpdateByID(ctx, mod); nil != err {
+		t.Fatal(err)
+	}
+	if "2024-01-01 09:00:00" != mod.UpdatedAt {
+		t.Errorf("UpdatedAt = %q, want the update time written back", mod.UpdatedAt)
+	}
+	found := &testEvent{Id: 1}
+	if _, err := s.FindByID(ctx, found); nil != err {
+		t.Fatal(err)
+	}
+	if mod.UpdatedAt != found.UpdatedAt || created.UnixMilli() != found.CreatedAt {
+		t.Errorf("stored = %+v, want UpdatedAt %q and the original CreatedAt", found, mod.UpdatedAt)
+	}
+}
+
+type testTagsOwner struct {
+	Name string
+}
+
+// testTags 测试用的 model，有切片、指针与 map 字段
+type testTags struct {
+	BaseModel
+	Id    int64             `json:"id" table:"id"`
+	Tags  []string          `json:"tags" table:"tags" codec:"csv"`
+	Owner *testTagsOwner    `json:"owner" table:"owner" codec:"json"`
+	Attrs map[string]string `json:"attrs" table:"attrs" codec:"json"`
+}
+
+func (*testTags) GetTableName() string {
+	return "tags"
+}
+
+func TestMemoryStorageDeepCopy(t *testing.T) {
+	s := NewMemoryStorage()
+	ctx := context.Background()
+	mod := &testTags{Tags: []string{"a"}, Owner: &testTagsOwner{Name: "tom"}, Attrs: map[string]string{"k": "v"}}
+	if _, err := s.AddModel(ctx, mod); nil != err {
+		t.Fatal(err)
+	}
+	// 入库后修改调用方的数据不影响存储
+	mod.Tags[0], mod.Owner.Name, mod.Attrs["k"] = "x", "x", "x"
+	found := &testTags{Id: mod.Id}
+	if _, err := s.FindByID(ctx, found); nil != err {
+		t.Fatal(err)
+	}
+	if "a" != found.Tags[0] || "tom" != found.Owner.Name || "v" != found.Attrs["k"] {
+		t.Fatalf("stored row changed with the caller: %+v %+v", found, found.Owner)
+	}
+
+	update := &testTags{Id: mod.Id, Tags: []string{"b"}, Owner: &testTagsOwner{Name: "jerry"}}
+	if _, err := s.UpdateByID(ctx, update); nil != err {
+		t.Fatal(err)
+	}
+	update.Tags[0], update.Owner.Name = "x", "x"
+	// 查询结果修改后不影响存储
+	found.Tags[0] = "x"
+	list := make([]*testTags, 0)
+	if err := s.FindList(ctx, map[string]interface{}{}, &testTags{}, &list); nil != err {
+		t.Fatal(err)
+	}
+	if 1 != len(list) || "b" != list[0].Tags[0] || "jerry" != list[0].Owner.Name || nil != list[0].Attrs {
+		t.Fatalf("stored row = %+v", list[0])
+	}
+	list[0].Owner.Name = "x"
+	again := &testTags{Id: mod.Id}
+	if _, err := s.FindByID(ctx, again); nil != err || "jerry" != again.Owner.Name {
+		t.Errorf("stored owner = %+v, %v", again.Owner, err)
+	}
+}
@@ -0,0 +1,647 @@
+package at
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 建表相关 tag（MySQL）：
+// type	字段类型，如 `type:"VARCHAR(32)"`，没有时按 Go 类型推断
+// comment	字段注释
+// default	默认值，原样写入 DEFAULT，如 `default:"0"`、`default:"''"`
+// index	普通索引名，多个字段同名组成联合索引，顺序与字段顺序一致，如 `index:"idx_user_state"`
+// unique	唯一索引名，规则同 index
+// 主键为 pk tag 的字段，没有 pk tag 时为 GetPKTableField 返回的字段（默认第一个表字段）；
+// 自增的整型主键为 AUTO_INCREMENT（见 PK.go）；指针字段可为 NULL，其它字段 NOT NULL
+
+// 迁移版本记录表
+const SchemaMigrationsTable = "schema_migrations"
+
+// TableColumn 数据库中已存在的字段
+type TableColumn struct {
+	Name     string
+	Type     string
+	Nullable bool
+	Default  sql.NullString
+	Comment  string
+}
+
+type tableIndex struct {
+	name    string
+	unique  bool
+	columns []string
+}
+
+// modelColumn 由 model 生成的字段定义
+type modelColumn struct {
+	name       string
+	sqlType    string
+	nullable   bool
+	defaultVal string
+	comment    string
+	autoIncr   bool
+}
+
+func modelColumns(modPointer interface{}) (*modelMeta, []modelColumn, []tableIndex) {
+	meta := getModelMeta(modPointer)
+	ty := reflect.TypeOf(modPointer)
+	if reflect.Ptr == ty.Kind() {
+		ty = ty.Elem()
+	}
+	columns := make([]modelColumn, 0, len(meta.listTableFields))
+	indexes := make([]tableIndex, 0)
+	indexPos := make(map[string]int)
+	for _, f := range meta.listTableFields {
+		name, tf, _ := meta.fieldByTable(f)
+		sf, _ := ty.FieldByName(name)
+		col := modelColumn{
+			name:       f,
+			sqlType:    tf.FieldType,
+			nullable:   reflect.Ptr == sf.Type.Kind(),
+			defaultVal: sf.Tag.Get("default"),
+			comment:    sf.Tag.Get("comment"),
+		}
+		if "" == col.sqlType {
+			col.sqlType = inferColumnType(sf.Type)
+			// precision tag 的时间字段保存小数秒
+			if "DATETIME" == col.sqlType && PrecisionMilli == tf.FieldPrecision {
+				col.sqlType = "DATETIME(3)"
+			} else if "DATETIME" == col.sqlType && PrecisionMicro == tf.FieldPrecision {
+				col.sqlType = "DATETIME(6)"
+			}
+		}
+		// 主键由 model 赋值或 idgen 生成时不自增
+		col.autoIncr = f == meta.pkField && meta.pkAutoIncrement() && strings.Contains(strings.ToUpper(col.sqlType), "INT")
+		columns = append(columns, col)
+
+		for _, tag := range []string{"index", "unique"} {
+			idx := sf.Tag.Get(tag)
+			if "" == idx {
+				continue
+			}
+			if p, isOk := indexPos[idx]; isOk {
+				indexes[p].columns = append(indexes[p].columns, f)
+				continue
+			}
+			indexPos[idx] = len(indexes)
+			indexes = append(indexes, tableIndex{name: idx, unique: "unique" == tag, columns: []string{f}})
+		}
+	}
+	return meta, columns, indexes
+}
+
+// inferColumnType 没有 type tag 时按 Go 类型推断字段类型
+func inferColumnType(t reflect.Type) string {
+	for reflect.Ptr == t.Kind() {
+		t = t.Elem()
+	}
+	if "Time" == t.Name() {
+		return "DATETIME"
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return "TINYINT(1)"
+	case reflect.Int8:
+		return "TINYINT"
+	case reflect.Uint8:
+		return "TINYINT UNSIGNED"
+	case reflect.Int16:
+		return "SMALLINT"
+	case reflect.Uint16:
+		return "SMALLINT UNSIGNED"
+	case reflect.Int32:
+		return "INT"
+	case reflect.Uint32:
+		return "INT UNSIGNED"
+	case reflect.Int, reflect.Int64:
+		return "BIGINT"
+	case reflect.Uint, reflect.Uint64:
+		return "BIGINT UNSIGNED"
+	case reflect.Float32:
+		return "FLOAT"
+	case reflect.Float64:
+		return "DOUBLE"
+	case reflect.Slice:
+		return "BLOB"
+	}
+	return "VARCHAR(255)"
+}
+
+func (that modelColumn) definition() string {
+	b := strings.Builder{}
+	b.WriteString(fmt.Sprintf("`%s` %s", that.name, that.sqlType))
+	if that.nullable {
+		b.WriteString(" NULL")
+	} else {
+		b.WriteString(" NOT NULL")
+	}
+	if that.autoIncr {
+		b.WriteString(" AUTO_INCREMENT")
+	}
+	if "" != that.defaultVal {
+		b.WriteString(fmt.Sprintf(" DEFAULT %s", that.defaultVal))
+	}
+	if "" != that.comment {
+		b.WriteString(fmt.Sprintf(" COMMENT '%s'", strings.ReplaceAll(that.comment, "'", "''")))
+	}
+	return b.String()
+}
+
+func (that tableIndex) definition() string {
+	kind := "KEY"
+	if that.unique {
+		kind = "UNIQUE KEY"
+	}
+	return fmt.Sprintf("%s `%s` (`%s`)", kind, that.name, strings.Join(that.columns, "`,`"))
+}
+
+// CreateTableSQL 由 model 生成 CREATE TABLE 语句（MySQL）
+// modPointer interface{}	model 的指针
+// string	建表语句
+func CreateTableSQL(modPointer interface{}) string {
+	meta, columns, indexes := modelColumns(modPointer)
+	lines := make([]string, 0, len(columns)+len(indexes)+1)
+	for _, c := range columns {
+		lines = append(lines, "\t"+c.definition())
+	}
+	lines = append(lines, fmt.Sprintf("\tPRIMARY KEY (`%s`)", strings.Join(meta.pkFields, "`,`")))
+	for _, idx := range indexes {
+		lines = append(lines, "\t"+idx.definition())
+	}
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS `%s` (\n%s\n) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4", meta.tableName, strings.Join(lines, ",\n"))
+}
+
+// LoadTableColumns 读取表的字段，表不存在时返回空切片。
+// MySQL 读取当前库（DATABASE()）的 information_schema，SQLite 使用 PRAGMA table_info（见 SetDialect）
+func LoadTableColumns(ctx context.Context, db *sql.DB, tableName string) ([]TableColumn, error) {
+	if DialectSQLite == GetDialect() {
+		return loadSQLiteColumns(ctx, db, tableName)
+	}
+	s := "SELECT COLUMN_NAME,COLUMN_TYPE,IS_NULLABLE,COLUMN_DEFAULT,COLUMN_COMMENT FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? ORDER BY ORDINAL_POSITION"
+	rows, err := db.QueryContext(ctx, s, tableName)
+	if nil != err {
+		return nil, err
+	}
+	defer rows.Close()
+	columns := make([]TableColumn, 0)
+	for rows.Next() {
+		c := TableColumn{}
+		nullable := ""
+		if err = rows.Scan(&c.Name, &c.Type, &nullable, &c.Default, &c.Comment); nil != err {
+			return nil, err
+		}
+		c.Nullable = "YES" == nullable
+		columns = append(columns, c)
+	}
+	return columns, rows.Err()
+}
+
+func loadSQLiteColumns(ctx context.Context, db *sql.DB, tableName string) ([]TableColumn, error) {
+	rows, err := db.QueryContext(ctx, fmt.Sprintf("PRAGMA table_info(`%s`)", tableName))
+	if nil != err {
+		return nil, err
+	}
+	defer rows.Close()
+	columns := make([]TableColumn, 0)
+	for rows.Next() {
+		var cid, notNull, pk int
+		c := TableColumn{}
+		if err = rows.Scan(&cid, &c.Name, &c.Type, &notNull, &c.Default, &pk); nil != err {
+			return nil, err
+		}
+		c.Nullable = 0 == notNull && 0 == pk
+		columns = append(columns, c)
+	}
+	return columns, rows.Err()
+}
+
+// loadTableIndexes 读取表的索引名，MySQL 读取 information_schema，SQLite 使用 PRAGMA index_list
+func loadTableIndexes(ctx context.Context, db *sql.DB, tableName string) (map[string]bool, error) {
+	if DialectSQLite == GetDialect() {
+		return loadSQLiteIndexes(ctx, db, tableName)
+	}
+	s := "SELECT DISTINCT INDEX_NAME FROM information_schema.STATISTICS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ?"
+	rows, err := db.QueryContext(ctx, s, tableName)
+	if nil != err {
+		return nil, err
+	}
+	defer rows.Close()
+	indexes := make(map[string]bool)
+	for rows.Next() {
+		name := ""
+		if err = rows.Scan(&name); nil != err {
+			return nil, err
+		}
+		indexes[name] = true
+	}
+	return indexes, rows.Err()
+}
+
+// loadSQLiteIndexes PRAGMA index_list 的列数随 SQLite 版本不同，按列名取 name
+func loadSQLiteIndexes(ctx context.Context, db *sql.DB, tableName string) (map[string]bool, error) {
+	rows, err := db.QueryContext(ctx, fmt.Sprintf("PRAGMA index_list(`%s`)", tableName))
+	if nil != err {
+		return nil, err
+	}
+	defer rows.Close()
+	cols, err := rows.Columns()
+	if nil != err {
+		return nil, err
+	}
+	nameInx := -1
+	for i, c := range cols {
+		if "name" == strings.ToLower(c) {
+			nameInx = i
+		}
+	}
+	if -1 == nameInx {
+		return nil, errors.New("error:PRAGMA index_list has no name column")
+	}
+	indexes := make(map[string]bool)
+	for rows.Next() {
+		name := ""
+		values := make([]interface{}, len(cols))
+		for i := range values {
+			values[i] = new(interface{})
+		}
+		values[nameInx] = &name
+		if err = rows.Scan(values...); nil != err {
+			return nil, err
+		}
+		indexes[name] = true
+	}
+	return indexes, rows.Err()
+}
+
+var intDisplayWidth = regexp.MustCompile(`^((?:TINY|SMALL|MEDIUM|BIG)?INT)\(\d+\)`)
+
+// normalizeColumnType 统一字段类型写法用于比较：大写、合并空格、去掉整型显示宽度（TINYINT(1) 除外）
+func normalizeColumnType(t string) string {
+	t = strings.Join(strings.Fields(strings.ToUpper(t)), " ")
+	if strings.HasPrefix(t, "TINYINT(1)") {
+		return t
+	}
+	t = intDisplayWidth.ReplaceAllString(t, "$1")
+	if strings.HasPrefix(t, "INTEGER") {
+		t = "INT" + t[len("INTEGER"):]
+	}
+	return t
+}
+
+// DiffTable 比较 model 与数据库中已存在的表，生成升级与回退语句。
+// 表不存在时为建表与删表；表存在时新增缺少的字段与索引，修改类型不一致的字段。
+// 数据库中多出的字段不会删除，避免误删数据。
+// db *sql.DB	数据源（MySQL）
+// modPointer interface{}	model 的指针
+// up []string	升级语句
+// down []string	回退语句，按执行顺序排列
+func DiffTable(ctx context.Context, db *sql.DB, modPointer interface{}) (up []string, down []string, err error) {
+	meta, columns, indexes := modelColumns(modPointer)
+	existing, err := LoadTableColumns(ctx, db, meta.tableName)
+	if nil != err {
+		return nil, nil, err
+	}
+	if 0 == len(existing) {
+		return []string{CreateTableSQL(modPointer)}, []string{fmt.Sprintf("DROP TABLE IF EXISTS `%s`", meta.tableName)}, nil
+	}
+
+	existingMap := make(map[string]TableColumn, len(existing))
+	for _, c := range existing {
+		existingMap[c.Name] = c
+	}
+	up = make([]string, 0)
+	down = make([]string, 0)
+	for _, c := range columns {
+		old, isOk := existingMap[c.name]
+		if !isOk {
+			up = append(up, fmt.Sprintf("ALTER TABLE `%s` ADD COLUMN %s", meta.tableName, c.definition()))
+			down = append(down, fmt.Sprintf("ALTER TABLE `%s` DROP COLUMN `%s`", meta.tableName, c.name))
+			continue
+		}
+		if normalizeColumnType(old.Type) != normalizeColumnType(c.sqlType) || old.Nullable != c.nullable {
+			up = append(up, fmt.Sprintf("ALTER TABLE `%s` MODIFY COLUMN %s", meta.tableName, c.definition()))
+			oldDef := modelColumn{name: old.Name, sqlType: old.Type, nullable: old.Nullable, comment: old.Comment, autoIncr: c.autoIncr}
+			if old.Default.Valid {
+				oldDef.defaultVal = fmt.Sprintf("'%s'", strings.ReplaceAll(old.Default.String, "'", "''"))
+			}
+			down = append(down, fmt.Sprintf("ALTER TABLE `%s` MODIFY COLUMN %s", meta.tableName, oldDef.definition()))
+		}
+	}
+
+	existingIndexes, err := loadTableIndexes(ctx, db, meta.tableName)
+	if nil != err {
+		return nil, nil, err
+	}
+	for _, idx := range indexes {
+		if existingIndexes[idx.name] {
+			continue
+		}
+		up = append(up, fmt.Sprintf("ALTER TABLE `%s` ADD %s", meta.tableName, idx.definition()))
+		down = append(down, fmt.Sprintf("ALTER TABLE `%s` DROP INDEX `%s`", meta.tableName, idx.name))
+	}
+
+	// 回退按相反顺序执行
+	for i, j := 0, len(down)-1; i < j; i, j = i+1, j-1 {
+		down[i], down[j] = down[j], down[i]
+	}
+	return up, down, nil
+}
+
+// Migration 一个版本的迁移
+type Migration struct {
+	Version string
+	Name    string
+	Up      []string
+	Down    []string
+}
+
+var migrationFileName = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// WriteMigration 写入迁移文件 <version>_<name>.up.sql 与 <version>_<name>.down.sql，
+// version 为当前时间 20060102150405 加 3 位序号，同一秒内多次写入时序号递增，版本号按字符串排序与写入顺序一致
+// dir string	迁移文件目录
+// name string	迁移名称，如 add_user_phone
+// up []string	升级语句
+// down []string	回退语句
+// string	版本号
+func WriteMigration(dir, name string, up, down []string) (string, error) {
+	if err := os.MkdirAll(dir, 0755); nil != err {
+		return "", err
+	}
+	migrationVersionLock.Lock()
+	defer migrationVersionLock.Unlock()
+	version, err := nextMigrationVersion(dir)
+	if nil != err {
+		return "", err
+	}
+	base := filepath.Join(dir, fmt.Sprintf("%s_%s", version, name))
+	if err := os.WriteFile(base+".up.sql", []byte(joinStatements(up)), 0644); nil != err {
+		return "", err
+	}
+	if err := os.WriteFile(base+".down.sql", []byte(joinStatements(down)), 0644); nil != err {
+		return "", err
+	}
+	return version, nil
+}
+
+var migrationVersionLock sync.Mutex
+var lastMigrationVersion string
+
+// nextMigrationVersion 当前时间加序号，大于目录中已有与本进程写过的版本
+func nextMigrationVersion(dir string) (string, error) {
+	entries, err := os.ReadDir(dir)
+	if nil != err {
+		return "", err
+	}
+	last := lastMigrationVersion
+	for _, e := range entries {
+		if match := migrationFileName.FindStringSubmatch(e.Name()); nil != match && match[1] > last {
+			last = match[1]
+		}
+	}
+	prefix := time.Now().Format("20060102150405")
+	seq := 0
+	if strings.HasPrefix(last, prefix) && len(last) > len(prefix) {
+		n, err := strconv.Atoi(last[len(prefix):])
+		if nil != err || 999 <= n {
+			return "", errors.New(fmt.Sprintf("error:too many migrations in %s", prefix))
+		}
+		seq = n + 1
+	}
+	version := fmt.Sprintf("%s%03d", prefix, seq)
+	lastMigrationVersion = version
+	return version, nil
+}
+
+// GenerateMigration 比较全部 model 与数据库，有差异时写入一个迁移版本
+// db *sql.DB	数据源
+// dir string	迁移文件目录
+// name string	迁移名称
+// models ...interface{}	model 的指针
+// string	版本号，没有差异时为 ""
+func GenerateMigration(ctx context.Context, db *sql.DB, dir, name string, models ...interface{}) (string, error) {
+	up := make([]string, 0)
+	down := make([]string, 0)
+	for _, m := range models {
+		u, d, err := DiffTable(ctx, db, m)
+		if nil != err {
+			return "", err
+		}
+		up = append(up, u...)
+		down = append(d, down...)
+	}
+	if 0 == len(up) {
+		return "", nil
+	}
+	return WriteMigration(dir, name, up, down)
+}
+
+func joinStatements(statements []string) string {
+	b := strings.Builder{}
+	for _, s := range statements {
+		b.WriteString(s)
+		b.WriteString(";\n")
+	}
+	return b.String()
+}
+
+// splitStatements 按行尾的 ; 拆分语句，忽略空行与 -- 注释行
+func splitStatements(content string) []string {
+	statements := make([]string, 0)
+	current := strings.Builder{}
+	for _, line := range strings.Split(content, "\n") {
+		trim := strings.TrimSpace(line)
+		if "" == trim || strings.HasPrefix(trim, "--") {
+			continue
+		}
+		current.WriteString(line)
+		current.WriteString("\n")
+		if strings.HasSuffix(trim, ";") {
+			s := strings.TrimSuffix(strings.TrimSpace(current.String()), ";")
+			statements = append(statements, s)
+			current.Reset()
+		}
+	}
+	if s := strings.TrimSpace(current.String()); "" != s {
+		statements = append(statements, s)
+	}
+	return statements
+}
+
+// LoadMigrations 读取目录中的迁移文件，按版本升序
+func LoadMigrations(dir string) ([]*Migration, error) {
+	entries, err := os.ReadDir(dir)
+	if nil != err {
+		return nil, err
+	}
+	byVersion := make(map[string]*Migration)
+	for _, e := range entries {
+		match := migrationFileName.FindStringSubmatch(e.Name())
+		if e.IsDir() || nil == match {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if nil != err {
+			return nil, err
+		}
+		m, isOk := byVersion[match[1]]
+		if !isOk {
+			m = &Migration{Version: match[1], Name: match[2]}
+			byVersion[match[1]] = m
+		}
+		if "up" == match[3] {
+			m.Up = splitStatements(string(content))
+		} else {
+			m.Down = splitStatements(string(content))
+		}
+	}
+	list := make([]*Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		list = append(list, m)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Version < list[j].Version
+	})
+	return list, nil
+}
+
+// Migrator 执行迁移文件，已执行的版本记录在 schema_migrations 表
+type Migrator struct {
+	db  *sql.DB
+	dir string
+}
+
+// NewMigrator 创建迁移执行器
+// db *sql.DB	数据源
+// dir string	迁移文件目录
+func NewMigrator(db *sql.DB, dir string) *Migrator {
+	return &Migrator{db: db, dir: dir}
+}
+
+func (that *Migrator) ensureTable(ctx context.Context) error {
+	s := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (version VARCHAR(32) NOT NULL PRIMARY KEY, name VARCHAR(255) NOT NULL, applied_at BIGINT NOT NULL)", SchemaMigrationsTable)
+	_, err := that.db.ExecContext(ctx, s)
+	return err
+}
+
+// Applied 已执行的版本，升序
+func (that *Migrator) Applied(ctx context.Context) ([]string, error) {
+	if err := that.ensureTable(ctx); nil != err {
+		return nil, err
+	}
+	rows, err := that.db.QueryContext(ctx, fmt.Sprintf("SELECT version FROM %s ORDER BY version", SchemaMigrationsTable))
+	if nil != err {
+		return nil, err
+	}
+	defer rows.Close()
+	versions := make([]string, 0)
+	for rows.Next() {
+		v := ""
+		if err = rows.Scan(&v); nil != err {
+			return nil, err
+		}
+		versions = append(versions, v)
+	}
+	return versions, rows.Err()
+}
+
+// Pending 未执行的迁移，升序
+func (that *Migrator) Pending(ctx context.Context) ([]*Migration, error) {
+	applied, err := that.Applied(ctx)
+	if nil != err {
+		return nil, err
+	}
+	done := make(map[string]bool, len(applied))
+	for _, v := range applied {
+		done[v] = true
+	}
+	all, err := LoadMigrations(that.dir)
+	if nil != err {
+		return nil, err
+	}
+	pending := make([]*Migration, 0)
+	for _, m := range all {
+		if !done[m.Version] {
+			pending = append(pending, m)
+		}
+	}
+	return pending, nil
+}
+
+// Up 按版本顺序执行全部未执行的迁移，每个版本在一个事务内执行并记录版本。
+// 注意 MySQL 的 DDL 会隐式提交，失败时已执行的 DDL 不会回滚。
+// []string	本次执行的版本
+func (that *Migrator) Up(ctx context.Context) ([]string, error) {
+	pending, err := that.Pending(ctx)
+	if nil != err {
+		return nil, err
+	}
+	done := make([]string, 0, len(pending))
+	for _, m := range pending {
+		err = that.run(ctx, m.Up, func(tx *sql.Tx) error {
+			_, err := tx.ExecContext(ctx, fmt.Sprintf("INSERT INTO %s(version,name,applied_at) VALUES(?,?,?)", SchemaMigrationsTable), m.Version, m.Name, time.Now().Unix())
+			return err
+		})
+		if nil != err {
+			return done, fmt.Errorf("migration %s_%s up: %w", m.Version, m.Name, err)
+		}
+		done = append(done, m.Version)
+	}
+	return done, nil
+}
+
+// Down 回退最近执行的 steps 个版本
+// []string	本次回退的版本
+func (that *Migrator) Down(ctx context.Context, steps int) ([]string, error) {
+	applied, err := that.Applied(ctx)
+	if nil != err {
+		return nil, err
+	}
+	all, err := LoadMigrations(that.dir)
+	if nil != err {
+		return nil, err
+	}
+	byVersion := make(map[string]*Migration, len(all))
+	for _, m := range all {
+		byVersion[m.Version] = m
+	}
+
+	done := make([]string, 0, steps)
+	for i := len(applied) - 1; i >= 0 && len(done) < steps; i-- {
+		m, isOk := byVersion[applied[i]]
+		if !isOk {
+			return done, errors.New(fmt.Sprintf("migration %s not found in %s", applied[i], that.dir))
+		}
+		err = that.run(ctx, m.Down, func(tx *sql.Tx) error {
+			_, err := tx.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE version = ?", SchemaMigrationsTable), m.Version)
+			return err
+		})
+		if nil != err {
+			return done, fmt.Errorf("migration %s_%s down: %w", m.Version, m.Name, err)
+		}
+		done = append(done, m.Version)
+	}
+	return done, nil
+}
+
+func (that *Migrator) run(ctx context.Context, statements []string, record func(tx *sql.Tx) error) error {
+	return GetInstanceByBaseDao().Transaction(that.db, func(tx *sql.Tx) error {
+		for _, s := range statements {
+			if _, err := tx.ExecContext(ctx, s); nil != err {
+				return err
+			}
+		}
+		return record(tx)
+	})
+}
@@ -0,0 +1,53 @@
+package at
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+	"testing"
+)
+
+func TestLoadTableIndexesSQLite(t *testing.T) {
+	SetDialect(DialectSQLite)
+	defer SetDialect(DialectMySQL)
+	f, db := newFakeDB()
+	defer db.Close()
+	f.rows = func(string) ([]string, [][]driver.Value) {
+		return []string{"seq", "name", "unique", "origin", "partial"}, [][]driver.Value{
+			{int64(0), "idx_person_name", int64(0), "c", int64(0)},
+		}
+	}
+	indexes, err := loadTableIndexes(context.Background(), db, "person")
+	if nil != err {
+		t.Fatal(err)
+	}
+	if !indexes["idx_person_name"] || 1 != len(indexes) {
+		t.Errorf("indexes = %v", indexes)
+	}
+	if 1 != len(f.execs) || !strings.HasPrefix(f.execs[0], "PRAGMA index_list") {
+		t.Errorf("queries = %v", f.execs)
+	}
+}
+
+func TestWriteMigrationVersionsUnique(t *testing.T) {
+	dir := t.TempDir()
+	versions := make(map[string]bool)
+	last := ""
+	for i := 0; i < 5; i++ {
+		v, err := WriteMigration(dir, "m", []string{"SELECT 1"}, nil)
+		if nil != err {
+			t.Fatal(err)
+		}
+		if versions[v] || v <= last {
+			t.Fatalf("version %s after %s", v, last)
+		}
+		versions[v], last = true, v
+	}
+	list, err := LoadMigrations(dir)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if 5 != len(list) {
+		t.Errorf("loaded %d migrations, want 5", len(list))
+	}
+}
@@ -0,0 +1,264 @@
+package at
+
+import "time"
+
+// testPerson 测试用的 model，phone 加密并有盲索引
+type testPerson struct {
+	BaseModel
+	Id        int64  `json:"id" table:"id"`
+	Name      string `json:"name" table:"name"`
+	Phone     string `json:"phone" table:"phone" encrypt:"phone_bidx"`
+	PhoneBidx string `json:"-" table:"phone_bidx"`
+}
+
+func (*testPerson) GetTableName() string {
+	return "person"
+}
+
+func (*testPerson) GetDefaultAlias() string {
+	return "p"
+}
+
+func (*testPerson) GetPKTableField() string {
+	return "id"
+}
+
+func (that *testPerson) GetPKValue() interface{} {
+	return that.Id
+}
+
+func (that *testPerson) GetFieldsSQLByInsert(alias string) (string, string) {
+	l, m := that.ModelToTableFields(that)
+	f, v, _ := that.GetModelFieldsByInsertToFieldStr(alias, l, m)
+	return f, v
+}
+
+func (that *testPerson) GetFieldsSQLByUpdate(alias string) string {
+	l, m := that.ModelToTableFields(that)
+	f, _ := that.GetModelFieldsByUpdateToFieldStr(alias, l, m)
+	return f
+}
+
+func (that *testPerson) GetValueListByTableField(alias, fieldSQL string) []interface{} {
+	_, m := that.ModelToTableFields(that)
+	return that.GetModelTableFieldValueList(alias, fieldSQL, m, that)
+}
+
+// testItem 测试用的 model，使用查询缓存
+type testItem struct {
+	BaseModel
+	Id    int64  `json:"id" table:"id"`
+	Title string `json:"title" table:"title"`
+}
+
+func (*testItem) GetTableName() string {
+	return "item"
+}
+
+func (*testItem) GetDefaultAlias() string {
+	return "i"
+}
+
+func (*testItem) GetPKTableField() string {
+	return "id"
+}
+
+func (that *testItem) GetPKValue() interface{} {
+	return that.Id
+}
+
+func (*testItem) CacheTTL() time.Duration {
+	return time.Minute
+}
+
+func (that *testItem) GetFieldsSQLByInsert(alias string) (string, string) {
+	l, m := that.ModelToTableFields(that)
+	f, v, _ := that.GetModelFieldsByInsertToFieldStr(alias, l, m)
+	return f, v
+}
+
+func (that *testItem) GetFieldsSQLByUpdate(alias string) string {
+	l, m := that.ModelToTableFields(that)
+	f, _ := that.GetModelFieldsByUpdateToFieldStr(alias, l, m)
+	return f
+}
+
+func (that *testItem) GetValueListByTableField(alias, fieldSQL string) []interface{} {
+	_, m := that.ModelToTableFields(that)
+	return that.GetModelTableFieldValueList(alias, fieldSQL, m, that)
+}
+
+// testEvent 测试用的 model，创建时间为毫秒时间戳，最后更新为上海时区的时间字符串
+type testEvent struct {
+	BaseModel
+	Id        int64  `json:"id" table:"id"`
+	CreatedAt int64  `json:"createdAt" table:"created_at" comment:"创建时间" precision:"ms" tz:"Asia/Shanghai"`
+	UpdatedAt string `json:"updatedAt" table:"updated_at" comment:"最后更新" tz:"Asia/Shanghai"`
+}
+
+func (*testEvent) GetTableName() string {
+	return "event"
+}
+
+func (*testEvent) GetDefaultAlias() string {
+	return "e"
+}
+
+func (*testEvent) GetPKTableField() string {
+	return "id"
+}
+
+func (that *testEvent) GetPKValue() interface{} {
+	return that.Id
+}
+
+func (that *testEvent) GetFieldsSQLByInsert(alias string) (string, string) {
+	l, m := that.ModelToTableFields(that)
+	f, v, _ := that.GetModelFieldsByInsertToFieldStr(alias, l, m)
+	return f, v
+}
+
+func (that *testEvent) GetFieldsSQLByUpdate(alias string) string {
+	l, m := that.ModelToTableFields(that)
+	f, _ := that.GetModelFieldsByUpdateToFieldStr(alias, l, m)
+	return f
+}
+
+func (that *testEvent) GetValueListByTableField(alias, fieldSQL string) []interface{} {
+	_, m := that.ModelToTableFields(that)
+	return that.GetModelTableFieldValueList(alias, fieldSQL, m, that)
+}
+
+// testNote 测试用的 model，按租户隔离并记录审计
+type testNote struct {
+	BaseModel
+	Id       int64  `json:"id" table:"id"`
+	TenantId int64  `json:"tenantId" table:"tenant_id" tenant:""`
+	Body     string `json:"body" table:"body"`
+}
+
+func (*testNote) GetTableName() string {
+	return "note"
+}
+
+func (*testNote) GetDefaultAlias() string {
+	return "n"
+}
+
+func (*testNote) GetPKTableField() string {
+	return "id"
+}
+
+func (that *testNote) GetPKValue() interface{} {
+	return that.Id
+}
+
+func (*testNote) AuditEnabled() bool {
+	return true
+}
+
+func (that *testNote) GetFieldsSQLByInsert(alias string) (string, string) {
+	l, m := that.ModelToTableFields(that)
+	f, v, _ := that.GetModelFieldsByInsertToFieldStr(alias, l, m)
+	return f, v
+}
+
+func (that *testNote) GetFieldsSQLByUpdate(alias string) string {
+	l, m := that.ModelToTableFields(that)
+	f, _ := that.GetModelFieldsByUpdateToFieldStr(alias, l, m)
+	return f
+}
+
+func (that *testNote) GetValueListByTableField(alias, fieldSQL string) []interface{} {
+	_, m := that.ModelToTableFields(that)
+	return that.GetModelTableFieldValueList(alias, fieldSQL, m, that)
+}
+
+// testOrderSku 测试用的 model，order_id 与 sku_id 为联合主键
+type testOrderSku struct {
+	BaseModel
+	OrderId int64 `json:"orderId" table:"order_id" pk:""`
+	SkuId   int64 `json:"skuId" table:"sku_id" pk:""`
+	Qty     int   `json:"qty" table:"qty"`
+}
+
+func (*testOrderSku) GetTableName() string {
+	return "order_sku"
+}
+
+func (*testOrderSku) GetDefaultAlias() string {
+	return "os"
+}
+
+func (*testOrderSku) GetPKTableField() string {
+	return "order_id"
+}
+
+func (that *testOrderSku) GetPKValue() interface{} {
+	return that.OrderId
+}
+
+func (that *testOrderSku) GetFieldsSQLByInsert(alias string) (string, string) {
+	l, m := that.ModelToTableFields(that)
+	f, v, _ := that.GetModelFieldsByInsertToFieldStr(alias, l, m)
+	return f, v
+}
+
+func (that *testOrderSku) GetFieldsSQLByUpdate(alias string) string {
+	l, m := that.ModelToTableFields(that)
+	f, _ := that.GetModelFieldsByUpdateToFieldStr(alias, l, m)
+	return f
+}
+
+func (that *testOrderSku) GetValueListByTableField(alias, fieldSQL string) []interface{} {
+	_, m := that.ModelToTableFields(that)
+	return that.GetModelTableFieldValueList(alias, fieldSQL, m, that)
+}
+
+// testAccount 测试用的 model，记录审计，secret 加密
+type testAccount struct {
+	BaseModel
+	Id      int64   `json:"id" table:"id"`
+	Name    string  `json:"name" table:"name"`
+	Balance float64 `json:"balance" table:"balance"`
+	Level   int     `json:"level" table:"level"`
+	Active  bool    `json:"active" table:"active"`
+	Secret  string  `json:"-" table:"secret" encrypt:""`
+}
+
+func (*testAccount) GetTableName() string {
+	return "account"
+}
+
+func (*testAccount) GetDefaultAlias() string {
+	return "a"
+}
+
+func (*testAccount) GetPKTableField() string {
+	return "id"
+}
+
+func (that *testAccount) GetPKValue() interface{} {
+	return that.Id
+}
+
+func (*testAccount) AuditEnabled() bool {
+	return true
+}
+
+func (that *testAccount) GetFieldsSQLByInsert(alias string) (string, string) {
+	l, m := that.ModelToTableFields(that)
+	f, v, _ := that.GetModelFieldsByInsertToFieldStr(alias, l, m)
+	return f, v
+}
+
+func (that *testAccount) GetFieldsSQLByUpdate(alias string) string {
+	l, m := that.ModelToTableFields(that)
+	f, _ := that.GetModelFieldsByUpdateToFieldStr(alias, l, m)
+	return f
+}
+
+func (that *testAccount) GetValueListByTableField(alias, fieldSQL string) []interface{} {
+	_, m := that.ModelToTableFields(that)
+	return that.GetModelTableFieldValueList(alias, fieldSQL, m, that)
+}
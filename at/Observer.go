@@ -0,0 +1,326 @@
+package at
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 语句操作名，QueryEvent.Operation 的取值
+const (
+	OpAddModel           = "AddModel"
+	OpAddModelBatch      = "AddModelBatch"
+	OpUpdateByID         = "UpdateByID"
+	OpDeleteByID         = "DeleteByID"
+	OpUpdate             = "Update"
+	OpUpdateMustAffected = "UpdateMustAffected"
+	OpAuditBefore        = "AuditBefore"
+	OpAuditWrite         = "AuditWrite"
+	OpFindByID           = "FindByID"
+	OpFindList           = "FindList"
+	OpCount              = "Count"
+	OpIterate            = "Iterate"
+	OpUpsert             = "Upsert"
+)
+
+// QueryEvent BaseDao 执行一条语句的信息
+type QueryEvent struct {
+	// Table 表名，执行自定义 SQL 时为 ""
+	Table string
+	// Operation 操作名，如 AddModel、UpdateByID
+	Operation string
+	SQL       string
+	Args      []interface{}
+	// Start 开始执行时间
+	Start time.Time
+	// Duration 执行耗时，BeforeQuery 时为 0
+	Duration time.Duration
+	// RowsAffected 受影响行数（查询为读取行数），未知时为 -1
+	RowsAffected int64
+	// Err 执行错误
+	Err error
+}
+
+// Observer 语句观察者，BaseDao 执行每一条语句前后调用，实现中不应阻塞
+type Observer interface {
+	BeforeQuery(ctx context.Context, event *QueryEvent)
+	AfterQuery(ctx context.Context, event *QueryEvent)
+}
+
+var observers []Observer
+var observersLock sync.RWMutex
+
+// AddObserver 注册语句观察者
+func AddObserver(o Observer) {
+	observersLock.Lock()
+	defer observersLock.Unlock()
+	observers = append(observers, o)
+}
+
+// ClearObserver 移除全部语句观察者
+func ClearObserver() {
+	observersLock.Lock()
+	defer observersLock.Unlock()
+	observers = nil
+}
+
+func getObservers() []Observer {
+	observersLock.RLock()
+	defer observersLock.RUnlock()
+	return observers
+}
+
+func notifyBefore(ctx context.Context, event *QueryEvent) {
+	for _, o := range getObservers() {
+		o.BeforeQuery(ctx, event)
+	}
+}
+
+func notifyAfter(ctx context.Context, event *QueryEvent) {
+	event.Duration = time.Since(event.Start)
+	for _, o := range getObservers() {
+		o.AfterQuery(ctx, event)
+	}
+}
+
+// Querier *sql.DB 与 *sql.Tx 均实现，查询方法可以在事务内或事务外执行
+type Querier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// beginEvent 语句执行前通知观察者
+func (that *BaseDao) beginEvent(ctx context.Context, tableName, op, s string, args []interface{}) *QueryEvent {
+	event := &QueryEvent{Table: tableName, Operation: op, SQL: s, Args: args, Start: time.Now(), RowsAffected: -1}
+	notifyBefore(ctx, event)
+	return event
+}
+
+// endEvent 语句执行后通知观察者并输出日志
+func (that *BaseDao) endEvent(ctx context.Context, event *QueryEvent, rows int64, err error) {
+	event.RowsAffected = rows
+	event.Err = err
+	notifyAfter(ctx, event)
+	that.logEvent(ctx, event)
+}
+
+// execContext 执行语句，通知观察者并输出日志
+func (that *BaseDao) execContext(ctx context.Context, tx *sql.Tx, tableName, op, s string, args ...interface{}) (sql.Result, error) {
+	event := that.beginEvent(ctx, tableName, op, s, args)
+	result, err := txExecContext(ctx, tx, op, s, args...)
+	err = wrapDaoError(tableName, op, err)
+	rows := int64(-1)
+	if nil == err {
+		if n, err2 := result.RowsAffected(); nil == err2 {
+			rows = n
+		}
+	}
+	that.endEvent(ctx, event, rows, err)
+	return result, err
+}
+
+// queryRowScanContext 查询一行并扫描到 dest，通知观察者并输出日志，没有数据时返回 ErrNotFound
+func (that *BaseDao) queryRowScanContext(ctx context.Context, q Querier, tableName, op, s string, args []interface{}, dest ...interface{}) error {
+	event := that.beginEvent(ctx, tableName, op, s, args)
+	err := q.QueryRowContext(ctx, s, args...).Scan(dest...)
+	rows := int64(-1)
+	if nil == err {
+		rows = 1
+	} else if sql.ErrNoRows == err {
+		rows = 0
+	}
+	err = wrapDaoError(tableName, op, err)
+	that.endEvent(ctx, event, rows, err)
+	return err
+}
+
+// SlowQueryLogger 慢查询日志，耗时达到 Threshold 的语句输出到 Logs。
+// Logs 实现 StructuredLogs 时以 Warn 级别（语句出错为 Error）输出 key/value 属性，
+// 否则调用 Logs.Error，成功的慢查询 err 为 ErrSlowQuery
+type SlowQueryLogger struct {
+	Threshold time.Duration
+	// Logs 为 nil 时使用 InitDao 设置的日志
+	Logs Logs
+}
+
+// ErrSlowQuery 成功的慢查询输出到 Logs.Error 时的 err
+var ErrSlowQuery = errors.New("error:slow query")
+
+// NewSlowQueryLogger 创建慢查询日志观察者
+// threshold time.Duration	慢查询阈值
+// log Logs	日志输出，为 nil 时使用 InitDao 设置的日志
+func NewSlowQueryLogger(threshold time.Duration, log Logs) *SlowQueryLogger {
+	return &SlowQueryLogger{Threshold: threshold, Logs: log}
+}
+
+func (that *SlowQueryLogger) BeforeQuery(context.Context, *QueryEvent) {
+}
+
+func (that *SlowQueryLogger) AfterQuery(ctx context.Context, event *QueryEvent) {
+	if event.Duration < that.Threshold {
+		return
+	}
+	log := that.Logs
+	if nil == log {
+		log = daoLogs
+	}
+	if nil == log {
+		return
+	}
+	if sl, isOk := log.(StructuredLogs); isOk {
+		level := slog.LevelWarn
+		attrs := []slog.Attr{
+			slog.String(LogKeyTable, event.Table),
+			slog.String(LogKeyOperation, event.Operation),
+			slog.String(LogKeySQL, event.SQL),
+			slog.Any(LogKeyArgs, event.Args),
+			slog.Duration(LogKeyDuration, event.Duration),
+			slog.Int64(LogKeyRows, event.RowsAffected),
+		}
+		if nil != event.Err {
+			level = slog.LevelError
+			attrs = append(attrs, slog.Any(LogKeyErr, event.Err))
+		}
+		sl.Log(ctx, level, "slow query", attrs...)
+		return
+	}
+	msg := fmt.Sprintf("slow query %s %s duration=%s rows=%d sql=%s args=%v", event.Table, event.Operation, event.Duration, event.RowsAffected, event.SQL, event.Args)
+	err := event.Err
+	if nil == err {
+		err = ErrSlowQuery
+	}
+	log.Error(msg, err)
+}
+
+// 耗时直方图默认分桶（秒）
+var defaultDurationBuckets = []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5}
+
+type metricsKey struct {
+	table     string
+	operation string
+}
+
+type metricsValue struct {
+	count   uint64
+	errors  uint64
+	rows    int64
+	sum     float64
+	buckets []uint64
+}
+
+// MetricsCollector 语句指标收集，以 Prometheus 文本格式输出
+type MetricsCollector struct {
+	buckets []float64
+	lock    sync.Mutex
+	values  map[metricsKey]*metricsValue
+}
+
+// NewMetricsCollector 创建指标收集观察者
+// buckets []float64	耗时直方图分桶（秒，升序），为 nil 时使用默认分桶
+func NewMetricsCollector(buckets []float64) *MetricsCollector {
+	if 0 == len(buckets) {
+		buckets = defaultDurationBuckets
+	}
+	return &MetricsCollector{buckets: buckets, values: make(map[metricsKey]*metricsValue)}
+}
+
+func (that *MetricsCollector) BeforeQuery(context.Context, *QueryEvent) {
+}
+
+func (that *MetricsCollector) AfterQuery(_ context.Context, event *QueryEvent) {
+	seconds := event.Duration.Seconds()
+	key := metricsKey{table: event.Table, operation: event.Operation}
+
+	that.lock.Lock()
+	defer that.lock.Unlock()
+	v, isOk := that.values[key]
+	if !isOk {
+		v = &metricsValue{buckets: make([]uint64, len(that.buckets))}
+		that.values[key] = v
+	}
+	v.count++
+	v.sum += seconds
+	if nil != event.Err {
+		v.errors++
+	}
+	if 0 < event.RowsAffected {
+		v.rows += event.RowsAffected
+	}
+	for i, b := range that.buckets {
+		if seconds <= b {
+			v.buckets[i]++
+		}
+	}
+}
+
+// WritePrometheus 以 Prometheus 文本格式输出全部指标
+func (that *MetricsCollector) WritePrometheus(w io.Writer) error {
+	that.lock.Lock()
+	keys := make([]metricsKey, 0, len(that.values))
+	values := make(map[metricsKey]metricsValue, len(that.values))
+	for k, v := range that.values {
+		keys = append(keys, k)
+		cp := *v
+		cp.buckets = append([]uint64(nil), v.buckets...)
+		values[k] = cp
+	}
+	that.lock.Unlock()
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].table != keys[j].table {
+			return keys[i].table < keys[j].table
+		}
+		return keys[i].operation < keys[j].operation
+	})
+
+	b := strings.Builder{}
+	b.WriteString("# HELP pmc_at_queries_total Total number of statements executed by BaseDao.\n")
+	b.WriteString("# TYPE pmc_at_queries_total counter\n")
+	for _, k := range keys {
+		b.WriteString(fmt.Sprintf("pmc_at_queries_total{%s} %d\n", k.labels(), values[k].count))
+	}
+	b.WriteString("# HELP pmc_at_query_errors_total Total number of statements that returned an error.\n")
+	b.WriteString("# TYPE pmc_at_query_errors_total counter\n")
+	for _, k := range keys {
+		b.WriteString(fmt.Sprintf("pmc_at_query_errors_total{%s} %d\n", k.labels(), values[k].errors))
+	}
+	b.WriteString("# HELP pmc_at_rows_affected_total Total number of rows affected or read.\n")
+	b.WriteString("# TYPE pmc_at_rows_affected_total counter\n")
+	for _, k := range keys {
+		b.WriteString(fmt.Sprintf("pmc_at_rows_affected_total{%s} %d\n", k.labels(), values[k].rows))
+	}
+	b.WriteString("# HELP pmc_at_query_duration_seconds Statement latency in seconds.\n")
+	b.WriteString("# TYPE pmc_at_query_duration_seconds histogram\n")
+	for _, k := range keys {
+		v := values[k]
+		for i, bucket := range that.buckets {
+			b.WriteString(fmt.Sprintf("pmc_at_query_duration_seconds_bucket{%s,le=\"%g\"} %d\n", k.labels(), bucket, v.buckets[i]))
+		}
+		b.WriteString(fmt.Sprintf("pmc_at_query_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", k.labels(), v.count))
+		b.WriteString(fmt.Sprintf("pmc_at_query_duration_seconds_sum{%s} %g\n", k.labels(), v.sum))
+		b.WriteString(fmt.Sprintf("pmc_at_query_duration_seconds_count{%s} %d\n", k.labels(), v.count))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// ServeHTTP 作为 /metrics 接口输出
+func (that *MetricsCollector) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	that.WritePrometheus(w)
+}
+
+func (that metricsKey) labels() string {
+	return fmt.Sprintf(`table="%s",operation="%s"`, labelEscaper.Replace(that.table), labelEscaper.Replace(that.operation))
+}
+
+// labelEscaper Prometheus 文本格式的标签值只转义 \、" 与换行
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
@@ -0,0 +1,126 @@
+package at
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// structuredRecord StructuredLogs 收到的一条日志
+type structuredRecord struct {
+	level slog.Level
+	msg   string
+	attrs map[string]slog.Value
+}
+
+// structuredLogs 记录日志的 StructuredLogs
+type structuredLogs struct {
+	lock    sync.Mutex
+	records []structuredRecord
+}
+
+func (that *structuredLogs) Debug(msg string) {
+	that.Log(context.Background(), slog.LevelDebug, msg)
+}
+
+func (that *structuredLogs) Error(msg string, err error) {
+	that.Log(context.Background(), slog.LevelError, msg, slog.Any(LogKeyErr, err))
+}
+
+func (that *structuredLogs) Log(_ context.Context, level slog.Level, msg string, attrs ...slog.Attr) {
+	r := structuredRecord{level: level, msg: msg, attrs: make(map[string]slog.Value, len(attrs))}
+	for _, a := range attrs {
+		r.attrs[a.Key] = a.Value
+	}
+	that.lock.Lock()
+	defer that.lock.Unlock()
+	that.records = append(that.records, r)
+}
+
+func slowEvent(d time.Duration, err error) *QueryEvent {
+	return &QueryEvent{Table: "person", Operation: OpFindList, SQL: "SELECT 1", Args: []interface{}{7}, Duration: d, RowsAffected: 3, Err: err}
+}
+
+func TestSlowQueryLoggerStructured(t *testing.T) {
+	logs := &structuredLogs{}
+	o := NewSlowQueryLogger(100*time.Millisecond, logs)
+	ctx := context.Background()
+
+	o.AfterQuery(ctx, slowEvent(time.Millisecond, nil))
+	if 0 != len(logs.records) {
+		t.Fatalf("fast query logged: %v", logs.records)
+	}
+
+	o.AfterQuery(ctx, slowEvent(time.Second, nil))
+	errQuery := errors.New("query failed")
+	o.AfterQuery(ctx, slowEvent(time.Second, errQuery))
+	if 2 != len(logs.records) {
+		t.Fatalf("records = %v", logs.records)
+	}
+	r := logs.records[0]
+	if slog.LevelWarn != r.level {
+		t.Errorf("slow query level = %v, want Warn", r.level)
+	}
+	if "person" != r.attrs[LogKeyTable].String() || OpFindList != r.attrs[LogKeyOperation].String() ||
+		"SELECT 1" != r.attrs[LogKeySQL].String() || time.Second != r.attrs[LogKeyDuration].Duration() || 3 != r.attrs[LogKeyRows].Int64() {
+		t.Errorf("attrs = %v", r.attrs)
+	}
+	if _, isOk := r.attrs[LogKeyErr]; isOk {
+		t.Errorf("successful query has err attr: %v", r.attrs)
+	}
+	r = logs.records[1]
+	if slog.LevelError != r.level || errQuery != r.attrs[LogKeyErr].Any() {
+		t.Errorf("failed slow query = %v %v, want Error with err", r.level, r.attrs)
+	}
+}
+
+func TestSlowQueryLoggerPlain(t *testing.T) {
+	logs := &bufferLogs{}
+	o := NewSlowQueryLogger(100*time.Millisecond, logs)
+	o.AfterQuery(context.Background(), slowEvent(time.Second, nil))
+	o.AfterQuery(context.Background(), slowEvent(time.Second, errors.New("query failed")))
+	lines := strings.Split(strings.TrimSpace(logs.buf.String()), "\n")
+	if 2 != len(lines) {
+		t.Fatalf("lines = %q", lines)
+	}
+	if !strings.Contains(lines[0], "sql=SELECT 1") || !strings.HasSuffix(lines[0], ErrSlowQuery.Error()) {
+		t.Errorf("slow query = %q, want Error with ErrSlowQuery", lines[0])
+	}
+	if !strings.HasSuffix(lines[1], "query failed") {
+		t.Errorf("failed slow query = %q", lines[1])
+	}
+}
+
+func TestMetricsCollector(t *testing.T) {
+	m := NewMetricsCollector([]float64{0.01, 1})
+	ctx := context.Background()
+	m.AfterQuery(ctx, &QueryEvent{Table: "person", Operation: OpFindList, Duration: 5 * time.Millisecond, RowsAffected: 3})
+	m.AfterQuery(ctx, &QueryEvent{Table: "person", Operation: OpFindList, Duration: 500 * time.Millisecond, RowsAffected: -1, Err: errors.New("x")})
+	m.AfterQuery(ctx, &QueryEvent{Table: "a\"b\\c\nd\t表", Operation: OpCount, Duration: 2 * time.Second})
+
+	var out bytes.Buffer
+	if err := m.WritePrometheus(&out); nil != err {
+		t.Fatal(err)
+	}
+	s := out.String()
+	for _, want := range []string{
+		`pmc_at_queries_total{table="person",operation="FindList"} 2`,
+		`pmc_at_query_errors_total{table="person",operation="FindList"} 1`,
+		`pmc_at_rows_affected_total{table="person",operation="FindList"} 3`,
+		`pmc_at_query_duration_seconds_bucket{table="person",operation="FindList",le="0.01"} 1`,
+		`pmc_at_query_duration_seconds_bucket{table="person",operation="FindList",le="1"} 2`,
+		`pmc_at_query_duration_seconds_bucket{table="person",operation="FindList",le="+Inf"} 2`,
+		`pmc_at_query_duration_seconds_count{table="person",operation="FindList"} 2`,
+		// 只转义 \、" 与换行，tab 与中文原样输出
+		"pmc_at_queries_total{table=\"a\\\"b\\\\c\\nd\t表\",operation=\"Count\"} 1",
+	} {
+		if !strings.Contains(s, want) {
+			t.Errorf("missing %q in\n%s", want, s)
+		}
+	}
+}
@@ -0,0 +1,304 @@
+package at
+
+import (
+	"database/sql/driver"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// OpenAPISchema OpenAPI 3 Schema Object，只包括 model 用到的部分，可以直接 json.Marshal
+type OpenAPISchema struct {
+	Type        string                    `json:"type,omitempty"`
+	Format      string                    `json:"format,omitempty"`
+	Description string                    `json:"description,omitempty"`
+	Properties  map[string]*OpenAPISchema `json:"properties,omitempty"`
+	Required    []string                  `json:"required,omitempty"`
+	Items       *OpenAPISchema            `json:"items,omitempty"`
+	Enum        []interface{}             `json:"enum,omitempty"`
+	Default     interface{}               `json:"default,omitempty"`
+	Minimum     *float64                  `json:"minimum,omitempty"`
+	Maximum     *float64                  `json:"maximum,omitempty"`
+	MinLength   *int                      `json:"minLength,omitempty"`
+	MaxLength   *int                      `json:"maxLength,omitempty"`
+	MinItems    *int                      `json:"minItems,omitempty"`
+	MaxItems    *int                      `json:"maxItems,omitempty"`
+	Pattern     string                    `json:"pattern,omitempty"`
+	Nullable    bool                      `json:"nullable,omitempty"`
+	ReadOnly    bool                      `json:"readOnly,omitempty"`
+}
+
+// OpenAPIParameter OpenAPI 3 Parameter Object
+type OpenAPIParameter struct {
+	Name        string         `json:"name"`
+	In          string         `json:"in"`
+	Description string         `json:"description,omitempty"`
+	Required    bool           `json:"required,omitempty"`
+	Schema      *OpenAPISchema `json:"schema"`
+}
+
+// OpenAPIComponents 多个 model 的 components.schemas，key 为 model 类型名
+// modPointers ...interface{}	model 指针
+func OpenAPIComponents(modPointers ...interface{}) map[string]*OpenAPISchema {
+	schemas := make(map[string]*OpenAPISchema, len(modPointers))
+	for _, mod := range modPointers {
+		schemas[reflect.TypeOf(mod).Elem().Name()] = OpenAPISchemaOf(mod)
+	}
+	return schemas
+}
+
+// OpenAPISchemaOf model 的 Schema，属性名为 tag json：
+// 类型取 model 字段类型，VARCHAR(n)、CHAR(n) 等 type tag 为 maxLength，comment tag 为 description，
+// validate tag 的 required、max、min、enum、email、url、regexp 转为对应的约束；
+// 生成的主键、创建时间、最后更新、删除时间、租户字段为 readOnly
+// modPointer interface{}	model 指针
+func OpenAPISchemaOf(modPointer interface{}) *OpenAPISchema {
+	meta := getModelMeta(modPointer)
+	ty := reflect.TypeOf(modPointer).Elem()
+	schema := &OpenAPISchema{Type: "object", Properties: make(map[string]*OpenAPISchema, len(meta.listTableFields))}
+	for inx, f := range meta.listTableFields {
+		name, tf, _ := meta.fieldByTable(f)
+		sf, isOk := ty.FieldByName(name)
+		if !isOk || "-" == sf.Tag.Get("json") {
+			continue
+		}
+		if _, isBlindIndex := blindIndexSource(f, meta.mapModelTableField); isBlindIndex {
+			continue
+		}
+		s := openAPIFieldSchema(sf, tf)
+		s.ReadOnly = isReadOnlyField(meta, inx, tf)
+		if openAPIRequired(sf) && !s.ReadOnly {
+			schema.Required = append(schema.Required, fieldJSONName(sf))
+		}
+		schema.Properties[fieldJSONName(sf)] = s
+	}
+	return schema
+}
+
+// openAPIFieldSchema 字段的 Schema，包括 type tag、comment tag 与 validate tag
+func openAPIFieldSchema(sf reflect.StructField, tf TableField) *OpenAPISchema {
+	s := openAPITypeSchema(sf.Type)
+	if strings.Contains(sf.Tag.Get("json"), ",string") && ("integer" == s.Type || "number" == s.Type || "boolean" == s.Type) {
+		s.Type, s.Format = "string", ""
+	}
+	s.Description = sf.Tag.Get("comment")
+	if "string" == s.Type && "" == s.Format {
+		if n, isOk := openAPITypeLength(tf.FieldType); isOk {
+			s.MaxLength = &n
+		}
+	}
+	rules, err := parseValidateTag(sf.Tag.Get(validateTag))
+	if nil != err {
+		return s
+	}
+	for _, r := range rules {
+		switch r.name {
+		case RuleMax, RuleMin:
+			openAPIRange(s, r.name, r.num)
+		case RuleEnum:
+			s.Enum = nil
+			for _, e := range strings.Split(r.param, "|") {
+				s.Enum = append(s.Enum, openAPIEnumValue(s.Type, e))
+			}
+		case RuleEmail:
+			s.Format = "email"
+		case RuleURL:
+			s.Format = "uri"
+		case RuleRegexp:
+			s.Pattern = r.param
+		}
+	}
+	return s
+}
+
+var valuerType = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+
+// openAPITypeSchema Go 类型对应的 Schema，指针与 sql.Null* 为 nullable
+func openAPITypeSchema(ty reflect.Type) *OpenAPISchema {
+	nullable := false
+	for reflect.Ptr == ty.Kind() {
+		ty, nullable = ty.Elem(), true
+	}
+	if reflect.Struct == ty.Kind() && 2 == ty.NumField() && "Valid" == ty.Field(1).Name && ty.Implements(valuerType) {
+		s := openAPITypeSchema(ty.Field(0).Type)
+		s.Nullable = true
+		return s
+	}
+	s := &OpenAPISchema{Nullable: nullable}
+	switch {
+	case timeType == ty:
+		s.Type, s.Format = "string", "date-time"
+	case reflect.Bool == ty.Kind():
+		s.Type = "boolean"
+	case reflect.Int8 <= ty.Kind() && reflect.Int32 >= ty.Kind(), reflect.Uint8 <= ty.Kind() && reflect.Uint32 >= ty.Kind():
+		s.Type, s.Format = "integer", "int32"
+	case isIntKind(ty.Kind()), reflect.Uint == ty.Kind(), reflect.Uint64 == ty.Kind():
+		s.Type, s.Format = "integer", "int64"
+	case reflect.Float32 == ty.Kind():
+		s.Type, s.Format = "number", "float"
+	case reflect.Float64 == ty.Kind():
+		s.Type, s.Format = "number", "double"
+	case reflect.String == ty.Kind():
+		s.Type = "string"
+	case reflect.Slice == ty.Kind() && reflect.Uint8 == ty.Elem().Kind():
+		s.Type, s.Format = "string", "byte"
+	case reflect.Slice == ty.Kind(), reflect.Array == ty.Kind():
+		s.Type, s.Items = "array", openAPITypeSchema(ty.Elem())
+	default:
+		s.Type = "object"
+	}
+	return s
+}
+
+var typeLengthRegexp = regexp.MustCompile(`(?i)^\s*(VAR)?CHAR\s*\(\s*(\d+)\s*\)`)
+
+// openAPITypeLength type tag 中 VARCHAR(n)、CHAR(n) 的长度
+func openAPITypeLength(fieldType string) (int, bool) {
+	m := typeLengthRegexp.FindStringSubmatch(fieldType)
+	if nil == m {
+		return 0, false
+	}
+	n, err := strconv.Atoi(m[2])
+	return n, nil == err
+}
+
+// openAPIRange validate 的 max、min：字符串为长度，数组为元素数量，数字为取值范围
+func openAPIRange(s *OpenAPISchema, rule string, num float64) {
+	n := int(num)
+	switch s.Type {
+	case "string":
+		if RuleMax == rule {
+			s.MaxLength = &n
+		} else {
+			s.MinLength = &n
+		}
+	case "array":
+		if RuleMax == rule {
+			s.MaxItems = &n
+		} else {
+			s.MinItems = &n
+		}
+	case "integer", "number":
+		if RuleMax == rule {
+			s.Maximum = &num
+		} else {
+			s.Minimum = &num
+		}
+	}
+}
+
+// openAPIEnumValue enum 的值按 Schema 类型转换
+func openAPIEnumValue(schemaType, e string) interface{} {
+	switch schemaType {
+	case "integer":
+		if n, err := strconv.ParseInt(e, 10, 64); nil == err {
+			return n
+		}
+	case "number":
+		if n, err := strconv.ParseFloat(e, 64); nil == err {
+			return n
+		}
+	case "boolean":
+		if b, err := strconv.ParseBool(e); nil == err {
+			return b
+		}
+	}
+	return e
+}
+
+// openAPIRequired validate tag 是否有 required
+func openAPIRequired(sf reflect.StructField) bool {
+	rules, err := parseValidateTag(sf.Tag.Get(validateTag))
+	if nil != err {
+		return false
+	}
+	for _, r := range rules {
+		if RuleRequired == r.name {
+			return true
+		}
+	}
+	return false
+}
+
+// OpenAPIParameters 列表接口的查询参数，与 Bind 的规则一致：
+// 可查询字段的等值与 ! 不等参数，数字与时间字段的 ?>?、?>=、?<?、?<= 参数，数字字段的 ?in 参数
+// （thing 字段只有逗号分隔的等值与不等参数，加密字段只有等值与不等参数），
+// 以及 condPageIndex、condPageSize、condLimitBegin、condORDERField、condORDERType、condBeginTime、condEndTime、condCursor
+// modPointer interface{}	model 指针
+func (that *Binder) OpenAPIParameters(modPointer interface{}) []*OpenAPIParameter {
+	meta := getModelMeta(modPointer)
+	ty := reflect.TypeOf(modPointer).Elem()
+	params := make([]*OpenAPIParameter, 0)
+	query := func(name, description string, s *OpenAPISchema) {
+		params = append(params, &OpenAPIParameter{Name: name, In: "query", Description: description, Schema: s})
+	}
+	inSchema := &OpenAPISchema{Type: "string", Pattern: `^-?[0-9.]+(,-?[0-9.]+)*$`}
+
+	sortable := make([]string, 0)
+	for _, f := range meta.listTableFields {
+		name, tf, _ := meta.fieldByTable(f)
+		sf, isOk := ty.FieldByName(name)
+		if !isOk || !that.filterable(meta, tf) {
+			continue
+		}
+		jsonName := fieldJSONName(sf)
+		if that.sortable(meta, tf) {
+			sortable = append(sortable, jsonName)
+		}
+		s := openAPITypeSchema(sf.Type)
+		if "object" == s.Type || "array" == s.Type {
+			continue
+		}
+		s.Nullable = false
+		description := sf.Tag.Get("comment")
+		isNumber := "integer" == s.Type || "number" == s.Type
+		if PropertyThing == tf.FieldProperty && isNumber {
+			query(jsonName, description+"，多个值以逗号分隔", inSchema)
+			query(NOeq+jsonName, description+" 不等于，多个值以逗号分隔", inSchema)
+			continue
+		}
+		query(jsonName, description, s)
+		query(NOeq+jsonName, description+" !=", s)
+		if tf.FieldEncrypt {
+			continue
+		}
+		if isNumber || "date-time" == s.Format {
+			query(Gt+jsonName, description+" >", s)
+			query(GTeq+jsonName, description+" >=", s)
+			query(Lt+jsonName, description+" <", s)
+			query(LTeq+jsonName, description+" <=", s)
+		}
+		if isNumber {
+			query(In+jsonName, description+" IN，多个值以逗号分隔", inSchema)
+		}
+	}
+
+	maxPageSize := that.MaxPageSize
+	if 1 > maxPageSize {
+		maxPageSize = DefaultMaxPageSize
+	}
+	one, zero, maxSize := 1.0, 0.0, float64(maxPageSize)
+	timeSchema := &OpenAPISchema{Type: "string", Description: "unix 秒或 2006-01-02 15:04:05"}
+	query(CondPageIndex, "页码，从 1 开始", &OpenAPISchema{Type: "integer", Minimum: &one, Default: 1})
+	query(CondPageSize, "每页数量", &OpenAPISchema{Type: "integer", Minimum: &one, Maximum: &maxSize, Default: min(20, maxPageSize)})
+	query(CondLimitBegin, "起始条目，优先于 condPageIndex", &OpenAPISchema{Type: "integer", Minimum: &zero})
+	query(CondORDERField, "排序字段，多个以逗号分隔，可选："+strings.Join(sortable, ", "), &OpenAPISchema{Type: "string"})
+	query(CondORDERType, "排序类型，1 升序，2 降序", &OpenAPISchema{Type: "integer", Enum: []interface{}{CondORDERTypeAES, CondORDERTypeDESC}})
+	query(CondBeginTime, "创建时间开始", timeSchema)
+	query(CondEndTime, "创建时间结束", timeSchema)
+	query(CondCursor, "游标分页的游标，第一页为空", &OpenAPISchema{Type: "string"})
+	return params
+}
+
+// OpenAPIParameters 列表接口的查询参数，见 Binder.OpenAPIParameters，不包括 Handler 不支持的 condCursor
+func (that *Handler) OpenAPIParameters() []*OpenAPIParameter {
+	params := that.Binder.OpenAPIParameters(that.newModel())
+	list := make([]*OpenAPIParameter, 0, len(params))
+	for _, p := range params {
+		if CondCursor != p.Name {
+			list = append(list, p)
+		}
+	}
+	return list
+}
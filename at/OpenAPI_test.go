@@ -0,0 +1,18 @@
+package at
+
+import "testing"
+
+func TestOpenAPIParametersMatchBinder(t *testing.T) {
+	names := make(map[string]bool)
+	for _, p := range (&Binder{}).OpenAPIParameters(&testPerson{}) {
+		names[p.Name] = true
+	}
+	for _, name := range []string{"id", NOeq + "id", Gt + "id", GTeq + "id", Lt + "id", LTeq + "id", In + "id", "name", NOeq + "name", "phone", NOeq + "phone"} {
+		if !names[name] {
+			t.Errorf("missing parameter %s", name)
+		}
+	}
+	if names[Gt+"phone"] {
+		t.Error("encrypted field has a range parameter")
+	}
+}
@@ -0,0 +1,122 @@
+package at
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// 主键声明：字段加 pk tag 为主键，多个字段加 pk tag 为联合主键，顺序与字段顺序一致。
+// pk:"" 的主键由 model 赋值，插入语句包含主键列，适用于字符串、UUID 与联合主键；
+// pk:"auto" 为数据库自增主键，插入语句不包含主键列，AddModel 返回 LastInsertId。
+//
+//	Code    string `json:"code" table:"code" pk:""`
+//	Id      int64  `json:"id" table:"id" pk:"auto"`
+//	OrderId int64  `json:"orderId" table:"order_id" pk:""`
+//	SkuId   int64  `json:"skuId" table:"sku_id" pk:""`
+//
+// 没有 pk tag 时兼容旧的生成代码：主键为 GetPKTableField 返回的字段（默认第一个表字段），且为自增主键。
+const pkTag = "pk"
+
+// PKAuto pk tag 的值，数据库自增主键
+const PKAuto = "auto"
+
+// pkTableFields 有 pk tag 的表字段，按字段顺序，没有时为 nil
+func pkTableFields(fields []string, mapModelTableField map[string]TableField) []string {
+	var pks []string
+	for _, f := range fields {
+		for _, tf := range mapModelTableField {
+			if f == tf.FieldNameByTable && tf.FieldPK {
+				pks = append(pks, f)
+				break
+			}
+		}
+	}
+	return pks
+}
+
+// isPKTableField 表字段 v 是否是主键，没有 pk tag 时第一个表字段为主键
+func isPKTableField(inx int, v string, pks []string) bool {
+	if 0 == len(pks) {
+		return 0 == inx
+	}
+	for _, pk := range pks {
+		if v == pk {
+			return true
+		}
+	}
+	return false
+}
+
+// insertSkipField 插入语句是否跳过表字段 v：只跳过数据库自增的主键，主键由 idgen 生成时不跳过
+func insertSkipField(inx int, v string, pks []string, mapModelTableField map[string]TableField) bool {
+	if !isPKTableField(inx, v, pks) {
+		return false
+	}
+	for _, tf := range mapModelTableField {
+		if v != tf.FieldNameByTable {
+			continue
+		}
+		if "" != tf.FieldIDGen {
+			return false
+		}
+		return 0 == len(pks) || tf.FieldPKAuto
+	}
+	return false
+}
+
+// pkAutoIncrement 主键是否由数据库自增，自增时 AddModel 返回 LastInsertId
+func (that *modelMeta) pkAutoIncrement() bool {
+	if 1 != len(that.pkFields) {
+		return false
+	}
+	_, tf, _ := that.fieldByTable(that.pkFields[0])
+	if "" != tf.FieldIDGen {
+		return false
+	}
+	return !tf.FieldPK || tf.FieldPKAuto
+}
+
+// pkWhere 主键条件 alias.pk1 = ? AND alias.pk2 = ?，参数为 pkValues
+func (that *modelMeta) pkWhere(alias string) string {
+	conds := make([]string, len(that.pkFields))
+	for i, f := range that.pkFields {
+		if "" != alias {
+			f = fmt.Sprintf("%s.%s", alias, f)
+		}
+		conds[i] = fmt.Sprintf("%s = ?", f)
+	}
+	return strings.Join(conds, " AND ")
+}
+
+// pkValues 主键值，顺序与 pkFields 一致
+// val reflect.Value	model 结构体（非指针）
+func (that *modelMeta) pkValues(val reflect.Value) []interface{} {
+	values := make([]interface{}, len(that.pkFields))
+	for i, f := range that.pkFields {
+		name, _, _ := that.fieldByTable(f)
+		values[i] = val.FieldByName(name).Interface()
+	}
+	return values
+}
+
+// pkKey 主键值拼成的字符串，联合主键以 , 分隔，用于 MemoryStorage 与审计记录
+func (that *modelMeta) pkKey(val reflect.Value) string {
+	values := that.pkValues(val)
+	keys := make([]string, len(values))
+	for i, v := range values {
+		keys[i] = fmt.Sprint(auditNormalize(v))
+	}
+	return strings.Join(keys, ",")
+}
+
+// modelInsertID 主键不是数据库自增时 AddModel 的返回值：整型主键为主键值，其它类型为 1
+func modelInsertID(meta *modelMeta, val reflect.Value) int64 {
+	if 1 == len(meta.pkFields) {
+		pk := val.FieldByName(meta.pkModelField())
+		if isIntKind(pk.Kind()) {
+			return reflectInt(pk)
+		}
+	}
+	return 1
+}
@@ -0,0 +1,114 @@
+package at
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// lastExec 最后执行的语句与参数
+func lastExec(f *fakeDB) (string, []driver.Value) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.execs[len(f.execs)-1], f.args[len(f.args)-1]
+}
+
+func TestCompositePK(t *testing.T) {
+	f, db := newFakeDB()
+	defer db.Close()
+	dao := GetInstanceByBaseDao()
+	ctx := context.Background()
+	mod := &testOrderSku{OrderId: 7, SkuId: 9, Qty: 3}
+
+	err := dao.Transaction(db, func(tx *sql.Tx) error {
+		id, err := dao.AddModelContext(ctx, tx, mod)
+		if nil != err {
+			return err
+		}
+		if 1 != id {
+			t.Errorf("AddModel = %d, want 1 for a composite key", id)
+		}
+		s, args := lastExec(f)
+		if !strings.Contains(s, "order_id") || !strings.Contains(s, "sku_id") {
+			t.Errorf("insert %q leaves out a key column", s)
+		}
+		if !reflect.DeepEqual([]driver.Value{int64(7), int64(9), int64(3)}, args) {
+			t.Errorf("insert args = %v", args)
+		}
+
+		if _, err = dao.UpdateByIDContext(ctx, tx, mod); nil != err {
+			return err
+		}
+		s, args = lastExec(f)
+		if !strings.Contains(s, "WHERE os.order_id = ? AND os.sku_id = ?") {
+			t.Errorf("update %q", s)
+		}
+		if n := len(args); 2 > n || int64(7) != args[n-2] || int64(9) != args[n-1] {
+			t.Errorf("update args = %v, want the key values last in field order", args)
+		}
+
+		if _, err = dao.DeleteByIDContext(ctx, tx, mod); nil != err {
+			return err
+		}
+		s, args = lastExec(f)
+		if !strings.Contains(s, "WHERE order_id = ? AND sku_id = ?") {
+			t.Errorf("delete %q", s)
+		}
+		if !reflect.DeepEqual([]driver.Value{int64(7), int64(9)}, args) {
+			t.Errorf("delete args = %v", args)
+		}
+		return nil
+	})
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	if _, err = dao.FindByIDContext(ctx, db, &testOrderSku{OrderId: 7, SkuId: 9}); nil != err {
+		t.Fatal(err)
+	}
+	s, args := lastExec(f)
+	if !strings.Contains(s, "WHERE os.order_id = ? AND os.sku_id = ? LIMIT 1") {
+		t.Errorf("find %q", s)
+	}
+	if !reflect.DeepEqual([]driver.Value{int64(7), int64(9)}, args) {
+		t.Errorf("find args = %v", args)
+	}
+	if "7,9" != getModelMeta(mod).pkKey(reflect.ValueOf(mod).Elem()) {
+		t.Errorf("pkKey = %q", getModelMeta(mod).pkKey(reflect.ValueOf(mod).Elem()))
+	}
+}
+
+func TestPKDefaultsToFirstField(t *testing.T) {
+	meta := getModelMeta(&testItem{})
+	if !reflect.DeepEqual([]string{"id"}, meta.pkFields) {
+		t.Fatalf("pkFields = %v, want the first field", meta.pkFields)
+	}
+	if !meta.pkAutoIncrement() {
+		t.Error("a key without a pk tag should be auto increment")
+	}
+	if "i.id = ?" != meta.pkWhere("i") {
+		t.Errorf("pkWhere = %q", meta.pkWhere("i"))
+	}
+	if !reflect.DeepEqual([]interface{}{int64(5)}, meta.pkValues(reflect.ValueOf(testItem{Id: 5}))) {
+		t.Errorf("pkValues = %v", meta.pkValues(reflect.ValueOf(testItem{Id: 5})))
+	}
+
+	f, db := newFakeDB()
+	defer db.Close()
+	err := GetInstanceByBaseDao().Transaction(db, func(tx *sql.Tx) error {
+		id, err := GetInstanceByBaseDao().AddModel(tx, &testItem{Title: "a"})
+		if 1 != id {
+			t.Errorf("AddModel = %d, want LastInsertId", id)
+		}
+		return err
+	})
+	if nil != err {
+		t.Fatal(err)
+	}
+	if s, _ := lastExec(f); strings.Contains(s, "id,") || strings.Contains(s, "(id") {
+		t.Errorf("insert %q includes the auto increment key", s)
+	}
+}
@@ -0,0 +1,247 @@
+package at
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Cacheable 需要查询缓存的 model 实现此接口，CacheTTL 返回 > 0 时
+// FindByID、FindList、Count 先读缓存，未命中时查询数据库并写入缓存。
+// 缓存 key 为 表名 + 操作 + 条件（或主键）+ 租户，AddModel、AddModelBatch、UpdateByID、DeleteByID、Upsert 成功后该表的缓存全部失效，
+// 在 TransactionContext 开启的事务中时提交成功后才失效（见 AfterCommit），回滚不失效；
+// 使用自己开启的 *sql.Tx 时写入后立即失效，提交后需再调用 InvalidateCache，否则提交前读到的旧数据会被缓存。
+// Update、UpdateMustAffected 等手写 SQL 需调用 InvalidateCache。
+// 在事务中（q 为 *sql.Tx）查询与使用 WithoutCache 时不使用缓存。
+type Cacheable interface {
+	CacheTTL() time.Duration
+}
+
+// CacheStore 查询缓存的存储，值为 gob 编码的查询结果，可以实现为 Redis 等共享存储
+type CacheStore interface {
+	Get(key string) ([]byte, bool)
+	// Set ttl 为 0 时不过期
+	Set(key string, value []byte, ttl time.Duration)
+}
+
+var cacheStore CacheStore
+
+// SetCacheStore 设置查询缓存的存储，nil 为关闭
+func SetCacheStore(store CacheStore) {
+	cacheStore = store
+}
+
+type cacheBypassKey struct{}
+
+// WithoutCache 本次查询不读取也不写入缓存
+func WithoutCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, cacheBypassKey{}, true)
+}
+
+const cacheKeyPrefix = "pmc:at:"
+
+// cacheVersion 表的缓存版本，写入时更换版本使旧缓存全部失效。
+// 版本不存在（如被 LRU 淘汰）时生成新版本，旧版本的缓存不会再被读取。
+func cacheVersion(table string) string {
+	key := fmt.Sprintf("%sv:%s", cacheKeyPrefix, table)
+	if v, isOk := cacheStore.Get(key); isOk {
+		return string(v)
+	}
+	return renewCacheVersion(table)
+}
+
+func renewCacheVersion(table string) string {
+	v := strconv.FormatInt(time.Now().UnixNano(), 36)
+	cacheStore.Set(fmt.Sprintf("%sv:%s", cacheKeyPrefix, table), []byte(v), 0)
+	return v
+}
+
+// InvalidateCache 使表的查询缓存全部失效，在事务中写入时应在提交后调用
+// table string	GetTableName 返回的表名
+func InvalidateCache(table string) {
+	if nil == cacheStore {
+		return
+	}
+	renewCacheVersion(table)
+}
+
+// invalidateModelCache 写入成功后使 model 所在表的缓存失效，在事务中时提交后失效
+func invalidateModelCache(tx *sql.Tx, modPointer interface{}) {
+	if nil == cacheStore {
+		return
+	}
+	if _, isOk := modPointer.(Cacheable); !isOk {
+		return
+	}
+	tableName := getModelMeta(modPointer).tableName
+	AfterCommit(tx, func() {
+		InvalidateCache(tableName)
+	})
+}
+
+// queryCacheKey 查询缓存的 key 与 ttl，isOk 为 false 时不使用缓存
+// table string	逻辑表名
+// op string	操作，如 OpFindList
+// args interface{}	条件 map 或主键值
+func queryCacheKey(ctx context.Context, q Querier, modPointer interface{}, table, op string, args interface{}) (key string, ttl time.Duration, isOk bool) {
+	if nil == cacheStore {
+		return "", 0, false
+	}
+	if _, isTx := q.(*sql.Tx); isTx {
+		return "", 0, false
+	}
+	if bypass, _ := ctx.Value(cacheBypassKey{}).(bool); bypass {
+		return "", 0, false
+	}
+	c, isCacheable := modPointer.(Cacheable)
+	if !isCacheable || 0 >= c.CacheTTL() {
+		return "", 0, false
+	}
+
+	h := sha256.New()
+	h.Write([]byte(op))
+	if condition, isMap := args.(map[string]interface{}); isMap {
+		// 条件按 key 排序，值带类型，保证相同条件得到相同 key
+		keys := make([]string, 0, len(condition))
+		for k := range condition {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(h, "|%s=%T:%v", k, condition[k], condition[k])
+		}
+	} else {
+		fmt.Fprintf(h, "|%T:%v", args, args)
+	}
+	tenantID, _ := TenantFromContext(ctx)
+	bypassTenant, _ := ctx.Value(tenantBypassKey{}).(bool)
+	fmt.Fprintf(h, "|tenant=%T:%v:%v", tenantID, tenantID, bypassTenant)
+
+	key = fmt.Sprintf("%s%s:%s:%s", cacheKeyPrefix, table, cacheVersion(table), hex.EncodeToString(h.Sum(nil)))
+	return key, c.CacheTTL(), true
+}
+
+// cacheGet 读取缓存并解码到 dest（指针），未命中或解码失败返回 false。
+// gob 不编码零值字段，先解码到新值再整体赋值，避免 dest 原有的值残留
+func cacheGet(key string, dest interface{}) bool {
+	b, isOk := cacheStore.Get(key)
+	if !isOk {
+		return false
+	}
+	destVal := reflect.ValueOf(dest).Elem()
+	fresh := reflect.New(destVal.Type())
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(fresh.Interface()); nil != err {
+		return false
+	}
+	destVal.Set(fresh.Elem())
+	return true
+}
+
+// cacheSet 编码后写入缓存，编码失败时不缓存
+func cacheSet(key string, value interface{}, ttl time.Duration) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(value); nil != err {
+		return
+	}
+	cacheStore.Set(key, buf.Bytes(), ttl)
+}
+
+// LRUCacheStore 进程内的 CacheStore，超出容量时淘汰最久未使用的缓存
+type LRUCacheStore struct {
+	capacity int
+	lock     sync.Mutex
+	ll       *list.List
+	items    map[string]*list.Element
+}
+
+type lruCacheEntry struct {
+	key      string
+	value    []byte
+	expireAt time.Time
+}
+
+// NewLRUCacheStore 创建进程内缓存
+// capacity int	最多缓存的数量，< 1 时为 10000
+func NewLRUCacheStore(capacity int) *LRUCacheStore {
+	if 1 > capacity {
+		capacity = 10000
+	}
+	return &LRUCacheStore{
+		capacity: capacity,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+func (that *LRUCacheStore) Get(key string) ([]byte, bool) {
+	that.lock.Lock()
+	defer that.lock.Unlock()
+	e, isOk := that.items[key]
+	if !isOk {
+		return nil, false
+	}
+	entry := e.Value.(*lruCacheEntry)
+	if !entry.expireAt.IsZero() && time.Now().After(entry.expireAt) {
+		that.ll.Remove(e)
+		delete(that.items, key)
+		return nil, false
+	}
+	that.ll.MoveToFront(e)
+	return entry.value, true
+}
+
+func (that *LRUCacheStore) Set(key string, value []byte, ttl time.Duration) {
+	that.lock.Lock()
+	defer that.lock.Unlock()
+	var expireAt time.Time
+	if 0 < ttl {
+		expireAt = time.Now().Add(ttl)
+	}
+	if e, isOk := that.items[key]; isOk {
+		entry := e.Value.(*lruCacheEntry)
+		entry.value = value
+		entry.expireAt = expireAt
+		that.ll.MoveToFront(e)
+		return
+	}
+	that.items[key] = that.ll.PushFront(&lruCacheEntry{key: key, value: value, expireAt: expireAt})
+	for that.ll.Len() > that.capacity {
+		oldest := that.ll.Back()
+		that.ll.Remove(oldest)
+		delete(that.items, oldest.Value.(*lruCacheEntry).key)
+	}
+}
+
+func (that *LRUCacheStore) Delete(key string) {
+	that.lock.Lock()
+	defer that.lock.Unlock()
+	if e, isOk := that.items[key]; isOk {
+		that.ll.Remove(e)
+		delete(that.items, key)
+	}
+}
+
+// Len 当前缓存数量，包括已过期未清理的
+func (that *LRUCacheStore) Len() int {
+	that.lock.Lock()
+	defer that.lock.Unlock()
+	return that.ll.Len()
+}
+
+// Reset 清空缓存
+func (that *LRUCacheStore) Reset() {
+	that.lock.Lock()
+	defer that.lock.Unlock()
+	that.ll.Init()
+	that.items = make(map[string]*list.Element)
+}
@@ -0,0 +1,48 @@
+package at
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+)
+
+func TestCacheInvalidatedAfterCommit(t *testing.T) {
+	SetCacheStore(NewLRUCacheStore(0))
+	defer SetCacheStore(nil)
+	_, db := newFakeDB()
+	defer db.Close()
+	dao := GetInstanceByBaseDao()
+
+	before := cacheVersion("item")
+	err := dao.TransactionContext(context.Background(), db, nil, func(tx *sql.Tx) error {
+		if _, err := dao.AddModelContext(context.Background(), tx, &testItem{Title: "a"}); nil != err {
+			return err
+		}
+		if v := cacheVersion("item"); v != before {
+			t.Error("cache invalidated before commit")
+		}
+		return nil
+	})
+	if nil != err {
+		t.Fatal(err)
+	}
+	committed := cacheVersion("item")
+	if committed == before {
+		t.Fatal("cache not invalidated after commit")
+	}
+
+	errRollback := errors.New("rollback")
+	err = dao.TransactionContext(context.Background(), db, nil, func(tx *sql.Tx) error {
+		if _, err := dao.AddModelContext(context.Background(), tx, &testItem{Title: "b"}); nil != err {
+			return err
+		}
+		return errRollback
+	})
+	if !errors.Is(err, errRollback) {
+		t.Fatal(err)
+	}
+	if v := cacheVersion("item"); v != committed {
+		t.Error("cache invalidated by a rolled back transaction")
+	}
+}
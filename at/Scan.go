@@ -0,0 +1,219 @@
+package at
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+var scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+
+// 按 model 类型缓存 表字段（小写）-> 扫描字段
+var scanIndexCache sync.Map
+
+// scanField 扫描目标字段的下标路径、codec、是否加密，以及时间字段的精度与时区
+type scanField struct {
+	index   []int
+	codec   string
+	encrypt bool
+	time    TableField
+}
+
+func newScanField(t reflect.StructField) scanField {
+	_, encrypt := t.Tag.Lookup(encryptTag)
+	return scanField{index: t.Index, codec: t.Tag.Get("codec"), encrypt: encrypt, time: TableField{
+		FieldNameByTable: t.Tag.Get("table"),
+		FieldPrecision:   normalizePrecision(t.Tag.Get(precisionTag)),
+		FieldTZ:          t.Tag.Get(tzTag),
+	}}
+}
+
+// isEmbeddedStruct 是否为需要展开的匿名嵌入结构体，time.Time、实现 sql.Scanner 的类型以及有 table tag 的字段不展开。
+// 只支持值嵌入，指针嵌入为 nil 时无法取字段，不展开。
+func isEmbeddedStruct(t reflect.StructField) bool {
+	if !t.Anonymous || reflect.Struct != t.Type.Kind() || "" != t.Tag.Get("table") {
+		return false
+	}
+	return timeType != t.Type && !reflect.PtrTo(t.Type).Implements(scannerType)
+}
+
+// modelTableStructFields 有 table tag 的字段，匿名嵌入结构体的字段展开在嵌入位置，Index 为从 model 开始的完整下标路径
+func modelTableStructFields(ty reflect.Type) []reflect.StructField {
+	fields := make([]reflect.StructField, 0)
+	for i := 0; i < ty.NumField(); i++ {
+		t := ty.Field(i)
+		if isEmbeddedStruct(t) {
+			for _, sub := range modelTableStructFields(t.Type) {
+				sub.Index = append([]int{i}, sub.Index...)
+				fields = append(fields, sub)
+			}
+			continue
+		}
+		if "" != t.Tag.Get("table") {
+			fields = append(fields, t)
+		}
+	}
+	return fields
+}
+
+// modelColumnIndex 表字段（小写）到扫描字段的映射
+func modelColumnIndex(ty reflect.Type) map[string]scanField {
+	if v, isOk := scanIndexCache.Load(ty); isOk {
+		return v.(map[string]scanField)
+	}
+	fields := modelTableStructFields(ty)
+	mapIndex := make(map[string]scanField, len(fields))
+	for _, t := range fields {
+		mapIndex[strings.ToLower(t.Tag.Get("table"))] = newScanField(t)
+	}
+	scanIndexCache.Store(ty, mapIndex)
+	return mapIndex
+}
+
+// columnIndexes 按查询结果的列名找到 model 字段，列名可带表别名，不区分大小写，找不到的列为 nil
+func columnIndexes(ty reflect.Type, columns []string) []scanField {
+	mapIndex := modelColumnIndex(ty)
+	indexes := make([]scanField, len(columns))
+	for i, c := range columns {
+		if inx := strings.LastIndex(c, "."); -1 != inx {
+			c = c[inx+1:]
+		}
+		indexes[i] = mapIndex[strings.ToLower(c)]
+	}
+	return indexes
+}
+
+// setScanTargets 按下标路径将 model 字段地址装入 values，找不到字段的列丢弃
+func setScanTargets(values []interface{}, elem reflect.Value, indexes []scanField) {
+	for i, f := range indexes {
+		if nil == f.index {
+			values[i] = new(interface{})
+			continue
+		}
+		values[i] = scanTarget(elem.FieldByIndex(f.index), f)
+	}
+}
+
+// scanTarget 字段的扫描目标：加密字段先解密，有 codec 的字段由 codec 解码，time.Time 与 *time.Time 使用 timeScanner，
+// 其它字段（包括指针、sql.Null*、实现 sql.Scanner 的类型）直接使用字段地址，由 database/sql 转换
+func scanTarget(field reflect.Value, f scanField) interface{} {
+	if f.encrypt {
+		return &decryptScanner{codec: f.codec, dest: field.Addr().Interface()}
+	}
+	if "" != f.codec {
+		return &codecScanner{name: f.codec, dest: field.Addr().Interface()}
+	}
+	if timeType == field.Type() || reflect.PtrTo(timeType) == field.Type() {
+		return &timeScanner{dest: field, field: f.time}
+	}
+	return field.Addr().Interface()
+}
+
+// SetModelInstanceToListAddrByColumns	按列名将 Model 字段地址存入切片，用于 rows.Scan
+// values []interface{}	用于装指针的切片，长度与 columns 相同
+// columns []string	rows.Columns() 得到的列名，按 table tag 匹配，model 中没有的列被丢弃
+// toPointer interface{}	Model指针
+func (*BaseModel) SetModelInstanceToListAddrByColumns(values []interface{}, columns []string, toPointer interface{}) {
+	elem := reflect.ValueOf(toPointer).Elem()
+	setScanTargets(values, elem, columnIndexes(elem.Type(), columns))
+}
+
+// ScanRows 按列名将 rows 的每一行装入新的 model 并追加到 listPointer，不关闭 rows。
+// 用于手写 SQL 的查询，列名按 table tag 匹配，NULL 需使用指针或 sql.Null* 字段接收。
+// rows *sql.Rows	查询结果
+// listPointer interface{}	结果切片的指针，如 *[]*User
+// error	err 不为 nil 时失败
+func ScanRows(rows *sql.Rows, listPointer interface{}) error {
+	listVal := reflect.ValueOf(listPointer).Elem()
+	modType := listVal.Type().Elem().Elem()
+	columns, err := rows.Columns()
+	if nil != err {
+		return err
+	}
+	indexes := columnIndexes(modType, columns)
+	values := make([]interface{}, len(columns))
+	for rows.Next() {
+		m := reflect.New(modType)
+		setScanTargets(values, m.Elem(), indexes)
+		if err = rows.Scan(values...); nil != err {
+			return err
+		}
+		listVal.Set(reflect.Append(listVal, m))
+	}
+	return rows.Err()
+}
+
+// timeScanner 将数据库返回的时间装入 time.Time 或 *time.Time 字段，按字段的 precision、tz tag 解析（见 Clock.go）。
+// 兼容驱动未解析时间（如 MySQL 未开启 parseTime）返回的字符串，以及 int 类型的时间戳，NULL 为零值或 nil。
+type timeScanner struct {
+	dest  reflect.Value
+	field TableField
+}
+
+func (that *timeScanner) Scan(src interface{}) error {
+	if nil == src {
+		that.dest.Set(reflect.Zero(that.dest.Type()))
+		return nil
+	}
+	tm, err := parseTimeValue(src, that.field)
+	if nil != err {
+		return err
+	}
+	if reflect.Ptr == that.dest.Kind() {
+		that.dest.Set(reflect.ValueOf(&tm))
+	} else {
+		that.dest.Set(reflect.ValueOf(tm))
+	}
+	return nil
+}
+
+// 字符串时间支持的格式
+var timeLayouts = []string{
+	"2006-01-02 15:04:05.999999999",
+	time.RFC3339Nano,
+	"2006-01-02",
+}
+
+// parseTimeValue 按字段的精度解析 unix 时间戳，按字段的时区解析时间字符串；
+// 有 tz 时驱动返回的 time.Time 按该时区的墙上时间解释，与写入的时间字符串一致
+func parseTimeValue(src interface{}, tf TableField) (time.Time, error) {
+	loc, err := timestampLocation(tf)
+	if nil != err {
+		return time.Time{}, err
+	}
+	switch v := src.(type) {
+	case time.Time:
+		if "" == tf.FieldTZ || v.IsZero() {
+			return v, nil
+		}
+		return time.Date(v.Year(), v.Month(), v.Day(), v.Hour(), v.Minute(), v.Second(), v.Nanosecond(), loc), nil
+	case int64:
+		return timestampFromUnix(tf, v).In(loc), nil
+	case []byte:
+		return parseTimeStringIn(string(v), loc)
+	case string:
+		return parseTimeStringIn(v, loc)
+	}
+	return time.Time{}, errors.New(fmt.Sprintf("error:unsupported scan, storing %T into time.Time", src))
+}
+
+func parseTimeString(s string) (time.Time, error) {
+	return parseTimeStringIn(s, time.Local)
+}
+
+func parseTimeStringIn(s string, loc *time.Location) (time.Time, error) {
+	if "" == s || strings.HasPrefix(s, "0000-00-00") {
+		return time.Time{}, nil
+	}
+	for _, layout := range timeLayouts {
+		if tm, err := time.ParseInLocation(layout, s, loc); nil == err {
+			return tm, nil
+		}
+	}
+	return time.Time{}, errors.New(fmt.Sprintf("error:cannot parse %q as time", s))
+}
@@ -0,0 +1,124 @@
+package at
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type scanAudit struct {
+	CreatedBy string `table:"created_by"`
+}
+
+// scanRow 测试 ScanRows 的 model，包含嵌入结构体、sql.Null*、指针与不同来源的时间
+type scanRow struct {
+	scanAudit
+	Id       int64          `table:"id"`
+	Name     sql.NullString `table:"name"`
+	Score    sql.NullInt64  `table:"score"`
+	Nick     *string        `table:"nick"`
+	Age      *int64         `table:"age"`
+	Born     time.Time      `table:"born" tz:"Asia/Shanghai"`
+	Seen     *time.Time     `table:"seen"`
+	LoggedAt time.Time      `table:"logged_at" precision:"ms" tz:"Asia/Shanghai"`
+	Missing  string         `table:"missing"`
+}
+
+func scanFake(t *testing.T, columns []string, values [][]driver.Value) []*scanRow {
+	t.Helper()
+	f, db := newFakeDB()
+	defer db.Close()
+	f.rows = func(string) ([]string, [][]driver.Value) {
+		return columns, values
+	}
+	rows, err := db.Query("SELECT")
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	list := make([]*scanRow, 0)
+	if err = ScanRows(rows, &list); nil != err {
+		t.Fatal(err)
+	}
+	return list
+}
+
+func TestScanRows(t *testing.T) {
+	loc, _ := time.LoadLocation("Asia/Shanghai")
+	columns := []string{"r.id", "CREATED_BY", "name", "score", "nick", "age", "born", "seen", "logged_at", "extra"}
+	list := scanFake(t, columns, [][]driver.Value{
+		{int64(1), "admin", "tom", int64(90), "t", int64(18), []byte("2024-01-02 03:04:05"), "2024-05-06T07:08:09Z", int64(1704135845000), "dropped"},
+		{int64(2), "", nil, nil, nil, nil, "2024-01-02", nil, nil, nil},
+	})
+	if 2 != len(list) {
+		t.Fatalf("len = %d", len(list))
+	}
+
+	r := list[0]
+	if 1 != r.Id || "admin" != r.CreatedBy {
+		t.Errorf("id = %d createdBy = %q, want column by alias and embedded field", r.Id, r.CreatedBy)
+	}
+	if !r.Name.Valid || "tom" != r.Name.String || !r.Score.Valid || 90 != r.Score.Int64 {
+		t.Errorf("name = %v score = %v", r.Name, r.Score)
+	}
+	if nil == r.Nick || "t" != *r.Nick || nil == r.Age || 18 != *r.Age {
+		t.Errorf("nick = %v age = %v", r.Nick, r.Age)
+	}
+	if want := time.Date(2024, 1, 2, 3, 4, 5, 0, loc); !r.Born.Equal(want) {
+		t.Errorf("born from []byte = %v, want %v", r.Born, want)
+	}
+	if want := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC); nil == r.Seen || !r.Seen.Equal(want) {
+		t.Errorf("seen from string = %v, want %v", r.Seen, want)
+	}
+	if want := time.UnixMilli(1704135845000); !r.LoggedAt.Equal(want) || "Asia/Shanghai" != r.LoggedAt.Location().String() {
+		t.Errorf("loggedAt from int64 = %v, want %v in Asia/Shanghai", r.LoggedAt, want)
+	}
+	if "" != r.Missing {
+		t.Errorf("missing = %q, want zero value", r.Missing)
+	}
+
+	r = list[1]
+	if r.Name.Valid || r.Score.Valid {
+		t.Errorf("NULL into sql.Null*: name = %v score = %v", r.Name, r.Score)
+	}
+	if nil != r.Nick || nil != r.Age || nil != r.Seen {
+		t.Errorf("NULL into pointers: nick = %v age = %v seen = %v", r.Nick, r.Age, r.Seen)
+	}
+	if !r.LoggedAt.IsZero() {
+		t.Errorf("NULL into time.Time = %v, want zero", r.LoggedAt)
+	}
+	if want := time.Date(2024, 1, 2, 0, 0, 0, 0, loc); !r.Born.Equal(want) {
+		t.Errorf("born from date string = %v, want %v", r.Born, want)
+	}
+}
+
+func TestScanRowsTimeError(t *testing.T) {
+	f, db := newFakeDB()
+	defer db.Close()
+	f.rows = func(string) ([]string, [][]driver.Value) {
+		return []string{"born"}, [][]driver.Value{{"not a time"}}
+	}
+	rows, err := db.Query("SELECT")
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	list := make([]*scanRow, 0)
+	if err = ScanRows(rows, &list); nil == err {
+		t.Fatal("unparsable time scanned without error")
+	}
+}
+
+func TestModelTableStructFieldsEmbedded(t *testing.T) {
+	fields := modelTableStructFields(reflect.TypeOf(scanRow{}))
+	if "created_by" != fields[0].Tag.Get("table") || 2 != len(fields[0].Index) {
+		t.Fatalf("first field = %s %v, want embedded created_by with full index", fields[0].Tag.Get("table"), fields[0].Index)
+	}
+	for _, f := range fields {
+		if "" == f.Tag.Get("table") {
+			t.Errorf("field %s without table tag", f.Name)
+		}
+	}
+}
@@ -0,0 +1,310 @@
+package at
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ShardStrategy 分表策略，按 model 或查询条件将 GetTableName 返回的逻辑表解析为物理表。
+// 通过 RegisterShard 为逻辑表注册后，标准方法自动使用物理表：
+// AddModel、UpdateByID、DeleteByID、FindByID 使用 Table；FindList、Count 使用 Tables，
+// 多个物理表时分别查询后合并排序与分页，每个物理表查询 offset+size 条，不适合深分页。
+// 游标分页与遍历只支持解析为一个物理表的条件。
+type ShardStrategy interface {
+	// Table model 所在的物理表，model 的分片键需有值
+	Table(logicalTable string, modPointer interface{}) (string, error)
+	// Tables 满足 condition 的数据可能所在的物理表，modPointer 用于解析条件中的字段
+	Tables(logicalTable string, modPointer interface{}, condition map[string]interface{}) ([]string, error)
+}
+
+// ErrShardSpan 游标分页、遍历或批量插入涉及多个物理表
+var ErrShardSpan = errors.New("error:query spans multiple shards")
+
+var shards = make(map[string]ShardStrategy)
+var shardsLock sync.RWMutex
+
+// RegisterShard 为逻辑表注册分表策略
+// logicalTable string	GetTableName 返回的表名
+// strategy ShardStrategy	分表策略
+func RegisterShard(logicalTable string, strategy ShardStrategy) {
+	shardsLock.Lock()
+	defer shardsLock.Unlock()
+	shards[logicalTable] = strategy
+}
+
+func getShard(logicalTable string) (ShardStrategy, bool) {
+	shardsLock.RLock()
+	defer shardsLock.RUnlock()
+	s, isOk := shards[logicalTable]
+	return s, isOk
+}
+
+// shardTable 写入与按主键操作时的物理表，逻辑表没有分表时原样返回
+func shardTable(logicalTable string, modPointer interface{}) (string, error) {
+	s, isOk := getShard(logicalTable)
+	if !isOk {
+		return logicalTable, nil
+	}
+	return s.Table(logicalTable, modPointer)
+}
+
+// shardMetas 查询涉及的物理表，每个物理表一个 modelMeta 副本
+func shardMetas(meta *modelMeta, modPointer interface{}, condition map[string]interface{}) ([]*modelMeta, error) {
+	s, isOk := getShard(meta.tableName)
+	if !isOk {
+		return []*modelMeta{meta}, nil
+	}
+	tables, err := s.Tables(meta.tableName, modPointer, condition)
+	if nil != err {
+		return nil, err
+	}
+	metas := make([]*modelMeta, 0, len(tables))
+	for _, t := range tables {
+		m := *meta
+		m.tableName = t
+		metas = append(metas, &m)
+	}
+	return metas, nil
+}
+
+// singleShardMeta 查询只能涉及一个物理表时使用，逻辑表没有分表时原样返回
+func singleShardMeta(meta *modelMeta, modPointer interface{}, condition map[string]interface{}) (*modelMeta, error) {
+	metas, err := shardMetas(meta, modPointer, condition)
+	if nil != err {
+		return nil, err
+	}
+	if 1 != len(metas) {
+		return nil, ErrShardSpan
+	}
+	return metas[0], nil
+}
+
+// shardFieldValue 取得 model 中表字段 tableField 的值
+func shardFieldValue(modPointer interface{}, tableField string) (interface{}, error) {
+	meta := getModelMeta(modPointer)
+	name, _, isOk := meta.fieldByTable(tableField)
+	if !isOk {
+		return nil, errors.New(fmt.Sprintf("error:shard field %s not in %s", tableField, meta.tableName))
+	}
+	return reflect.ValueOf(modPointer).Elem().FieldByName(name).Interface(), nil
+}
+
+// shardConditionValue condition 中分片键的等于条件，条件名支持 model 字段名、tag json、tag table
+func shardConditionValue(modPointer interface{}, tableField string, condition map[string]interface{}) (interface{}, bool) {
+	meta := getModelMeta(modPointer)
+	_, tf, isOk := meta.fieldByTable(tableField)
+	if !isOk {
+		return nil, false
+	}
+	for k, v := range condition {
+		if "" != meta.alias && strings.HasPrefix(k, meta.alias+".") {
+			k = k[len(meta.alias)+1:]
+		}
+		if k == tf.FieldNameByModel || k == tf.FieldNameByTable || k == tf.FieldNameByJSON {
+			if nil != v && "" != v {
+				return v, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// HashModShard 按分片键取模分表，物理表为 逻辑表_序号，如 order_log_0 ~ order_log_15。
+// 整数分片键直接取模，其它类型取 FNV-32a 哈希后取模。
+// 查询条件中有分片键的等于条件时只查询一个物理表，否则查询全部物理表。
+type HashModShard struct {
+	// Field 分片键的表字段
+	Field string
+	// Count 物理表数量
+	Count int
+}
+
+func (that *HashModShard) table(logicalTable string, v interface{}) string {
+	v = derefValue(v)
+	var n uint64
+	rv := reflect.ValueOf(v)
+	switch {
+	case nil == v:
+		n = 0
+	case reflect.Int <= rv.Kind() && reflect.Int64 >= rv.Kind():
+		i := rv.Int()
+		if 0 > i {
+			i = -i
+		}
+		n = uint64(i)
+	case reflect.Uint <= rv.Kind() && reflect.Uint64 >= rv.Kind():
+		n = rv.Uint()
+	default:
+		s := fmt.Sprint(v)
+		if i, err := strconv.ParseInt(s, 10, 64); nil == err && 0 <= i {
+			// 条件中的数字字符串与整数分片键一致
+			n = uint64(i)
+		} else {
+			h := fnv.New32a()
+			h.Write([]byte(s))
+			n = uint64(h.Sum32())
+		}
+	}
+	return fmt.Sprintf("%s_%d", logicalTable, n%uint64(that.Count))
+}
+
+func (that *HashModShard) Table(logicalTable string, modPointer interface{}) (string, error) {
+	v, err := shardFieldValue(modPointer, that.Field)
+	if nil != err {
+		return "", err
+	}
+	return that.table(logicalTable, v), nil
+}
+
+func (that *HashModShard) Tables(logicalTable string, modPointer interface{}, condition map[string]interface{}) ([]string, error) {
+	if v, isOk := shardConditionValue(modPointer, that.Field, condition); isOk {
+		return []string{that.table(logicalTable, v)}, nil
+	}
+	tables := make([]string, that.Count)
+	for i := range tables {
+		tables[i] = fmt.Sprintf("%s_%d", logicalTable, i)
+	}
+	return tables, nil
+}
+
+// MonthShard 按时间字段每月一个物理表，物理表为 逻辑表_200601，如 order_log_202610。
+// 写入时时间字段为零值则使用 Clock 的当前时间（与插入的创建时间一致），UpdateByID、DeleteByID、FindByID 需时间字段有值。
+// 查询按 CondBeginTime、CondEndTime 确定月份范围（结束时间不包含），没有开始时间或开始时间早于 Begin 时从 Begin 开始（Begin 为零值时从结束月份开始），没有结束时间时到当前月。
+type MonthShard struct {
+	// Field 时间的表字段，字段可以是 time.Time、unix 时间戳（按 precision tag）或 "2006-01-02 15:04:05"（按 tz tag）
+	Field string
+	// Begin 最早的物理表所在月份
+	Begin time.Time
+}
+
+func (that *MonthShard) Table(logicalTable string, modPointer interface{}) (string, error) {
+	v, err := shardFieldValue(modPointer, that.Field)
+	if nil != err {
+		return "", err
+	}
+	_, tf, _ := getModelMeta(modPointer).fieldByTable(that.Field)
+	tm, err := shardTime(v, tf)
+	if nil != err {
+		return "", err
+	}
+	if tm.IsZero() {
+		tm = nowTime()
+	}
+	// 月份按字段的时区计算，与写入的时间字符串一致
+	if loc, err := timestampLocation(tf); nil == err {
+		tm = tm.In(loc)
+	}
+	return fmt.Sprintf("%s_%s", logicalTable, tm.Format("200601")), nil
+}
+
+func (that *MonthShard) Tables(logicalTable string, modPointer interface{}, condition map[string]interface{}) ([]string, error) {
+	_, tf, _ := getModelMeta(modPointer).fieldByTable(that.Field)
+	loc, err := timestampLocation(tf)
+	if nil != err {
+		return nil, err
+	}
+	begin, end := that.Begin, nowTime()
+	if begin.IsZero() {
+		begin = end
+	}
+	if v, isOk := condition[CondBeginTime]; isOk {
+		tm, err := condTime(v, tf)
+		if nil != err {
+			return nil, err
+		}
+		// 早于 Begin 的月份没有物理表
+		if that.Begin.IsZero() || tm.After(that.Begin) {
+			begin = tm
+		}
+	}
+	if v, isOk := condition[CondEndTime]; isOk {
+		tm, err := condTime(v, tf)
+		if nil != err {
+			return nil, err
+		}
+		end = tm.Add(-time.Second)
+	}
+	begin, end = begin.In(loc), end.In(loc)
+	tables := make([]string, 0)
+	month := time.Date(begin.Year(), begin.Month(), 1, 0, 0, 0, 0, begin.Location())
+	for !month.After(end) {
+		tables = append(tables, fmt.Sprintf("%s_%s", logicalTable, month.Format("200601")))
+		month = month.AddDate(0, 1, 0)
+	}
+	return tables, nil
+}
+
+// shardTime 时间分片键转为时间，见 toTime
+func shardTime(v interface{}, tf TableField) (time.Time, error) {
+	return toTime(v, tf)
+}
+
+// findListSharded 多个物理表的列表查询：每个物理表查询 offset+size 条，合并排序后分页。
+// 每页的查询量随页码线性增长，逐页读取全部数据（如 BaseService.List）的总查询量为页数的平方，
+// 跨多个物理表的深分页应缩小条件范围，使其解析为一个物理表后使用游标分页或遍历
+func (that *BaseDao) findListSharded(ctx context.Context, q Querier, metas []*modelMeta, condition map[string]interface{}, modPointer interface{}) (reflect.Value, error) {
+	offset, size := LimitOffset(condition)
+	fields, asc := cursorOrder(metas[0], condition)
+	orderBy := "DESC"
+	if asc {
+		orderBy = "ASC"
+	}
+	orders := make([]string, 0, len(fields))
+	for _, f := range fields {
+		orders = append(orders, fmt.Sprintf("%s.%s %s", metas[0].alias, f, orderBy))
+	}
+
+	list := reflect.MakeSlice(reflect.SliceOf(reflect.TypeOf(modPointer)), 0, 0)
+	for _, meta := range metas {
+		fieldStr, _ := (&BaseModel{}).GetModelFieldsToFieldStr(meta.alias, meta.listTableFields)
+		where, params, err := that.buildWhere(ctx, meta, condition)
+		if nil != err {
+			return list, err
+		}
+		s := fmt.Sprintf("SELECT %s FROM %s AS %s %s ORDER BY %s LIMIT 0,%d", fieldStr, meta.tableName, meta.alias, where, strings.Join(orders, ","), offset+size)
+		part, err := that.queryModels(ctx, q, meta, OpFindList, s, params, modPointer)
+		if nil != err {
+			return list, err
+		}
+		list = reflect.AppendSlice(list, part)
+	}
+
+	names := make([]string, len(fields))
+	for i, f := range fields {
+		names[i], _, _ = metas[0].fieldByTable(f)
+	}
+	rows := make([]reflect.Value, list.Len())
+	for i := range rows {
+		rows[i] = list.Index(i)
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		for _, name := range names {
+			c := CompareValue(rows[i].Elem().FieldByName(name).Interface(), rows[j].Elem().FieldByName(name).Interface())
+			if 0 != c {
+				return (0 > c) == asc
+			}
+		}
+		return false
+	})
+
+	if offset > len(rows) {
+		offset = len(rows)
+	}
+	end := offset + size
+	if end > len(rows) {
+		end = len(rows)
+	}
+	result := reflect.MakeSlice(list.Type(), 0, end-offset)
+	for _, row := range rows[offset:end] {
+		result = reflect.Append(result, row)
+	}
+	return result, nil
+}
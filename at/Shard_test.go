@@ -0,0 +1,64 @@
+package at
+
+import (
+	"context"
+	"database/sql/driver"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLimitOffsetNegative(t *testing.T) {
+	for _, condition := range []map[string]interface{}{
+		{CondPageIndex: 0},
+		{CondLimitBegin: -5},
+		{CondPageIndex: "-1", CondPageSize: "10"},
+	} {
+		if offset, size := LimitOffset(condition); 0 != offset || 0 > size {
+			t.Errorf("LimitOffset(%v) = %d,%d", condition, offset, size)
+		}
+	}
+}
+
+func TestFindListShardedPageIndexZero(t *testing.T) {
+	RegisterShard("event", &HashModShard{Field: "id", Count: 2})
+	defer func() {
+		shardsLock.Lock()
+		defer shardsLock.Unlock()
+		delete(shards, "event")
+	}()
+
+	f, db := newFakeDB()
+	defer db.Close()
+	f.rows = func(string) ([]string, [][]driver.Value) {
+		return []string{"id", "created_at", "updated_at"}, [][]driver.Value{{int64(1), int64(0), ""}}
+	}
+	list := make([]*testEvent, 0)
+	for _, condition := range []map[string]interface{}{{CondPageIndex: 0}, {CondLimitBegin: -1}} {
+		if err := GetInstanceByBaseDao().FindListContext(context.Background(), db, condition, &testEvent{}, &list); nil != err {
+			t.Fatal(err)
+		}
+		if 2 != len(list) {
+			t.Errorf("%v: got %d rows, want 2", condition, len(list))
+		}
+	}
+}
+
+func TestMonthShardTablesClampToBegin(t *testing.T) {
+	loc, err := time.LoadLocation("Asia/Shanghai")
+	if nil != err {
+		t.Skip(err)
+	}
+	s := &MonthShard{Field: "created_at", Begin: time.Date(2024, 3, 1, 0, 0, 0, 0, loc)}
+	tables, err := s.Tables("event", &testEvent{}, map[string]interface{}{
+		CondBeginTime: time.Date(2020, 1, 1, 0, 0, 0, 0, loc),
+		CondEndTime:   time.Date(2024, 5, 1, 0, 0, 0, 0, loc),
+	})
+	if nil != err {
+		t.Fatal(err)
+	}
+	want := []string{"event_202403", "event_202404"}
+	if !reflect.DeepEqual(want, tables) {
+		t.Errorf("tables = %v, want %v", tables, want)
+	}
+}
@@ -0,0 +1,114 @@
+package at
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// StructuredLogs 结构化日志，InitDao 传入的 Logs 同时实现此接口时，
+// BaseDao 以 key/value 属性输出（table、operation、sql、duration、rows 等），不再拼接字符串
+type StructuredLogs interface {
+	Log(ctx context.Context, level slog.Level, msg string, attrs ...slog.Attr)
+}
+
+// 日志属性名
+const (
+	LogKeyTable     = "table"
+	LogKeyOperation = "operation"
+	LogKeySQL       = "sql"
+	LogKeyArgs      = "args"
+	LogKeyDuration  = "duration"
+	LogKeyRows      = "rows"
+	LogKeyErr       = "err"
+)
+
+// BaseDao 输出日志的最低级别，默认 Debug 全部输出；LevelVar 可在运行中并发修改
+var daoLogLevel = func() *slog.LevelVar {
+	v := &slog.LevelVar{}
+	v.Set(slog.LevelDebug)
+	return v
+}()
+
+// SetDaoLogLevel 设置 BaseDao 输出日志的最低级别，可在运行中调用
+func SetDaoLogLevel(level slog.Level) {
+	daoLogLevel.Set(level)
+}
+
+// SlogLogs 基于 log/slog 的 Logs 实现，同时实现 StructuredLogs
+type SlogLogs struct {
+	logger *slog.Logger
+}
+
+// NewSlogLogs 创建 slog 日志
+// handler slog.Handler	slog 的输出，如 slog.NewJSONHandler(os.Stdout, nil)
+func NewSlogLogs(handler slog.Handler) *SlogLogs {
+	return &SlogLogs{logger: slog.New(handler)}
+}
+
+// InitDaoSlog 以 slog.Handler 初始化 Dao 日志
+func InitDaoSlog(handler slog.Handler) {
+	InitDao(NewSlogLogs(handler))
+}
+
+func (that *SlogLogs) Debug(msg string) {
+	that.logger.Debug(msg)
+}
+
+func (that *SlogLogs) Error(msg string, err error) {
+	that.logger.Error(msg, slog.Any(LogKeyErr, err))
+}
+
+func (that *SlogLogs) Log(ctx context.Context, level slog.Level, msg string, attrs ...slog.Attr) {
+	that.logger.LogAttrs(ctx, level, msg, attrs...)
+}
+
+// logAttrs 按级别输出带属性的日志，Logs 未实现 StructuredLogs 时将属性拼接为 k=v 后调用 Debug/Error
+func (*BaseDao) logAttrs(ctx context.Context, level slog.Level, msg string, err error, attrs ...slog.Attr) {
+	if nil == daoLogs || level < daoLogLevel.Level() {
+		return
+	}
+	if sl, isOk := daoLogs.(StructuredLogs); isOk {
+		if nil != err {
+			attrs = append(attrs, slog.Any(LogKeyErr, err))
+		}
+		sl.Log(ctx, level, msg, attrs...)
+		return
+	}
+
+	b := strings.Builder{}
+	b.WriteString(msg)
+	for _, a := range attrs {
+		b.WriteString(fmt.Sprintf(" %s=%v", a.Key, a.Value))
+	}
+	if level >= slog.LevelWarn {
+		daoLogs.Error(b.String(), err)
+	} else {
+		daoLogs.Debug(b.String())
+	}
+}
+
+// logEvent 语句执行后输出，成功为 Debug，失败为 Error
+func (that *BaseDao) logEvent(ctx context.Context, event *QueryEvent) {
+	level := slog.LevelDebug
+	if nil != event.Err {
+		level = slog.LevelError
+	}
+	that.logAttrs(ctx, level, event.Operation, event.Err,
+		slog.String(LogKeyTable, event.Table),
+		slog.String(LogKeyOperation, event.Operation),
+		slog.String(LogKeySQL, event.SQL),
+		slog.Any(LogKeyArgs, event.Args),
+		slog.Duration(LogKeyDuration, event.Duration),
+		slog.Int64(LogKeyRows, event.RowsAffected),
+	)
+}
+
+// logOp 输出 BaseDao 方法内非语句执行的日志，带 table、operation 属性
+func (that *BaseDao) logOp(ctx context.Context, level slog.Level, tableName, op, msg string, err error) {
+	that.logAttrs(ctx, level, msg, err,
+		slog.String(LogKeyTable, tableName),
+		slog.String(LogKeyOperation, op),
+	)
+}
@@ -0,0 +1,95 @@
+package at
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func testQueryEvent(err error) *QueryEvent {
+	return &QueryEvent{Table: "person", Operation: OpAddModel, SQL: "INSERT INTO person(name) VALUE(?)", Args: []interface{}{"tom"}, Duration: time.Millisecond, RowsAffected: 1, Err: err}
+}
+
+func TestLogEventStructured(t *testing.T) {
+	logs := &structuredLogs{}
+	InitDao(logs)
+	defer InitDao(nil)
+
+	dao := GetInstanceByBaseDao()
+	dao.logEvent(context.Background(), testQueryEvent(nil))
+	errExec := errors.New("exec failed")
+	dao.logEvent(context.Background(), testQueryEvent(errExec))
+	if 2 != len(logs.records) {
+		t.Fatalf("records = %v", logs.records)
+	}
+	r := logs.records[0]
+	if slog.LevelDebug != r.level || OpAddModel != r.msg {
+		t.Errorf("level = %v msg = %q", r.level, r.msg)
+	}
+	if "person" != r.attrs[LogKeyTable].String() || OpAddModel != r.attrs[LogKeyOperation].String() ||
+		"INSERT INTO person(name) VALUE(?)" != r.attrs[LogKeySQL].String() || 1 != r.attrs[LogKeyRows].Int64() ||
+		time.Millisecond != r.attrs[LogKeyDuration].Duration() {
+		t.Errorf("attrs = %v", r.attrs)
+	}
+	if _, isOk := r.attrs[LogKeyErr]; isOk {
+		t.Errorf("successful statement has err attr: %v", r.attrs)
+	}
+	r = logs.records[1]
+	if slog.LevelError != r.level || errExec != r.attrs[LogKeyErr].Any() {
+		t.Errorf("failed statement = %v %v", r.level, r.attrs)
+	}
+}
+
+func TestLogEventPlain(t *testing.T) {
+	logs := &bufferLogs{}
+	InitDao(logs)
+	defer InitDao(nil)
+
+	dao := GetInstanceByBaseDao()
+	dao.logEvent(context.Background(), testQueryEvent(nil))
+	dao.logEvent(context.Background(), testQueryEvent(errors.New("exec failed")))
+	lines := strings.Split(strings.TrimSpace(logs.buf.String()), "\n")
+	if 2 != len(lines) {
+		t.Fatalf("lines = %q", lines)
+	}
+	if want := "AddModel table=person operation=AddModel sql=INSERT INTO person(name) VALUE(?) args=[tom] duration=1ms rows=1"; want != lines[0] {
+		t.Errorf("debug = %q, want %q", lines[0], want)
+	}
+	if !strings.HasPrefix(lines[1], "AddModel table=person") || !strings.HasSuffix(lines[1], " exec failed") {
+		t.Errorf("error = %q", lines[1])
+	}
+}
+
+func TestSetDaoLogLevel(t *testing.T) {
+	logs := &structuredLogs{}
+	InitDao(logs)
+	defer InitDao(nil)
+	SetDaoLogLevel(slog.LevelWarn)
+	defer SetDaoLogLevel(slog.LevelDebug)
+
+	dao := GetInstanceByBaseDao()
+	dao.logEvent(context.Background(), testQueryEvent(nil))
+	dao.logEvent(context.Background(), testQueryEvent(errors.New("exec failed")))
+	if 1 != len(logs.records) || slog.LevelError != logs.records[0].level {
+		t.Fatalf("records = %v, want only the error", logs.records)
+	}
+
+	// 运行中修改级别与输出日志并发执行，go test -race 检查
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			SetDaoLogLevel(slog.Level(i % 2 * 4))
+		}(i)
+		go func() {
+			defer wg.Done()
+			dao.logEvent(context.Background(), testQueryEvent(nil))
+		}()
+	}
+	wg.Wait()
+}
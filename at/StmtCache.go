@@ -0,0 +1,179 @@
+package at
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"sync"
+)
+
+// StmtCache 预编译语句缓存，以数据源与生成的 SQL 为 key，超出容量时淘汰最久未使用的语句。
+// 语句在开启事务的数据源上预编译，事务内通过 tx.Stmt 使用，连接断开重连后由 database/sql 自动重新预编译。
+// 只缓存 AddModel、UpdateByID、DeleteByID 等生成的 SQL，自定义 SQL 与批量插入不缓存；
+// 只用于 TransactionContext（包括 Transaction、BaseService 的事务）开启的事务，自己开启的事务直接执行。
+type StmtCache struct {
+	db       *sql.DB
+	capacity int
+	lock     sync.Mutex
+	ll       *list.List
+	items    map[stmtKey]*list.Element
+}
+
+type stmtKey struct {
+	db  *sql.DB
+	sql string
+}
+
+type stmtCacheEntry struct {
+	key  stmtKey
+	stmt *sql.Stmt
+}
+
+// NewStmtCache 创建预编译语句缓存
+// db *sql.DB	Get 预编译所在的数据源，事务使用开启该事务的数据源
+// capacity int	最多缓存的语句数量，< 1 时为 128
+func NewStmtCache(db *sql.DB, capacity int) *StmtCache {
+	if 1 > capacity {
+		capacity = 128
+	}
+	return &StmtCache{
+		db:       db,
+		capacity: capacity,
+		ll:       list.New(),
+		items:    make(map[stmtKey]*list.Element),
+	}
+}
+
+var stmtCache *StmtCache
+var stmtCacheLock sync.RWMutex
+
+// SetStmtCache 设置 BaseDao 使用的预编译语句缓存，nil 为关闭，替换时旧缓存的语句全部关闭
+func SetStmtCache(cache *StmtCache) {
+	stmtCacheLock.Lock()
+	old := stmtCache
+	stmtCache = cache
+	stmtCacheLock.Unlock()
+	if nil != old && cache != old {
+		old.Reset()
+	}
+}
+
+func getStmtCache() *StmtCache {
+	stmtCacheLock.RLock()
+	defer stmtCacheLock.RUnlock()
+	return stmtCache
+}
+
+// 使用预编译缓存的操作，均为按 model 生成的 SQL
+var stmtCacheableOp = map[string]bool{
+	OpAddModel:   true,
+	OpUpdateByID: true,
+	OpDeleteByID: true,
+	OpAuditWrite: true,
+}
+
+// Get 取得 s 在 NewStmtCache 的数据源上的预编译语句，未缓存时预编译并加入缓存
+func (that *StmtCache) Get(ctx context.Context, s string) (*sql.Stmt, error) {
+	return that.get(ctx, that.db, s)
+}
+
+func (that *StmtCache) get(ctx context.Context, db *sql.DB, s string) (*sql.Stmt, error) {
+	key := stmtKey{db: db, sql: s}
+	that.lock.Lock()
+	if e, isOk := that.items[key]; isOk {
+		that.ll.MoveToFront(e)
+		that.lock.Unlock()
+		return e.Value.(*stmtCacheEntry).stmt, nil
+	}
+	that.lock.Unlock()
+
+	// 预编译不持有锁，并发时可能重复预编译，后到者关闭自己的语句
+	stmt, err := db.PrepareContext(ctx, s)
+	if nil != err {
+		return nil, err
+	}
+
+	that.lock.Lock()
+	defer that.lock.Unlock()
+	if e, isOk := that.items[key]; isOk {
+		stmt.Close()
+		that.ll.MoveToFront(e)
+		return e.Value.(*stmtCacheEntry).stmt, nil
+	}
+	that.items[key] = that.ll.PushFront(&stmtCacheEntry{key: key, stmt: stmt})
+	for that.ll.Len() > that.capacity {
+		that.removeElement(that.ll.Back())
+	}
+	return stmt, nil
+}
+
+// Remove 移除并关闭 s 在各数据源上的预编译语句
+func (that *StmtCache) Remove(s string) {
+	that.lock.Lock()
+	defer that.lock.Unlock()
+	for key, e := range that.items {
+		if s == key.sql {
+			that.removeElement(e)
+		}
+	}
+}
+
+func (that *StmtCache) remove(key stmtKey) {
+	that.lock.Lock()
+	defer that.lock.Unlock()
+	if e, isOk := that.items[key]; isOk {
+		that.removeElement(e)
+	}
+}
+
+// Reset 关闭并清空全部预编译语句，数据源重建或连接重置时调用
+func (that *StmtCache) Reset() {
+	that.lock.Lock()
+	defer that.lock.Unlock()
+	for e := that.ll.Front(); nil != e; e = that.ll.Front() {
+		that.removeElement(e)
+	}
+}
+
+// Len 当前缓存的语句数量
+func (that *StmtCache) Len() int {
+	that.lock.Lock()
+	defer that.lock.Unlock()
+	return that.ll.Len()
+}
+
+func (that *StmtCache) removeElement(e *list.Element) {
+	entry := that.ll.Remove(e).(*stmtCacheEntry)
+	delete(that.items, entry.key)
+	entry.stmt.Close()
+}
+
+// txExecContext 执行语句，op 可缓存、设置了 StmtCache 且事务由 TransactionContext 开启时，
+// 通过 tx.Stmt 使用开启事务的数据源上的预编译语句
+func txExecContext(ctx context.Context, tx *sql.Tx, op, s string, args ...interface{}) (sql.Result, error) {
+	cache := getStmtCache()
+	if nil == cache || !stmtCacheableOp[op] {
+		return tx.ExecContext(ctx, s, args...)
+	}
+	db, isOk := txDB(tx)
+	if !isOk {
+		return tx.ExecContext(ctx, s, args...)
+	}
+	stmt, err := cache.get(ctx, db, s)
+	if nil != err {
+		// 预编译失败不影响执行
+		return tx.ExecContext(ctx, s, args...)
+	}
+	result, err := tx.StmtContext(ctx, stmt).ExecContext(ctx, args...)
+	if nil != err && isStmtInvalid(err) {
+		cache.remove(stmtKey{db: db, sql: s})
+	}
+	return result, err
+}
+
+// isStmtInvalid 连接失效时预编译语句不再可用，从缓存中移除
+func isStmtInvalid(err error) bool {
+	return errors.Is(err, driver.ErrBadConn) || errors.Is(err, sql.ErrConnDone)
+}
@@ -0,0 +1,71 @@
+package at
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"testing"
+)
+
+func TestStmtCachePerDB(t *testing.T) {
+	f1, db1 := newFakeDB()
+	defer db1.Close()
+	f2, db2 := newFakeDB()
+	defer db2.Close()
+	cache := NewStmtCache(db1, 0)
+	SetStmtCache(cache)
+	defer SetStmtCache(nil)
+
+	for i, db := range []*sql.DB{db1, db2} {
+		err := GetInstanceByBaseDao().Transaction(db, func(tx *sql.Tx) error {
+			_, err := GetInstanceByBaseDao().AddModelContext(context.Background(), tx, &testItem{Title: "a"})
+			return err
+		})
+		if nil != err {
+			t.Fatal(err)
+		}
+		if i+1 != cache.Len() {
+			t.Errorf("cache has %d statements after a transaction on db%d", cache.Len(), i+1)
+		}
+	}
+	if 1 != len(f1.execs) || 1 != len(f2.execs) {
+		t.Errorf("db1 executed %v, db2 executed %v", f1.execs, f2.execs)
+	}
+
+	// 自己开启的事务不使用缓存
+	tx, err := db2.Begin()
+	if nil != err {
+		t.Fatal(err)
+	}
+	if _, err = GetInstanceByBaseDao().AddModelContext(context.Background(), tx, &testItem{Title: "b"}); nil != err {
+		t.Fatal(err)
+	}
+	if err = tx.Commit(); nil != err {
+		t.Fatal(err)
+	}
+	if 2 != cache.Len() || 2 != len(f2.execs) {
+		t.Errorf("cache has %d statements, db2 executed %v", cache.Len(), f2.execs)
+	}
+}
+
+func TestSetStmtCacheConcurrent(t *testing.T) {
+	_, db := newFakeDB()
+	defer db.Close()
+	defer SetStmtCache(nil)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			SetStmtCache(NewStmtCache(db, 0))
+		}()
+		go func() {
+			defer wg.Done()
+			_ = GetInstanceByBaseDao().Transaction(db, func(tx *sql.Tx) error {
+				_, err := GetInstanceByBaseDao().AddModelContext(context.Background(), tx, &testItem{Title: "a"})
+				return err
+			})
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,182 @@
+package at
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// Storage BaseService 的存储后端，默认使用 SetDb 设置的数据源，
+// 单元测试可以通过 SetStorage(NewMemoryStorage()) 替换为内存实现，不依赖数据库
+type Storage interface {
+	// AddModel 入库一个 model，返回主键值
+	AddModel(ctx context.Context, modPointer interface{}) (int64, error)
+	// UpdateByID 根据主键修改一个 model，返回受影响行数
+	UpdateByID(ctx context.Context, modPointer interface{}) (int64, error)
+	// DeleteByID 根据主键删除一个 model，返回受影响行数
+	DeleteByID(ctx context.Context, modPointer interface{}) (int64, error)
+	// FindByID 根据主键查询，结果装入 modPointer，返回是否找到
+	FindByID(ctx context.Context, modPointer interface{}) (bool, error)
+	// FindList 按 condition 查询列表，listPointer 为 *[]*Model
+	FindList(ctx context.Context, condition map[string]interface{}, modPointer interface{}, listPointer interface{}) error
+	// Count 按 condition 统计数量
+	Count(ctx context.Context, condition map[string]interface{}, modPointer interface{}) (int64, error)
+	// AddModelBatch 批量入库，modPointerList 为 []*Model，返回受影响行数
+	AddModelBatch(ctx context.Context, modPointerList interface{}) (int64, error)
+	// Upsert 入库一个 model，主键冲突时修改，返回受影响行数
+	Upsert(ctx context.Context, modPointer interface{}) (int64, error)
+}
+
+var storage Storage
+
+// SetStorage 设置 BaseService 的存储后端，nil 时恢复为数据库
+func SetStorage(s Storage) {
+	storage = s
+}
+
+func getStorage() Storage {
+	if nil != storage {
+		return storage
+	}
+	return &sqlStorageInstance
+}
+
+// sqlStorage 数据库存储，上下文中有事务（见 WithTx）时在该事务中执行，否则写操作各自开启事务、查询使用 db
+type sqlStorage struct {
+}
+
+var sqlStorageInstance sqlStorage
+
+var errNoDb = errors.New("error:db not set, call at.SetDb first")
+
+type txKey struct{}
+
+// WithTx 在上下文中放入事务，BaseService 的方法使用此上下文时在该事务中执行
+func WithTx(ctx context.Context, tx *sql.Tx) context.Context {
+	return context.WithValue(ctx, txKey{}, tx)
+}
+
+// TxFromContext 取出上下文中的事务
+func TxFromContext(ctx context.Context) (*sql.Tx, bool) {
+	tx, isOk := ctx.Value(txKey{}).(*sql.Tx)
+	return tx, isOk && nil != tx
+}
+
+func (that *sqlStorage) transaction(ctx context.Context, fun func(tx *sql.Tx) error) error {
+	if tx, isOk := TxFromContext(ctx); isOk {
+		return fun(tx)
+	}
+	if nil == db {
+		return errNoDb
+	}
+	return GetInstanceByBaseDao().TransactionContext(ctx, db, nil, fun)
+}
+
+// querier 查询使用上下文中的事务，没有时使用 db
+func (that *sqlStorage) querier(ctx context.Context) (Querier, error) {
+	if tx, isOk := TxFromContext(ctx); isOk {
+		return tx, nil
+	}
+	if nil == db {
+		return nil, errNoDb
+	}
+	return db, nil
+}
+
+func (that *sqlStorage) AddModel(ctx context.Context, modPointer interface{}) (int64, error) {
+	var result int64
+	err := that.transaction(ctx, func(tx *sql.Tx) error {
+		var err error
+		result, err = GetInstanceByBaseDao().AddModelContext(ctx, tx, modPointer)
+		return err
+	})
+	if nil != err {
+		return 0, err
+	}
+	return result, nil
+}
+
+func (that *sqlStorage) UpdateByID(ctx context.Context, modPointer interface{}) (int64, error) {
+	var result int64
+	err := that.transaction(ctx, func(tx *sql.Tx) error {
+		var err error
+		result, err = GetInstanceByBaseDao().UpdateByIDContext(ctx, tx, modPointer)
+		return err
+	})
+	if nil != err {
+		return 0, err
+	}
+	return result, nil
+}
+
+func (that *sqlStorage) DeleteByID(ctx context.Context, modPointer interface{}) (int64, error) {
+	var result int64
+	err := that.transaction(ctx, func(tx *sql.Tx) error {
+		var err error
+		result, err = GetInstanceByBaseDao().DeleteByIDContext(ctx, tx, modPointer)
+		return err
+	})
+	if nil != err {
+		return 0, err
+	}
+	return result, nil
+}
+
+func (that *sqlStorage) FindByID(ctx context.Context, modPointer interface{}) (bool, error) {
+	q, err := that.querier(ctx)
+	if nil != err {
+		return false, err
+	}
+	return GetInstanceByBaseDao().FindByIDContext(ctx, q, modPointer)
+}
+
+func (that *sqlStorage) FindList(ctx context.Context, condition map[string]interface{}, modPointer interface{}, listPointer interface{}) error {
+	q, err := that.querier(ctx)
+	if nil != err {
+		return err
+	}
+	return GetInstanceByBaseDao().FindListContext(ctx, q, condition, modPointer, listPointer)
+}
+
+// findListByCursor 游标分页查询，用于 BaseService.List 分批读取，不属于 Storage 接口
+func (that *sqlStorage) findListByCursor(ctx context.Context, condition map[string]interface{}, modPointer interface{}, listPointer interface{}) (*CursorPage, error) {
+	q, err := that.querier(ctx)
+	if nil != err {
+		return nil, err
+	}
+	return GetInstanceByBaseDao().FindListByCursorContext(ctx, q, condition, modPointer, listPointer)
+}
+
+func (that *sqlStorage) Count(ctx context.Context, condition map[string]interface{}, modPointer interface{}) (int64, error) {
+	q, err := that.querier(ctx)
+	if nil != err {
+		return 0, err
+	}
+	return GetInstanceByBaseDao().CountContext(ctx, q, condition, modPointer)
+}
+
+func (that *sqlStorage) AddModelBatch(ctx context.Context, modPointerList interface{}) (int64, error) {
+	var result int64
+	err := that.transaction(ctx, func(tx *sql.Tx) error {
+		var err error
+		_, result, err = GetInstanceByBaseDao().AddModelBatchContext(ctx, tx, modPointerList)
+		return err
+	})
+	if nil != err {
+		return 0, err
+	}
+	return result, nil
+}
+
+func (that *sqlStorage) Upsert(ctx context.Context, modPointer interface{}) (int64, error) {
+	var result int64
+	err := that.transaction(ctx, func(tx *sql.Tx) error {
+		var err error
+		result, err = GetInstanceByBaseDao().UpsertContext(ctx, tx, modPointer)
+		return err
+	})
+	if nil != err {
+		return 0, err
+	}
+	return result, nil
+}
@@ -0,0 +1,113 @@
+package at
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// 多租户：model 有租户字段（tenant tag，或 InitTenant 设置的表字段）时，标准方法按上下文中的租户自动限定：
+// 查询（FindByID、FindList、Count、游标分页、遍历）增加 租户字段 = ?，UpdateByID、DeleteByID 的 WHERE 增加租户条件，
+// AddModel、AddModelBatch、UpdateByID 将租户写入 model。Update、UpdateMustAffected 等手写 SQL 不处理。
+//
+//	ctx = at.WithTenant(ctx, 1001)
+//	dao.FindListContext(ctx, db, condition, &User{}, &list)
+//
+// 上下文中没有租户时返回 ErrTenantRequired，跨租户的后台任务需显式使用 WithoutTenant。
+const tenantTag = "tenant"
+
+// 作为租户字段的表字段，默认 ""，只识别 tenant tag
+var tenantColumn = ""
+
+// ErrTenantRequired 操作有租户字段的 model 时上下文中没有租户
+var ErrTenantRequired = errors.New("error:tenant required")
+
+// InitTenant 设置租户表字段，如 tenant_id，全部有此表字段的 model 都按租户限定，需在读取 model 之前调用
+func InitTenant(column string) {
+	tenantColumn = column
+}
+
+type tenantKey struct{}
+
+type tenantBypassKey struct{}
+
+// WithTenant 在上下文中放入租户
+func WithTenant(ctx context.Context, tenantID interface{}) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenantID)
+}
+
+// WithoutTenant 跨租户操作，标准方法不再限定租户，插入时使用 model 中的租户，仅用于后台任务
+func WithoutTenant(ctx context.Context) context.Context {
+	return context.WithValue(ctx, tenantBypassKey{}, true)
+}
+
+// TenantFromContext 取出上下文中的租户
+func TenantFromContext(ctx context.Context) (interface{}, bool) {
+	v := ctx.Value(tenantKey{})
+	return v, nil != v
+}
+
+// tenantField model 的租户字段
+func (that *modelMeta) tenantField() (string, TableField, bool) {
+	for k, v := range that.mapModelTableField {
+		if PropertyTenant == v.FieldProperty {
+			return k, v, true
+		}
+	}
+	return "", TableField{}, false
+}
+
+// tenantScope 需要限定的租户，scoped 为 false 时不限定（model 没有租户字段或使用了 WithoutTenant）
+func tenantScope(ctx context.Context, meta *modelMeta) (tf TableField, tenantID interface{}, scoped bool, err error) {
+	_, tf, isOk := meta.tenantField()
+	if !isOk {
+		return tf, nil, false, nil
+	}
+	if bypass, _ := ctx.Value(tenantBypassKey{}).(bool); bypass {
+		return tf, nil, false, nil
+	}
+	tenantID, isOk = TenantFromContext(ctx)
+	if !isOk {
+		return tf, nil, false, ErrTenantRequired
+	}
+	return tf, tenantID, true, nil
+}
+
+// setTenant 将上下文中的租户写入 model 的租户字段
+func setTenant(ctx context.Context, meta *modelMeta, val reflect.Value) error {
+	tf, tenantID, scoped, err := tenantScope(ctx, meta)
+	if nil != err || !scoped {
+		return err
+	}
+	fv := val.FieldByName(tf.FieldNameByModel)
+	tv := reflect.ValueOf(tenantID)
+	if !tv.Type().ConvertibleTo(fv.Type()) {
+		return errors.New(fmt.Sprintf("error:tenant %T cannot be set to %s", tenantID, fv.Type()))
+	}
+	fv.Set(tv.Convert(fv.Type()))
+	return nil
+}
+
+// tenantMatch 一行数据是否属于上下文中的租户，用于 MemoryStorage
+func tenantMatch(ctx context.Context, meta *modelMeta, row reflect.Value) (bool, error) {
+	tf, tenantID, scoped, err := tenantScope(ctx, meta)
+	if nil != err || !scoped {
+		return nil == err, err
+	}
+	return fmt.Sprint(row.FieldByName(tf.FieldNameByModel).Interface()) == fmt.Sprint(tenantID), nil
+}
+
+// AddCondTenant 为 sql 增加租户条件 alias.column = ?，whereSQL 可以是完整的 UPDATE、DELETE 语句
+func (that *BaseDao) AddCondTenant(whereSQL string, params []interface{}, alias, column string, tenantID interface{}) (string, []interface{}) {
+	params = append(params, tenantID)
+	field := column
+	if "" != alias {
+		field = fmt.Sprintf("%s.%s", alias, column)
+	}
+	if strings.Contains(whereSQL, "WHERE ") || strings.Contains(whereSQL, "Where ") {
+		return fmt.Sprintf("%s AND %s = ? ", whereSQL, field), params
+	}
+	return fmt.Sprintf("%s WHERE %s = ? ", whereSQL, field), params
+}
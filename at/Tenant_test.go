@@ -0,0 +1,45 @@
+package at
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"strings"
+	"testing"
+)
+
+func TestAddCondTenantKeepsStatement(t *testing.T) {
+	s, params := GetInstanceByBaseDao().AddCondTenant("SELECT * FROM person AS p", nil, "p", "tenant_id", 7)
+	if "SELECT * FROM person AS p WHERE p.tenant_id = ?" != strings.TrimSpace(s) {
+		t.Errorf("sql = %q", s)
+	}
+	if 1 != len(params) || 7 != params[0] {
+		t.Errorf("params = %v", params)
+	}
+}
+
+func TestAuditBeforeScopedToTenant(t *testing.T) {
+	f, db := newFakeDB()
+	defer db.Close()
+	f.rows = func(string) ([]string, [][]driver.Value) {
+		return []string{"id", "tenant_id", "body"}, [][]driver.Value{{int64(1), int64(7), "old"}}
+	}
+	ctx := WithTenant(context.Background(), int64(7))
+	err := GetInstanceByBaseDao().TransactionContext(ctx, db, nil, func(tx *sql.Tx) error {
+		_, err := GetInstanceByBaseDao().UpdateByIDContext(ctx, tx, &testNote{Id: 1, TenantId: 7, Body: "new"})
+		return err
+	})
+	if nil != err {
+		t.Fatal(err)
+	}
+	for i, s := range f.execs {
+		if !strings.HasPrefix(s, "SELECT") {
+			continue
+		}
+		if !strings.Contains(s, "tenant_id = ?") || int64(7) != f.args[i][len(f.args[i])-1] {
+			t.Errorf("audit before query is not scoped to the tenant: %s %v", s, f.args[i])
+		}
+		return
+	}
+	t.Fatalf("no audit before query in %v", f.execs)
+}
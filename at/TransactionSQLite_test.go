@@ -0,0 +1,163 @@
+package at
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// openSQLite 临时目录中的 SQLite 文件库，外键约束在提交时检查
+func openSQLite(t *testing.T, params string) *sql.DB {
+	t.Helper()
+	dsn := "file:" + filepath.Join(t.TempDir(), "tx.db") + "?_foreign_keys=1" + params
+	db, err := sql.Open("sqlite3", dsn)
+	if nil != err {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	for _, s := range []string{
+		"CREATE TABLE parent (id INTEGER PRIMARY KEY)",
+		"CREATE TABLE child (id INTEGER PRIMARY KEY, parent_id INTEGER REFERENCES parent(id) DEFERRABLE INITIALLY DEFERRED)",
+	} {
+		if _, err = db.Exec(s); nil != err {
+			t.Fatal(err)
+		}
+	}
+	return db
+}
+
+func countSQLite(t *testing.T, db *sql.DB, table string) int {
+	t.Helper()
+	var n int
+	if err := db.QueryRow("SELECT COUNT(*) FROM " + table).Scan(&n); nil != err {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestSQLiteTransactionBeginError(t *testing.T) {
+	db := openSQLite(t, "&_txlock=immediate&_busy_timeout=0")
+	// 另一个连接持有写锁，BEGIN IMMEDIATE 返回 database is locked
+	conn, err := db.Conn(context.Background())
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err = conn.ExecContext(context.Background(), "BEGIN EXCLUSIVE"); nil != err {
+		t.Fatal(err)
+	}
+	defer conn.ExecContext(context.Background(), "ROLLBACK")
+
+	called := false
+	err = GetInstanceByBaseDao().TransactionContext(context.Background(), db, nil, func(tx *sql.Tx) error {
+		called = true
+		return nil
+	})
+	if nil == err {
+		t.Fatal("Begin on a locked database succeeded")
+	}
+	if called {
+		t.Error("callBack called without a transaction")
+	}
+}
+
+func TestSQLiteTransactionCommitError(t *testing.T) {
+	db := openSQLite(t, "")
+	ran := false
+	err := GetInstanceByBaseDao().TransactionContext(context.Background(), db, nil, func(tx *sql.Tx) error {
+		AfterCommit(tx, func() { ran = true })
+		// 延迟外键约束，在 COMMIT 时失败
+		_, err := tx.Exec("INSERT INTO child (id, parent_id) VALUES (1, 99)")
+		return err
+	})
+	if nil == err {
+		t.Fatal("commit with a violated deferred foreign key succeeded")
+	}
+	if ran {
+		t.Error("AfterCommit ran after a failed commit")
+	}
+}
+
+func TestSQLiteTransactionRollbackErrorJoined(t *testing.T) {
+	db := openSQLite(t, "")
+	errCallBack := errors.New("callBack failed")
+	err := GetInstanceByBaseDao().TransactionContext(context.Background(), db, nil, func(tx *sql.Tx) error {
+		if _, err := tx.Exec("INSERT INTO parent (id) VALUES (1)"); nil != err {
+			return err
+		}
+		// 事务已结束，tx.Rollback 的 ROLLBACK 由 SQLite 返回错误
+		if _, err := tx.Exec("ROLLBACK"); nil != err {
+			return err
+		}
+		return errCallBack
+	})
+	if !errors.Is(err, errCallBack) {
+		t.Fatalf("err = %v, want callBack error", err)
+	}
+	if errCallBack == err {
+		t.Fatal("rollback error not joined")
+	}
+	if 0 != countSQLite(t, db, "parent") {
+		t.Error("row kept after rollback")
+	}
+}
+
+func TestSQLiteTransactionRePanic(t *testing.T) {
+	db := openSQLite(t, "")
+	want := errors.New("boom")
+	ran := false
+	defer func() {
+		if p := recover(); p != want {
+			t.Fatalf("recovered %v, want the original panic value", p)
+		}
+		if 0 != countSQLite(t, db, "parent") {
+			t.Error("row kept after panic")
+		}
+		if ran {
+			t.Error("AfterCommit ran after a panic")
+		}
+	}()
+	_ = GetInstanceByBaseDao().TransactionContext(context.Background(), db, nil, func(tx *sql.Tx) error {
+		AfterCommit(tx, func() { ran = true })
+		if _, err := tx.Exec("INSERT INTO parent (id) VALUES (1)"); nil != err {
+			return err
+		}
+		panic(want)
+	})
+	t.Fatal("panic not propagated")
+}
+
+func TestSQLiteAfterCommit(t *testing.T) {
+	db := openSQLite(t, "")
+	seen := -1
+	err := GetInstanceByBaseDao().TransactionContext(context.Background(), db, nil, func(tx *sql.Tx) error {
+		AfterCommit(tx, func() { seen = countSQLite(t, db, "parent") })
+		if _, err := tx.Exec("INSERT INTO parent (id) VALUES (1)"); nil != err {
+			return err
+		}
+		if -1 != seen {
+			t.Error("AfterCommit ran before commit")
+		}
+		return nil
+	})
+	if nil != err {
+		t.Fatal(err)
+	}
+	// 函数在提交后执行，其它连接能读到提交的数据
+	if 1 != seen {
+		t.Fatalf("AfterCommit saw %d rows, want 1", seen)
+	}
+
+	ran := false
+	err = GetInstanceByBaseDao().TransactionContext(context.Background(), db, nil, func(tx *sql.Tx) error {
+		AfterCommit(tx, func() { ran = true })
+		return errors.New("rollback")
+	})
+	if nil == err || ran {
+		t.Errorf("err = %v ran = %v, want rollback without AfterCommit", err, ran)
+	}
+}
@@ -0,0 +1,117 @@
+package at
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"strings"
+)
+
+// UpsertContext	插入一个 model，主键或唯一索引冲突时修改已存在的行。
+// MySQL 使用 ON DUPLICATE KEY UPDATE，SQLite、PostgreSQL 使用 ON CONFLICT (主键) DO UPDATE（见 SetDialect）。
+// 修改不包括主键、创建时间与租户字段，多租户时已存在的行属于其它租户则不修改。
+// 数据库自增主键为零值时不插入主键列，插入成功后 LastInsertId 写入 model。不写入审计记录。
+// ctx context.Context	上下文
+// tx *sql.Tx 事务控制器
+// modPointer interface{}	数据，model 的指针
+// int64	rowsAffected 受影响行数，MySQL 插入为 1，修改为 2，值没有变化为 0
+// error	err 不为 nil 时失败，应回滚事务
+func (that *BaseDao) UpsertContext(ctx context.Context, tx *sql.Tx, modPointer interface{}) (int64, error) {
+	meta := getModelMeta(modPointer)
+	val := reflect.ValueOf(modPointer).Elem()
+	if err := setTenant(ctx, meta, val); nil != err {
+		return -1, err
+	}
+	if err := fillID(meta, val); nil != err {
+		return -1, err
+	}
+	if err := Validate(modPointer); nil != err {
+		that.logOp(ctx, slog.LevelWarn, "", OpUpsert, "Validate", err)
+		return -1, err
+	}
+	tableName, err := shardTable(meta.tableName, modPointer)
+	if nil != err {
+		return -1, err
+	}
+
+	pk := val.FieldByName(meta.pkModelField())
+	autoPK := meta.pkAutoIncrement() && pk.IsZero()
+	_, tenantTF, hasTenant := meta.tenantField()
+	columns := make([]string, 0, len(meta.listTableFields))
+	updates := make([]string, 0, len(meta.listTableFields))
+	pks := pkTableFields(meta.listTableFields, meta.mapModelTableField)
+	for inx, f := range meta.listTableFields {
+		_, tf, _ := meta.fieldByTable(f)
+		if PropertyDeleteTime == tf.FieldProperty {
+			continue
+		}
+		isPK := isPKTableField(inx, f, pks)
+		if isPK && autoPK {
+			continue
+		}
+		columns = append(columns, f)
+		if isPK || PropertyCreateTime == tf.FieldProperty || PropertyTenant == tf.FieldProperty {
+			continue
+		}
+		updates = append(updates, upsertAssign(f, tenantTF.FieldNameByTable, hasTenant))
+	}
+
+	insertSQL := strings.Join(columns, ",")
+	values := strings.TrimSuffix(strings.Repeat("?,", len(columns)), ",")
+	s := fmt.Sprintf("INSERT INTO %s(%s) VALUES(%s) %s", tableName, insertSQL, values, upsertConflict(meta, tableName, updates, tenantTF.FieldNameByTable, hasTenant))
+	valueList := (&BaseModel{}).GetModelTableFieldValueList("", insertSQL, meta.mapModelTableField, modPointer)
+
+	r, err := that.execContext(ctx, tx, tableName, OpUpsert, s, valueList...)
+	if nil != err {
+		return -1, err
+	}
+	rows, err := r.RowsAffected()
+	if nil != err {
+		that.logOp(ctx, slog.LevelError, tableName, OpUpsert, "RowsAffected", err)
+		return -1, err
+	}
+	if autoPK && isIntKind(pk.Kind()) {
+		if id, err2 := r.LastInsertId(); nil == err2 && 0 < id {
+			setReflectInt(pk, id)
+		}
+	}
+	invalidateModelCache(tx, modPointer)
+	return rows, nil
+}
+
+// Upsert	见 UpsertContext
+func (that *BaseDao) Upsert(tx *sql.Tx, modPointer interface{}) (int64, error) {
+	return that.UpsertContext(context.Background(), tx, modPointer)
+}
+
+// upsertAssign 冲突时修改字段 f 的语句，多租户时只修改同一租户的行
+func upsertAssign(f, tenantColumn string, hasTenant bool) string {
+	if DialectMySQL != GetDialect() {
+		return fmt.Sprintf("%s = excluded.%s", f, f)
+	}
+	if hasTenant {
+		return fmt.Sprintf("%s = IF(%s = VALUES(%s), VALUES(%s), %s)", f, tenantColumn, tenantColumn, f, f)
+	}
+	return fmt.Sprintf("%s = VALUES(%s)", f, f)
+}
+
+// upsertConflict 冲突处理语句
+func upsertConflict(meta *modelMeta, tableName string, updates []string, tenantColumn string, hasTenant bool) string {
+	if DialectMySQL == GetDialect() {
+		if 0 == len(updates) {
+			return fmt.Sprintf("ON DUPLICATE KEY UPDATE %s = %s", meta.pkField, meta.pkField)
+		}
+		return fmt.Sprintf("ON DUPLICATE KEY UPDATE %s", strings.Join(updates, ","))
+	}
+	target := strings.Join(meta.pkFields, ",")
+	if 0 == len(updates) {
+		return fmt.Sprintf("ON CONFLICT (%s) DO NOTHING", target)
+	}
+	s := fmt.Sprintf("ON CONFLICT (%s) DO UPDATE SET %s", target, strings.Join(updates, ","))
+	if hasTenant {
+		s = fmt.Sprintf("%s WHERE %s.%s = excluded.%s", s, tableName, tenantColumn, tenantColumn)
+	}
+	return s
+}
@@ -0,0 +1,357 @@
+package at
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// 字段校验 tag，多个规则以逗号分隔，regexp 必须放在最后（正则中可以包含逗号），未导出的字段不能使用
+// 例：`validate:"required,max=32,enum=1|2|3,email,url,regexp=^[a-z]+$"`
+// required	必填，字符串不为空、数字不为 0、指针不为 nil、时间不为零值
+// max=N	字符串/切片为最大长度（字符数），数字为最大值，其它类型为 tag 错误
+// min=N	字符串/切片为最小长度（字符数），数字为最小值，其它类型为 tag 错误
+// enum=a|b	值必须是列表中的一个
+// email	邮箱格式
+// url	URL 格式（必须带 scheme 与 host）
+// regexp=P	必须匹配正则 P
+const validateTag = "validate"
+
+const (
+	RuleRequired = "required"
+	RuleMax      = "max"
+	RuleMin      = "min"
+	RuleEnum     = "enum"
+	RuleEmail    = "email"
+	RuleURL      = "url"
+	RuleRegexp   = "regexp"
+)
+
+// ValidationError 单个字段的校验失败信息
+type ValidationError struct {
+	// Index 批量校验时数据在切片中的下标，单条数据为 0
+	Index int `json:"index"`
+	// Field 字段名，优先使用 json tag
+	Field string `json:"field"`
+	// Rule 未通过的规则
+	Rule string `json:"rule"`
+	// Param 规则参数
+	Param string `json:"param,omitempty"`
+	// Message 失败描述
+	Message string `json:"message"`
+}
+
+func (that *ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", that.Field, that.Message)
+}
+
+// ValidationErrors 校验失败集合，AddModel、AddModelBatch、UpdateByID 校验未通过时返回
+type ValidationErrors []*ValidationError
+
+func (that ValidationErrors) Error() string {
+	msg := strings.Builder{}
+	msg.WriteString("validation failed: ")
+	for inx, v := range that {
+		if 0 != inx {
+			msg.WriteString("; ")
+		}
+		msg.WriteString(v.Error())
+	}
+	return msg.String()
+}
+
+// Fields 字段名与失败描述的映射，字段有多个失败时只保留第一个
+func (that ValidationErrors) Fields() map[string]string {
+	m := make(map[string]string, len(that))
+	for _, v := range that {
+		if _, isOk := m[v.Field]; !isOk {
+			m[v.Field] = v.Message
+		}
+	}
+	return m
+}
+
+type validateRule struct {
+	name  string
+	param string
+	num   float64
+	enum  map[string]bool
+	re    *regexp.Regexp
+}
+
+type validateField struct {
+	index []int
+	name  string
+	rules []validateRule
+}
+
+// 按 model 类型缓存解析后的规则
+var validateCache sync.Map
+
+// Validate 按 validate tag 校验 model
+// modPointer interface{}	数据，model 或 model 的指针
+// error	校验未通过时为 ValidationErrors，tag 书写错误时为普通 error
+func Validate(modPointer interface{}) error {
+	return validateIndex(modPointer, 0)
+}
+
+// ValidateList 校验切片中的每一个 model，返回全部失败信息，ValidationError.Index 为所在下标
+func ValidateList(modPointerList interface{}) error {
+	modLst := reflect.ValueOf(modPointerList)
+	errs := make(ValidationErrors, 0)
+	for i := 0; i < modLst.Len(); i++ {
+		err := validateIndex(modLst.Index(i).Interface(), i)
+		if nil == err {
+			continue
+		}
+		if ves, isOk := err.(ValidationErrors); isOk {
+			errs = append(errs, ves...)
+			continue
+		}
+		return err
+	}
+	if 0 != len(errs) {
+		return errs
+	}
+	return nil
+}
+
+func validateIndex(modPointer interface{}, index int) error {
+	val := reflect.ValueOf(modPointer)
+	for reflect.Ptr == val.Kind() {
+		if val.IsNil() {
+			return fmt.Errorf("validate: nil model %T", modPointer)
+		}
+		val = val.Elem()
+	}
+	if reflect.Struct != val.Kind() {
+		return fmt.Errorf("validate: model must be struct, got %T", modPointer)
+	}
+	fields, err := validateFields(val.Type())
+	if nil != err {
+		return err
+	}
+
+	errs := make(ValidationErrors, 0)
+	for _, f := range fields {
+		fv := val.FieldByIndex(f.index)
+		for _, r := range f.rules {
+			msg := r.check(fv)
+			if "" == msg {
+				continue
+			}
+			errs = append(errs, &ValidationError{
+				Index:   index,
+				Field:   f.name,
+				Rule:    r.name,
+				Param:   r.param,
+				Message: msg,
+			})
+			// 同一字段只报告第一条未通过的规则
+			break
+		}
+	}
+	if 0 != len(errs) {
+		return errs
+	}
+	return nil
+}
+
+func validateFields(ty reflect.Type) ([]validateField, error) {
+	if v, isOk := validateCache.Load(ty); isOk {
+		return v.([]validateField), nil
+	}
+	fields := make([]validateField, 0)
+	for i := 0; i < ty.NumField(); i++ {
+		t := ty.Field(i)
+		tag := t.Tag.Get(validateTag)
+		if "" == tag {
+			continue
+		}
+		if !t.IsExported() {
+			return nil, fmt.Errorf("validate: %s.%s unexported field cannot be validated", ty.Name(), t.Name)
+		}
+		rules, err := parseValidateTag(tag)
+		if nil != err {
+			return nil, fmt.Errorf("validate: %s.%s %w", ty.Name(), t.Name, err)
+		}
+		for _, r := range rules {
+			if (RuleMax == r.name || RuleMin == r.name) && !isValidateNumberKind(t.Type) {
+				return nil, fmt.Errorf("validate: %s.%s %s does not support %s", ty.Name(), t.Name, r.name, t.Type)
+			}
+		}
+		fields = append(fields, validateField{
+			index: t.Index,
+			name:  fieldJSONName(t),
+			rules: rules,
+		})
+	}
+	validateCache.Store(ty, fields)
+	return fields, nil
+}
+
+// fieldJSONName 取 json tag 的名字部分，没有 json tag 时使用字段名
+func fieldJSONName(t reflect.StructField) string {
+	name := strings.Split(t.Tag.Get("json"), ",")[0]
+	if "" == name || "-" == name {
+		return t.Name
+	}
+	return name
+}
+
+func parseValidateTag(tag string) ([]validateRule, error) {
+	rules := make([]validateRule, 0)
+	for "" != tag {
+		item := tag
+		if strings.HasPrefix(tag, RuleRegexp+"=") {
+			// 正则吃掉剩余全部内容
+			tag = ""
+		} else if inx := strings.Index(tag, ","); -1 != inx {
+			item = tag[:inx]
+			tag = tag[inx+1:]
+		} else {
+			tag = ""
+		}
+		item = strings.TrimSpace(item)
+		if "" == item {
+			continue
+		}
+
+		r := validateRule{name: item}
+		if inx := strings.Index(item, "="); -1 != inx {
+			r.name = item[:inx]
+			r.param = item[inx+1:]
+		}
+		switch r.name {
+		case RuleRequired, RuleEmail, RuleURL:
+		case RuleMax, RuleMin:
+			num, err := strconv.ParseFloat(r.param, 64)
+			if nil != err {
+				return nil, fmt.Errorf("bad %s param %q", r.name, r.param)
+			}
+			r.num = num
+		case RuleEnum:
+			r.enum = make(map[string]bool)
+			for _, e := range strings.Split(r.param, "|") {
+				r.enum[e] = true
+			}
+		case RuleRegexp:
+			re, err := regexp.Compile(r.param)
+			if nil != err {
+				return nil, fmt.Errorf("bad regexp %q: %w", r.param, err)
+			}
+			r.re = re
+		default:
+			return nil, fmt.Errorf("unknown rule %q", r.name)
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+// check 返回失败描述，通过时返回 ""
+func (r *validateRule) check(fv reflect.Value) string {
+	if RuleRequired == r.name {
+		if isEmptyValue(fv) {
+			return "is required"
+		}
+		return ""
+	}
+	// 非必填的空字符串、nil 指针不做其它校验
+	for reflect.Ptr == fv.Kind() {
+		if fv.IsNil() {
+			return ""
+		}
+		fv = fv.Elem()
+	}
+	if reflect.String == fv.Kind() && "" == fv.String() {
+		return ""
+	}
+
+	switch r.name {
+	case RuleMax, RuleMin:
+		n, isLen := validateNumber(fv)
+		if RuleMax == r.name && n > r.num {
+			if isLen {
+				return fmt.Sprintf("length must be at most %s", r.param)
+			}
+			return fmt.Sprintf("must be at most %s", r.param)
+		}
+		if RuleMin == r.name && n < r.num {
+			if isLen {
+				return fmt.Sprintf("length must be at least %s", r.param)
+			}
+			return fmt.Sprintf("must be at least %s", r.param)
+		}
+	case RuleEnum:
+		if !r.enum[fmt.Sprint(fv.Interface())] {
+			return fmt.Sprintf("must be one of [%s]", strings.ReplaceAll(r.param, "|", ","))
+		}
+	case RuleEmail:
+		s := fmt.Sprint(fv.Interface())
+		addr, err := mail.ParseAddress(s)
+		if nil != err || addr.Address != s {
+			return "must be a valid email"
+		}
+	case RuleURL:
+		u, err := url.Parse(fmt.Sprint(fv.Interface()))
+		if nil != err || "" == u.Scheme || "" == u.Host {
+			return "must be a valid url"
+		}
+	case RuleRegexp:
+		if !r.re.MatchString(fmt.Sprint(fv.Interface())) {
+			return fmt.Sprintf("must match %s", r.param)
+		}
+	}
+	return ""
+}
+
+// isValidateNumberKind max、min 支持的类型：字符串、切片、数组、map、数字及其指针
+func isValidateNumberKind(ty reflect.Type) bool {
+	switch derefType(ty).Kind() {
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// validateNumber 字符串、切片、map 取长度，数字取值，类型见 isValidateNumberKind
+func validateNumber(fv reflect.Value) (float64, bool) {
+	switch fv.Kind() {
+	case reflect.String:
+		return float64(utf8.RuneCountInString(fv.String())), true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(fv.Len()), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(fv.Int()), false
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(fv.Uint()), false
+	case reflect.Float32, reflect.Float64:
+		return fv.Float(), false
+	}
+	return 0, false
+}
+
+func isEmptyValue(fv reflect.Value) bool {
+	switch fv.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return fv.IsNil()
+	case reflect.String, reflect.Slice, reflect.Map:
+		return 0 == fv.Len()
+	case reflect.Struct:
+		if tm, isOk := fv.Interface().(time.Time); isOk {
+			return tm.IsZero()
+		}
+	}
+	return fv.IsZero()
+}
@@ -0,0 +1,31 @@
+package at
+
+import (
+	"database/sql"
+	"testing"
+)
+
+func TestValidateTagErrors(t *testing.T) {
+	type unexported struct {
+		name string `validate:"required"`
+	}
+	type nullMax struct {
+		Name sql.NullString `validate:"max=3"`
+	}
+	type ok struct {
+		Name *string `validate:"max=3"`
+		Age  int     `validate:"min=1"`
+	}
+	for _, mod := range []interface{}{&unexported{name: "a"}, &nullMax{}} {
+		err := Validate(mod)
+		if _, isValidation := err.(ValidationErrors); nil == err || isValidation {
+			t.Errorf("Validate(%T) = %v, want a tag error", mod, err)
+		}
+	}
+	name := "abcd"
+	if err := Validate(&ok{Name: &name, Age: 1}); nil == err {
+		t.Error("Validate should fail on max length")
+	} else if _, isValidation := err.(ValidationErrors); !isValidation {
+		t.Errorf("Validate = %v, want ValidationErrors", err)
+	}
+}
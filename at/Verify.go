@@ -0,0 +1,127 @@
+package at
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// 校验问题类型
+const (
+	VerifyMissingTable  = "missing_table"
+	VerifyMissingColumn = "missing_column"
+	VerifyTypeMismatch  = "type_mismatch"
+)
+
+// VerifyIssue model 与数据库表不一致的一处
+type VerifyIssue struct {
+	Table  string
+	Column string
+	// Kind 问题类型，VerifyMissingTable、VerifyMissingColumn、VerifyTypeMismatch
+	Kind string
+	// Expected model 的 type tag
+	Expected string
+	// Actual 数据库中的字段类型
+	Actual string
+}
+
+func (that VerifyIssue) String() string {
+	switch that.Kind {
+	case VerifyMissingTable:
+		return fmt.Sprintf("table %s: missing", that.Table)
+	case VerifyMissingColumn:
+		return fmt.Sprintf("table %s: column %s missing", that.Table, that.Column)
+	}
+	return fmt.Sprintf("table %s: column %s type %s, model expects %s", that.Table, that.Column, that.Actual, that.Expected)
+}
+
+// VerifyReport 校验结果
+type VerifyReport struct {
+	Issues []VerifyIssue
+}
+
+// OK 没有任何问题
+func (that *VerifyReport) OK() bool {
+	return 0 == len(that.Issues)
+}
+
+// Err 有问题时返回包含全部问题的 error，没有问题时为 nil
+func (that *VerifyReport) Err() error {
+	if that.OK() {
+		return nil
+	}
+	lines := make([]string, 0, len(that.Issues))
+	for _, v := range that.Issues {
+		lines = append(lines, v.String())
+	}
+	return errors.New(fmt.Sprintf("schema verify failed:\n%s", strings.Join(lines, "\n")))
+}
+
+var registeredModels []interface{}
+var registeredModelsLock sync.Mutex
+
+// RegisterModel 注册 model，Verify 未传入 model 时校验全部已注册的 model
+// models ...interface{}	model 的指针
+func RegisterModel(models ...interface{}) {
+	registeredModelsLock.Lock()
+	defer registeredModelsLock.Unlock()
+	registeredModels = append(registeredModels, models...)
+}
+
+// RegisteredModels 已注册的 model
+func RegisteredModels() []interface{} {
+	registeredModelsLock.Lock()
+	defer registeredModelsLock.Unlock()
+	return append([]interface{}(nil), registeredModels...)
+}
+
+// Verify 校验 model 与数据库表是否一致：表是否存在、字段是否存在、字段类型是否与 type tag 一致（没有 type tag 的字段不校验类型）。
+// 按 SetDialect 读取 information_schema（MySQL）或 PRAGMA table_info（SQLite）。
+// 启动时或 CI 中调用，report.Err() 不为 nil 时应终止启动。
+// db *sql.DB	数据源
+// models ...interface{}	model 的指针，不传时使用 RegisterModel 注册的 model
+// *VerifyReport	校验结果
+// error	读取表结构失败
+func Verify(ctx context.Context, db *sql.DB, models ...interface{}) (*VerifyReport, error) {
+	if 0 == len(models) {
+		models = RegisteredModels()
+	}
+	report := &VerifyReport{Issues: make([]VerifyIssue, 0)}
+	for _, m := range models {
+		meta := getModelMeta(m)
+		columns, err := LoadTableColumns(ctx, db, meta.tableName)
+		if nil != err {
+			return report, err
+		}
+		if 0 == len(columns) {
+			report.Issues = append(report.Issues, VerifyIssue{Table: meta.tableName, Kind: VerifyMissingTable})
+			continue
+		}
+		existing := make(map[string]TableColumn, len(columns))
+		for _, c := range columns {
+			existing[strings.ToLower(c.Name)] = c
+		}
+		for _, f := range meta.listTableFields {
+			c, isOk := existing[strings.ToLower(f)]
+			if !isOk {
+				report.Issues = append(report.Issues, VerifyIssue{Table: meta.tableName, Column: f, Kind: VerifyMissingColumn})
+				continue
+			}
+			_, tf, _ := meta.fieldByTable(f)
+			if "" == tf.FieldType || normalizeColumnType(tf.FieldType) == normalizeColumnType(c.Type) {
+				continue
+			}
+			report.Issues = append(report.Issues, VerifyIssue{
+				Table:    meta.tableName,
+				Column:   f,
+				Kind:     VerifyTypeMismatch,
+				Expected: tf.FieldType,
+				Actual:   c.Type,
+			})
+		}
+	}
+	return report, nil
+}
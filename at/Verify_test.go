@@ -0,0 +1,113 @@
+package at
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// testProduct 测试用的 model，有 type tag
+type testProduct struct {
+	BaseModel
+	Id    int64  `json:"id" table:"id" type:"bigint"`
+	Name  string `json:"name" table:"name" type:"varchar(64)"`
+	Price string `json:"price" table:"price" type:"decimal(10,2)"`
+	Stock int    `json:"stock" table:"stock"`
+}
+
+func (*testProduct) GetTableName() string {
+	return "product"
+}
+
+// wantVerifyIssues product 的 name 类型不一致、price 缺失，item 表不存在
+func wantVerifyIssues(t *testing.T, report *VerifyReport, nameType string) {
+	t.Helper()
+	want := []VerifyIssue{
+		{Table: "product", Column: "name", Kind: VerifyTypeMismatch, Expected: "varchar(64)", Actual: nameType},
+		{Table: "product", Column: "price", Kind: VerifyMissingColumn},
+		{Table: "item", Kind: VerifyMissingTable},
+	}
+	if !reflect.DeepEqual(want, report.Issues) {
+		t.Fatalf("issues = %+v, want %+v", report.Issues, want)
+	}
+	if report.OK() || nil == report.Err() || !strings.Contains(report.Err().Error(), "column price missing") {
+		t.Errorf("Err = %v", report.Err())
+	}
+}
+
+func TestVerifyMySQL(t *testing.T) {
+	f, db := newFakeDB()
+	defer db.Close()
+	f.rows = func(query string) ([]string, [][]driver.Value) {
+		columns := []string{"COLUMN_NAME", "COLUMN_TYPE", "IS_NULLABLE", "COLUMN_DEFAULT", "COLUMN_COMMENT"}
+		if "product" != f.args[len(f.args)-1][0] {
+			return columns, nil
+		}
+		return columns, [][]driver.Value{
+			{"id", "bigint(20) unsigned", "NO", nil, ""},
+			{"NAME", "varchar(32)", "YES", nil, ""},
+			{"stock", "int(11)", "NO", "0", ""},
+		}
+	}
+	report, err := Verify(context.Background(), db, &testProduct{}, &testItem{})
+	if nil != err {
+		t.Fatal(err)
+	}
+	// bigint(20) unsigned 与 bigint 不一致
+	if 0 == len(report.Issues) || "id" != report.Issues[0].Column {
+		t.Fatalf("issues = %+v, want the unsigned id reported first", report.Issues)
+	}
+	wantVerifyIssues(t, &VerifyReport{Issues: report.Issues[1:]}, "varchar(32)")
+	for _, s := range f.execs {
+		if !strings.Contains(s, "information_schema.COLUMNS") {
+			t.Errorf("MySQL query %q", s)
+		}
+	}
+}
+
+func TestVerifySQLite(t *testing.T) {
+	SetDialect(DialectSQLite)
+	defer SetDialect(DialectMySQL)
+	db, err := sql.Open("sqlite3", "file:"+filepath.Join(t.TempDir(), "verify.db"))
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err = db.Exec("CREATE TABLE product (id BIGINT PRIMARY KEY, name VARCHAR(32), stock INTEGER)"); nil != err {
+		t.Fatal(err)
+	}
+	report, err := Verify(context.Background(), db, &testProduct{}, &testItem{})
+	if nil != err {
+		t.Fatal(err)
+	}
+	wantVerifyIssues(t, report, "VARCHAR(32)")
+
+	if _, err = db.Exec("CREATE TABLE item (id INTEGER PRIMARY KEY, title TEXT)"); nil != err {
+		t.Fatal(err)
+	}
+	if _, err = db.Exec("ALTER TABLE product ADD COLUMN price DECIMAL(10,2)"); nil != err {
+		t.Fatal(err)
+	}
+	if _, err = db.Exec("ALTER TABLE product RENAME COLUMN name TO old_name"); nil != err {
+		t.Fatal(err)
+	}
+	if _, err = db.Exec("ALTER TABLE product ADD COLUMN name varchar(64)"); nil != err {
+		t.Fatal(err)
+	}
+	RegisterModel(&testProduct{}, &testItem{})
+	defer func() {
+		registeredModelsLock.Lock()
+		registeredModels = nil
+		registeredModelsLock.Unlock()
+	}()
+	// 不传 model 时校验已注册的 model，类型不区分大小写
+	if report, err = Verify(context.Background(), db); nil != err || !report.OK() {
+		t.Errorf("report = %+v, err = %v", report, err)
+	}
+}